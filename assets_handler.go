@@ -1,7 +1,9 @@
 package main
 
 import (
+	"artifex/core/service"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -9,20 +11,35 @@ import (
 	"strings"
 )
 
-const imageURLPrefix = "/images/"
+const (
+	imageURLPrefix     = "/images/"
+	thumbnailURLPrefix = "/thumbnails/"
+
+	// imageETagVersion ETag 中的版本号，存储编码或缩略图生成方式变化时递增，
+	// 使浏览器按内容哈希长期缓存的旧响应失效
+	imageETagVersion = 1
+)
 
 // newImageAssetHandler 处理 images 目录下的静态图片请求
-func newImageAssetHandler() http.Handler {
-	imagesDir, err := resolveImagesDir()
-	if err != nil {
+// /images/<file> 返回原图，/thumbnails/<file> 返回缩略图（首次请求时生成并缓存）
+// imagesDir 为图片存储目录，应与 ImageStorage 使用的目录一致（见 service.ResolveImagesDir）
+func newImageAssetHandler(imagesDir string) http.Handler {
+	if imagesDir == "" {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "image assets unavailable", http.StatusInternalServerError)
 		})
 	}
+	storage := service.NewImageStorage(filepath.Dir(imagesDir))
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cleaned := path.Clean(r.URL.Path)
-		if !strings.HasPrefix(cleaned, imageURLPrefix) {
+		var prefix string
+		switch {
+		case strings.HasPrefix(cleaned, imageURLPrefix):
+			prefix = imageURLPrefix
+		case strings.HasPrefix(cleaned, thumbnailURLPrefix):
+			prefix = thumbnailURLPrefix
+		default:
 			http.NotFound(w, r)
 			return
 		}
@@ -32,30 +49,119 @@ func newImageAssetHandler() http.Handler {
 			return
 		}
 
-		rel := strings.TrimPrefix(cleaned, imageURLPrefix)
-		if rel == "" || strings.Contains(rel, "/") || strings.Contains(rel, "\\") {
+		// 允许子目录（如按哈希前缀分片的 images/ab/abcd.png），path.Clean 已消除 ".."
+		// 反斜杠在 Windows 上是路径分隔符，一律拒绝
+		rel := strings.TrimPrefix(cleaned, prefix)
+		if rel == "" || strings.Contains(rel, "\\") {
 			http.NotFound(w, r)
 			return
 		}
 
-		filePath := filepath.Join(imagesDir, rel)
-		info, err := os.Stat(filePath)
-		if err != nil || info.IsDir() {
+		filePath, ok := containedPath(imagesDir, rel)
+		if !ok {
 			http.NotFound(w, r)
 			return
 		}
+		etagName := rel
+		if prefix == thumbnailURLPrefix {
+			if _, err := os.Stat(filePath); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			// 缩略图内容不可变（始终为 PNG），ETag 命中时无需生成或读取文件
+			thumbETag := imageETag("thumb-"+rel, "png")
+			if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, thumbETag) {
+				w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+				w.Header().Set("ETag", fmt.Sprintf("\"%s\"", thumbETag))
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			thumbPath, err := storage.GetThumbnailPath(rel)
+			if err != nil {
+				// 无法生成缩略图（如 WebP 无法解码）时回退为原图
+				fmt.Printf("[AssetHandler] Warning: failed to generate thumbnail for %s: %v\n", rel, err)
+			} else {
+				filePath = thumbPath
+				etagName = "thumb-" + rel
+			}
+		}
 
-		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
-		w.Header().Set("ETag", fmt.Sprintf("\"%s\"", rel))
-		http.ServeFile(w, r, filePath)
+		serveImageFile(w, r, filePath, etagName)
 	})
 }
 
-func resolveImagesDir() (string, error) {
-	exePath, err := os.Executable()
+// containedPath 将相对路径拼接到 baseDir 下，并确认结果仍位于 baseDir 内
+func containedPath(baseDir string, rel string) (string, bool) {
+	base, err := filepath.Abs(baseDir)
 	if err != nil {
-		return "", err
+		return "", false
+	}
+	target := filepath.Join(base, filepath.FromSlash(rel))
+	relative, err := filepath.Rel(base, target)
+	if err != nil || relative == "." || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) || filepath.IsAbs(relative) {
+		return "", false
+	}
+	return target, true
+}
+
+// imageETag 根据文件标识（内容哈希文件名）、实际格式和 imageETagVersion 生成 ETag
+// 同一 ref 的存储内容变化时（如修正扩展名的迁移重写了文件、编码方式升级）ETag 随之变化
+func imageETag(name string, format string) string {
+	return fmt.Sprintf("%s-%s-v%d", name, format, imageETagVersion)
+}
+
+// etagMatches 判断 If-None-Match 请求头是否匹配给定 ETag（弱比较，支持列表和 *）
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if strings.Trim(candidate, "\"") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// serveImageFile 以可长期缓存的方式返回图片文件
+// etagName 为文件标识，实际 ETag 由 imageETag 附加识别出的格式和版本号
+// GET 和 HEAD 返回相同的响应头（含 Content-Length），HEAD 不返回内容；
+// If-None-Match 与 ETag 匹配时由 ServeContent 返回不带内容的 304
+func serveImageFile(w http.ResponseWriter, r *http.Request, filePath string, etagName string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	// 根据文件内容识别类型，扩展名可能与实际格式不符（如 .png 文件中存放 JPEG 数据）
+	// 已设置的 Content-Type 不会被 ServeContent 的扩展名推断覆盖
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		http.Error(w, "failed to read image", http.StatusInternalServerError)
+		return
+	}
+	format := "bin"
+	if contentType := http.DetectContentType(sniff[:n]); strings.HasPrefix(contentType, "image/") {
+		w.Header().Set("Content-Type", contentType)
+		format = strings.TrimPrefix(contentType, "image/")
 	}
 
-	return filepath.Join(filepath.Dir(exePath), "config", "images"), nil
+	// 文件名即内容哈希，内容不可变，可以长期缓存
+	// ServeContent 负责 Range（206）、If-Range、If-None-Match 和 If-Modified-Since（304）
+	// 并根据 modtime 设置 Last-Modified
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("ETag", fmt.Sprintf("\"%s\"", imageETag(etagName, format)))
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, filepath.Base(filePath), info.ModTime(), file)
 }
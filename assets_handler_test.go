@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestAssetHandler 在临时目录中创建一张分片存储的 PNG 图片，返回处理器、图片 URL 和文件内容
+func newTestAssetHandler(t *testing.T) (http.Handler, string, []byte) {
+	t.Helper()
+
+	imagesDir := filepath.Join(t.TempDir(), "images")
+	if err := os.MkdirAll(filepath.Join(imagesDir, "ab"), 0755); err != nil {
+		t.Fatalf("create images dir: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 16, 16))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	if err := os.WriteFile(filepath.Join(imagesDir, "ab", "abcdef.png"), data, 0644); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+
+	return newImageAssetHandler(imagesDir), imageURLPrefix + "ab/abcdef.png", data
+}
+
+func TestImageAssetHandlerRange(t *testing.T) {
+	handler, url, data := newTestAssetHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Range", "bytes=4-11")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rec.Code)
+	}
+	wantRange := "bytes 4-11/" + strconv.Itoa(len(data))
+	if got := rec.Header().Get("Content-Range"); got != wantRange {
+		t.Fatalf("expected Content-Range %q, got %q", wantRange, got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "8" {
+		t.Fatalf("expected Content-Length 8, got %q", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), data[4:12]) {
+		t.Fatalf("unexpected range body %v, want %v", rec.Body.Bytes(), data[4:12])
+	}
+	if got := rec.Header().Get("Content-Type"); got != "image/png" {
+		t.Fatalf("expected Content-Type image/png, got %q", got)
+	}
+}
+
+func TestImageAssetHandlerIfModifiedSince(t *testing.T) {
+	handler, url, _ := newTestAssetHandler(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	lastModified := rec.Header().Get("Last-Modified")
+	if lastModified == "" {
+		t.Fatal("expected Last-Modified header")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Fatalf("expected empty body, got %d bytes", rec.Body.Len())
+	}
+}
+
+func TestImageAssetHandlerHeadMatchesGet(t *testing.T) {
+	handler, url, data := newTestAssetHandler(t)
+
+	get := httptest.NewRecorder()
+	handler.ServeHTTP(get, httptest.NewRequest(http.MethodGet, url, nil))
+	head := httptest.NewRecorder()
+	handler.ServeHTTP(head, httptest.NewRequest(http.MethodHead, url, nil))
+
+	if get.Code != http.StatusOK || head.Code != http.StatusOK {
+		t.Fatalf("expected 200 for GET and HEAD, got %d and %d", get.Code, head.Code)
+	}
+	if got := head.Header().Get("Content-Length"); got != strconv.Itoa(len(data)) {
+		t.Fatalf("expected HEAD Content-Length %d, got %q", len(data), got)
+	}
+	for _, name := range []string{"Content-Length", "Content-Type", "ETag", "Last-Modified", "Cache-Control", "Accept-Ranges"} {
+		if head.Header().Get(name) != get.Header().Get(name) {
+			t.Fatalf("header %s differs: HEAD %q, GET %q", name, head.Header().Get(name), get.Header().Get(name))
+		}
+	}
+	if head.Body.Len() != 0 {
+		t.Fatalf("expected empty HEAD body, got %d bytes", head.Body.Len())
+	}
+	if !bytes.Equal(get.Body.Bytes(), data) {
+		t.Fatal("GET body does not match the stored file")
+	}
+}
+
+func TestImageAssetHandlerIfNoneMatch(t *testing.T) {
+	handler, url, _ := newTestAssetHandler(t)
+	thumbURL := thumbnailURLPrefix + "ab/abcdef.png"
+
+	for _, target := range []string{url, thumbURL} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		etag := rec.Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: expected ETag header", target)
+		}
+
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.Header.Set("If-None-Match", ifNoneMatch)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusNotModified {
+				t.Fatalf("%s with If-None-Match %s: expected 304, got %d", target, ifNoneMatch, rec.Code)
+			}
+			if rec.Body.Len() != 0 {
+				t.Fatalf("%s: expected empty 304 body, got %d bytes", target, rec.Body.Len())
+			}
+			if rec.Header().Get("ETag") != etag {
+				t.Fatalf("%s: expected 304 to repeat ETag %s, got %s", target, etag, rec.Header().Get("ETag"))
+			}
+		}
+
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("If-None-Match", `"stale"`)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s with stale ETag: expected 200, got %d", target, rec.Code)
+		}
+	}
+}
@@ -9,31 +9,37 @@ import (
 
 // App struct - 主应用结构
 type App struct {
-	ctx            context.Context
-	fileService    *service.FileService
-	configService  *service.ConfigService
-	aiService      *service.AIService
-	updateService  *service.UpdateService
-	historyService *service.HistoryService
+	ctx             context.Context
+	fileService     *service.FileService
+	configService   *service.ConfigService
+	aiService       *service.AIService
+	updateService   *service.UpdateService
+	historyService  *service.HistoryService
+	templateService *service.TemplateService
+	logService      *service.LogService
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
 	// 创建服务实例
 	configService := service.NewConfigService()
-	fileService := service.NewFileService()
+	fileService := service.NewFileService(configService)
 	aiService := service.NewAIService(configService)
-	historyService := service.NewHistoryService()
+	historyService := service.NewHistoryService(configService)
+	templateService := service.NewTemplateService()
+	logService := service.NewLogService(configService)
 
 	// 创建更新服务
 	updateService := service.NewUpdateService(RepoOwner, RepoName, Version)
 
 	return &App{
-		fileService:    fileService,
-		configService:  configService,
-		aiService:      aiService,
-		updateService:  updateService,
-		historyService: historyService,
+		fileService:     fileService,
+		configService:   configService,
+		aiService:       aiService,
+		updateService:   updateService,
+		historyService:  historyService,
+		templateService: templateService,
+		logService:      logService,
 	}
 }
 
@@ -47,58 +53,153 @@ func (a *App) Startup(ctx context.Context) {
 	if err := a.configService.Startup(ctx); err != nil {
 		fmt.Printf("Failed to initialize config service: %v\n", err)
 	}
+	// 尽早启用文件日志，记录后续各服务的启动信息
+	a.logService.Startup(ctx)
 	if err := a.historyService.Startup(ctx); err != nil {
 		fmt.Printf("Failed to initialize history service: %v\n", err)
 	}
+	if err := a.templateService.Startup(ctx); err != nil {
+		fmt.Printf("Failed to initialize template service: %v\n", err)
+	}
 	a.aiService.Startup(ctx)
 	a.updateService.Startup(ctx)
 }
 
 // Shutdown 在应用关闭时调用，优雅地停止各个服务
 func (a *App) Shutdown(ctx context.Context) {
+	// 取消所有进行中的 AI 请求并关闭提供商
+	if err := a.aiService.Close(); err != nil {
+		fmt.Printf("Failed to close AI service: %v\n", err)
+	}
+
 	// 停止历史记录服务的后台 goroutine，确保所有待保存的数据都被写入
 	if err := a.historyService.Shutdown(); err != nil {
 		fmt.Printf("Failed to shutdown history service: %v\n", err)
 	}
+
+	// 最后关闭日志文件，保证以上输出都已写入
+	a.logService.Shutdown()
 }
 
 // ===== 文件管理服务方法 =====
 
 // ExportImage 导出图像
 // imageDataURL: data URL 或 image ref (images/...)
-// suggestedName: 建议的文件名
-// format: 导出格式 ("png", "jpeg", "webp")，如果为空则从文件名推断
-// exportDir: 导出目录（可选），如果为空则显示文件保存对话框
-func (a *App) ExportImage(imageDataURL string, suggestedName string, format string, exportDir string) (string, error) {
-	return a.fileService.ExportImage(imageDataURL, suggestedName, format, exportDir)
+// optionsJSON（均可选）: {"suggestedName": string, "format": "png" | "jpeg" | "webp" | "gif",
+// "exportDir": string（为空时显示保存对话框）, "quality": 1-100（jpeg，默认 90）,
+// "metadata": {"prompt", "negativePrompt", "provider", "model", "seed"}（嵌入的生成元数据）,
+// "originalName": string（未指定 suggestedName 时建议 "<原名>-edited.<扩展名>"）,
+// "backgroundColor": "#rgb" | "#rrggbb"（透明图像导出为 jpeg 时的填充色，默认白色）}
+func (a *App) ExportImage(imageDataURL string, optionsJSON string) (string, error) {
+	return a.fileService.ExportImage(imageDataURL, optionsJSON)
+}
+
+// ReadImageMetadata 读取导出图像中嵌入的生成元数据
+// 返回 JSON 格式的元数据，图像中没有元数据时返回空字符串
+func (a *App) ReadImageMetadata(path string) (string, error) {
+	return a.fileService.ReadImageMetadata(path)
+}
+
+// ExportCanvasComposite 将整个画布合成为一张 PNG 导出
+// canvasJSON: {"images": [...], "background"?: "#ffffff"}，未指定 background 时为透明背景
+func (a *App) ExportCanvasComposite(canvasJSON string) (string, error) {
+	return a.fileService.ExportCanvasComposite(canvasJSON)
+}
+
+// ExportPDF 将一组图像导出为多页 PDF（每页一张图像）
+// imageRefsJSON: 图像 ref 的 JSON 数组
+// optionsJSON: {"pageSize"?: "A4" | "Letter", "margin"?: number(pt)}
+func (a *App) ExportPDF(imageRefsJSON string, optionsJSON string) (string, error) {
+	return a.fileService.ExportPDF(imageRefsJSON, optionsJSON)
+}
+
+// ImportImages 从磁盘导入图像
+// 打开多选文件对话框，返回 JSON 格式：{"refs": []string, "results": [{"path", "ref", "status", "error"}]}
+func (a *App) ImportImages() (string, error) {
+	return a.fileService.ImportImages()
 }
 
 // ExportSliceImages 批量导出切片图像
-func (a *App) ExportSliceImages(slicesJSON string) (string, error) {
-	return a.fileService.ExportSliceImages(slicesJSON)
+// quality: jpeg 压缩质量 1-100，<= 0 时使用默认值 90
+func (a *App) ExportSliceImages(slicesJSON string, quality int) (string, error) {
+	return a.fileService.ExportSliceImages(slicesJSON, quality)
 }
 
-// StoreImage persists a data URL and returns an image ref.
+// ExportSlicesAsZip 将所有切片打包导出为单个 ZIP 文件
+// includeManifest: 是否附带 manifest.json
+// 返回 JSON 格式：{"path": string, "files": []string, "count": int}
+func (a *App) ExportSlicesAsZip(slicesJSON string, quality int, includeManifest bool) (string, error) {
+	return a.fileService.ExportSlicesAsZip(slicesJSON, quality, includeManifest)
+}
+
+// StoreImage persists a data URL or http(s) URL and returns a local image ref.
 func (a *App) StoreImage(imageDataURL string) (string, error) {
 	return a.historyService.StoreImage(imageDataURL)
 }
 
+// GetImageDimensions 获取图像宽高（只读取文件头，比 LoadImage 开销小得多，用于画布布局）
+// 返回 JSON 格式：{"width": number, "height": number}
+func (a *App) GetImageDimensions(imageRef string) (string, error) {
+	return a.historyService.GetImageDimensions(imageRef)
+}
 
-// ===== 配置管理服务方法 =====
+// ExtractImageFirstFrame 提取动画图像（GIF）的第一帧并保存为新的 PNG 图像，返回新的 image ref
+// 动画图像不能直接导出为其他格式，需要静态图像时显式调用；静态图像直接返回原 ref
+func (a *App) ExtractImageFirstFrame(imageRef string) (string, error) {
+	return a.historyService.ExtractFirstFrame(imageRef)
+}
 
-// SaveSettings 保存设置
-func (a *App) SaveSettings(settingsJSON string) error {
-	if err := a.configService.SaveSettings(settingsJSON); err != nil {
-		return err
+// GetImageStorageSize 获取图片存储占用的磁盘空间（字节，含缩略图缓存）
+func (a *App) GetImageStorageSize() (int64, error) {
+	return a.historyService.GetImageStorageSize()
+}
+
+// CleanupUnusedImages 删除聊天历史和画布历史都不再引用的图片，用于"释放空间"
+// 最近一小时内生成的图片不会被删除；workers 为并发删除的协程数（<= 0 时使用默认值 4）
+// 返回 JSON 格式：{"deletedCount": number, "reclaimedBytes": number}
+func (a *App) CleanupUnusedImages(workers int) (string, error) {
+	result, err := a.historyService.CleanupUnusedImages(workers)
+	if err != nil {
+		return "", err
 	}
 
-	// 配置变更后，重新加载 AI 提供商以应用新配置
-	if err := a.aiService.ReloadProviders(); err != nil {
-		fmt.Printf("[App] Warning: failed to reload AI providers: %v\n", err)
-		// 不返回错误，因为配置已成功保存
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize cleanup result: %w", err)
 	}
+	return string(data), nil
+}
+
+// TrashImage 将删除的画布图片移入回收站（images/.trash/），可撤销
+// 聊天历史仍引用该图片时不移动文件；回收站中的图片 30 天后自动永久删除
+func (a *App) TrashImage(imageRef string) error {
+	return a.historyService.TrashImage(imageRef)
+}
 
-	return nil
+// RestoreTrashedImage 从回收站恢复图片
+func (a *App) RestoreTrashedImage(imageRef string) error {
+	return a.historyService.RestoreTrashedImage(imageRef)
+}
+
+// ListTrashedImages 列出回收站中的图片
+// 返回 JSON 数组：[{"ref": string, "path": string, "trashName": string, "trashedAt": number}]，按删除时间倒序
+func (a *App) ListTrashedImages() (string, error) {
+	return a.historyService.ListTrashedImages()
+}
+
+// GetImageDedupeReport 获取图像存储的去重统计（自应用启动以来）
+// 返回 JSON 格式：{"newWrites", "dedupeHits", "bytesSaved", "bytesNew", "since"}
+func (a *App) GetImageDedupeReport() (string, error) {
+	return a.historyService.GetImageDedupeReport()
+}
+
+
+// ===== 配置管理服务方法 =====
+
+// SaveSettings 保存设置
+// 设置有变化时 ConfigService 发送 config:changed 事件，由各服务自行重新加载配置
+func (a *App) SaveSettings(settingsJSON string) error {
+	return a.configService.SaveSettings(settingsJSON)
 }
 
 // LoadSettings 加载设置
@@ -106,6 +207,97 @@ func (a *App) LoadSettings() (string, error) {
 	return a.configService.LoadSettings()
 }
 
+// LoadSettingsDetailed 加载设置并返回加载时所做的修正
+// 返回 JSON：{"settings": {...}, "corrections": ["missing field ai.xxx, using default", ...]}
+func (a *App) LoadSettingsDetailed() (string, error) {
+	settings, corrections, err := a.configService.LoadSettingsDetailed()
+	if err != nil {
+		return "", err
+	}
+	if corrections == nil {
+		corrections = []string{}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"settings":    json.RawMessage(settings),
+		"corrections": corrections,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize settings: %w", err)
+	}
+	return string(data), nil
+}
+
+// ExportSettings 导出当前设置，用于迁移到其他机器
+// includeSecrets 为 true 时包含加密后的密钥（仅同一台机器可解密）
+func (a *App) ExportSettings(includeSecrets bool) (string, error) {
+	return a.configService.ExportSettings(includeSecrets)
+}
+
+// ImportSettings 导入 ExportSettings 导出的设置
+// 返回 JSON：{"settings": string, "missingSecrets": [需要重新填写的密钥字段]}
+func (a *App) ImportSettings(exportJSON string) (string, error) {
+	result, err := a.configService.ImportSettings(exportJSON)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize import result: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetDataDirInfo 获取应用数据目录信息
+// 返回 JSON 格式：{"path", "defaultPath", "relocated", "reason", "overridden"}
+// relocated 为 true 表示默认目录不可写，数据已保存到用户配置目录
+func (a *App) GetDataDirInfo() (string, error) {
+	info, err := service.ResolveDataDirInfo()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize data dir info: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetDataDirectory 获取应用数据目录的路径（图片、历史记录、设置和日志都保存在该目录下）
+func (a *App) GetDataDirectory() (string, error) {
+	return service.ResolveDataDir()
+}
+
+// OpenDataDirectory 在系统文件管理器中打开应用数据目录
+func (a *App) OpenDataDirectory() error {
+	return service.OpenDataDir()
+}
+
+// SaveProfile 保存命名配置档案（如 "work-vertex"、"personal-openai"）
+// 同名档案会被覆盖，不影响当前生效的设置
+func (a *App) SaveProfile(name string, settingsJSON string) error {
+	return a.configService.SaveProfile(name, settingsJSON)
+}
+
+// ListProfiles 列出全部配置档案
+// 返回 JSON：{"profiles": [{"name", "provider", "updatedAt", "active"}...], "active": string}
+func (a *App) ListProfiles() (string, error) {
+	return a.configService.ListProfiles()
+}
+
+// ActivateProfile 启用配置档案：写入为当前设置
+// 与 SaveSettings 相同，通过 config:changed 事件触发 AI 提供商重新加载
+func (a *App) ActivateProfile(name string) error {
+	return a.configService.ActivateProfile(name)
+}
+
+// DeleteProfile 删除配置档案
+func (a *App) DeleteProfile(name string) error {
+	return a.configService.DeleteProfile(name)
+}
+
 // ===== AI 服务方法 =====
 
 // GenerateImage 生成图像
@@ -114,6 +306,27 @@ func (a *App) GenerateImage(paramsJSON string, requestID string) (string, error)
 	return a.aiService.GenerateImage(paramsJSON, requestID)
 }
 
+// GenerateImageDetailed 生成图像并返回详细结果
+// 返回 JSON 格式：{"image": string, "requestId": string, "provider": string, "model": string, "durationMs": int, "revisedPrompt": string, "metadata": object}
+// requestID: 请求 ID，用于管理 context 和取消请求；传空字符串时由服务端生成并在结果中返回
+func (a *App) GenerateImageDetailed(paramsJSON string, requestID string) (string, error) {
+	return a.aiService.GenerateImageDetailed(paramsJSON, requestID)
+}
+
+// GenerateImages 批量生成图像（同一提示词生成 count 张变体）
+// 返回 JSON 格式的图像 ref 数组
+// requestID: 请求 ID，取消该请求会终止整个批次
+func (a *App) GenerateImages(paramsJSON string, requestID string) (string, error) {
+	return a.aiService.GenerateImages(paramsJSON, requestID)
+}
+
+// NewRequestID 生成唯一的请求 ID
+// 前端可先获取 ID 再发起请求，以便随时通过 CancelAIRequest 取消；
+// 同一 ID 发起新请求时会取消该 ID 上仍在进行的请求
+func (a *App) NewRequestID() string {
+	return service.NewRequestID()
+}
+
 // EditMultiImages 编辑图像（支持单图或多图）
 // 统一使用多图编辑方法，即使只有一张图也使用此方法
 // requestID: 请求 ID，用于管理 context 和取消请求
@@ -121,12 +334,26 @@ func (a *App) EditMultiImages(paramsJSON string, requestID string) (string, erro
 	return a.aiService.EditMultiImages(paramsJSON, requestID)
 }
 
+// EditMultiImagesDetailed 编辑图像并返回详细结果（JSON）
+// params.bestEffort 为 true 时跳过无效的输入图像，并在 skippedImages 中列出下标和原因
+// requestID: 请求 ID，用于管理 context 和取消请求
+func (a *App) EditMultiImagesDetailed(paramsJSON string, requestID string) (string, error) {
+	return a.aiService.EditMultiImagesDetailed(paramsJSON, requestID)
+}
+
 // RemoveBackground 移除背景
 // requestID: 请求 ID，用于管理 context 和取消请求
 func (a *App) RemoveBackground(imageData string, requestID string) (string, error) {
 	return a.aiService.RemoveBackground(imageData, requestID)
 }
 
+// RemoveBackgroundWithOptions 移除背景（带选项）
+// paramsJSON: {"image": string, "fallbackColor"?: string}
+// 返回 JSON 格式：{"image": string, "transparent": bool, "warning"?: string}
+func (a *App) RemoveBackgroundWithOptions(paramsJSON string, requestID string) (string, error) {
+	return a.aiService.RemoveBackgroundWithOptions(paramsJSON, requestID)
+}
+
 // EnhancePrompt 增强提示词
 // paramsJSON: JSON 格式的 EnhancePromptParams，包含 prompt 和可选的 referenceImages
 // requestID: 请求 ID，用于管理 context 和取消请求
@@ -134,16 +361,120 @@ func (a *App) EnhancePrompt(paramsJSON string, requestID string) (string, error)
 	return a.aiService.EnhancePrompt(paramsJSON, requestID)
 }
 
+// ListUnfinishedRequests 列出上次运行时未完成的请求（应用异常退出时遗留）
+// 返回 JSON 数组：[{"requestId": string, "operation": string, "provider": string, "params": object, "paramsOmitted": bool, "createdAt": int, "attempts": int}]
+// 启动时也会发送 ai:unfinished-requests 事件；使用相同的 requestId 重新执行可保留恢复次数
+func (a *App) ListUnfinishedRequests() (string, error) {
+	return a.aiService.ListUnfinishedRequests()
+}
+
+// DismissUnfinishedRequest 忽略上次运行未完成的请求，requestID 为空时忽略全部
+func (a *App) DismissUnfinishedRequest(requestID string) error {
+	return a.aiService.DismissUnfinishedRequest(requestID)
+}
+
+// GetCurrentCapabilities 获取当前配置的提供商支持的功能
+// 返回 JSON 格式：{"provider": string, "generateImage": bool, "editImage": bool, "inpaint": bool, "seed": bool, ..., "supportedSizes": [], "supportedAspectRatios": []}
+func (a *App) GetCurrentCapabilities() (string, error) {
+	return a.aiService.CurrentCapabilities()
+}
+
 // CancelAIRequest 取消 AI 请求
-// requestID: 要取消的请求 ID
+// requestID: 要取消的请求 ID，该 ID 同时用于多个操作（如生成和增强提示词）时全部取消
 func (a *App) CancelAIRequest(requestID string) error {
 	return a.aiService.CancelRequest(requestID)
 }
 
+// ListActiveAIRequests 列出所有进行中的 AI 请求及其存在时长
+// 返回 JSON 数组：[{"requestId": string, "operation": string, "createdAt": int, "ageMs": int}]
+// 同一请求 ID 在不同操作（"gen"、"edit"、"rmbg"、"enh"）下各占一项
+func (a *App) ListActiveAIRequests() (string, error) {
+	return a.aiService.ListActiveRequests()
+}
+
+// CancelAllRequests 取消所有进行中的 AI 请求
+// 返回被取消的请求数量
+func (a *App) CancelAllRequests() (int, error) {
+	return a.aiService.CancelAllRequests()
+}
+
+// GetAIQueueStatus 获取 AI 请求队列状态
+// 返回 JSON 格式：{"limit": int, "active": int, "queued": int}
+func (a *App) GetAIQueueStatus() (string, error) {
+	data, err := json.Marshal(a.aiService.GetQueueStatus())
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize queue status: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetUsageStats 获取 AI 调用用量统计
+// sinceUnix: 起始 Unix 时间戳（秒），<= 0 表示统计全部记录
+// 返回 JSON 格式：{"since": int, "total": {...}, "byProvider": {...}, "byModel": {...}, "byOperation": {...}}
+func (a *App) GetUsageStats(sinceUnix int64) (string, error) {
+	return a.aiService.GetUsageStats(sinceUnix)
+}
+
+// ValidateAISettings 校验 AI 提供商配置是否完整
+// 返回 JSON 格式：{"valid": bool, "problems": []string}
+func (a *App) ValidateAISettings(providerName string) (string, error) {
+	problems, err := a.aiService.ValidateSettings(providerName)
+	if err != nil {
+		return "", err
+	}
+	if problems == nil {
+		problems = []string{}
+	}
+
+	result := map[string]interface{}{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return string(data), nil
+}
+
+// PingAIProvider 检测提供商服务地址的连通性（设置页"测试连接"按钮），不消耗生成配额
+// providerName 为空时检测当前配置的提供商
+// 返回 JSON 格式：{"provider", "url", "reachable", "statusCode", "latencyMs", "error"}
+func (a *App) PingAIProvider(providerName string) (string, error) {
+	result, err := a.aiService.PingProvider(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize ping result: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetAIProviderLimits 返回提供商最近一次响应中的限额信息
+// providerName 为空时使用当前配置的提供商
+// 返回 JSON 格式：{"provider", "status": "known"|"unknown", "limits": {"limitRequests", "remainingRequests", "resetRequests", "limitTokens", "remainingTokens", "resetTokens", "retryAfter", "observedAt"}}
+func (a *App) GetAIProviderLimits(providerName string) (string, error) {
+	result, err := a.aiService.GetProviderLimits(providerName)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize provider limits: %w", err)
+	}
+	return string(data), nil
+}
+
 // CheckAIProviderAvailability 检测 AI 提供商可用性
+// 几秒内的重复检测复用上次结果，force 为 true 时跳过缓存立即检测
 // 返回 JSON 格式：{"available": bool, "message": string}
-func (a *App) CheckAIProviderAvailability(providerName string) (string, error) {
-	available, message, err := a.aiService.CheckProviderAvailability(providerName)
+func (a *App) CheckAIProviderAvailability(providerName string, force bool) (string, error) {
+	available, message, err := a.aiService.CheckProviderAvailability(providerName, force)
 	if err != nil {
 		return "", err
 	}
@@ -163,6 +494,17 @@ func (a *App) CheckAIProviderAvailability(providerName string) (string, error) {
 
 // ===== 更新服务方法 =====
 
+// RunSelfTest 一键诊断，检查数据目录和图片目录可写、当前提供商可用、历史文件可解析、磁盘剩余空间
+// 返回 JSON 格式：{"passed": bool, "checks": [{"name", "passed", "message"}], "durationMs": number}
+func (a *App) RunSelfTest() (string, error) {
+	report := service.RunSelfTest(a.aiService, a.historyService)
+	data, err := json.Marshal(report)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize self-test report: %w", err)
+	}
+	return string(data), nil
+}
+
 // CheckForUpdate 检查是否有可用更新
 // 返回 JSON 格式：{"hasUpdate": bool, "latestVersion": string, "currentVersion": string, "releaseUrl": string, "releaseNotes": string, "error": string}
 func (a *App) CheckForUpdate() (string, error) {
@@ -182,9 +524,32 @@ func (a *App) LoadChatHistory() (string, error) {
 	return a.historyService.LoadChatHistory()
 }
 
+// GetChatMessage 按 ID 获取单条聊天记录
+// 返回 JSON 格式：{"id", "role", "type", "text", "images"?: [image ref...], "timestamp"}，不存在时返回错误
+func (a *App) GetChatMessage(id string) (string, error) {
+	return a.historyService.GetChatMessage(id)
+}
+
+// AppendChatMessage 追加一条聊天记录，不重写之前的全部消息
+// messageJSON: {"id", "role", "type", "text", "images"?: [data URL 或 image ref...], "timestamp"}，已存在相同 ID 时替换
+func (a *App) AppendChatMessage(messageJSON string) error {
+	return a.historyService.AppendChatMessage(messageJSON)
+}
+
 // ClearChatHistory 清除聊天历史记录
-func (a *App) ClearChatHistory() error {
-	return a.historyService.ClearChatHistory()
+// pruneImages 为 true 时同时删除只被聊天历史引用的图片（画布仍引用的保留）
+// 返回 JSON 格式：{"deletedCount": number, "reclaimedBytes": number}
+func (a *App) ClearChatHistory(pruneImages bool) (string, error) {
+	result, err := a.historyService.ClearChatHistory(pruneImages)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize cleanup result: %w", err)
+	}
+	return string(data), nil
 }
 
 // LoadCanvasHistory 加载画布历史记录
@@ -194,8 +559,19 @@ func (a *App) LoadCanvasHistory() (string, error) {
 }
 
 // ClearCanvasHistory 清除画布历史记录
-func (a *App) ClearCanvasHistory() error {
-	return a.historyService.ClearCanvasHistory()
+// pruneImages 为 true 时同时删除只被画布历史引用的图片（聊天记录仍引用的保留）
+// 返回 JSON 格式：{"deletedCount": number, "reclaimedBytes": number}
+func (a *App) ClearCanvasHistory(pruneImages bool) (string, error) {
+	result, err := a.historyService.ClearCanvasHistory(pruneImages)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize cleanup result: %w", err)
+	}
+	return string(data), nil
 }
 
 // SaveChatHistorySync 同步保存聊天历史记录（用于应用关闭时）
@@ -224,8 +600,47 @@ func (a *App) Update() (string, error) {
 	return "", nil
 }
 
+// CancelUpdate 取消进行中的更新下载
+// 取消后 "update:progress" 事件发送 status 为 "cancelled" 的进度，Update 返回错误
+// 返回是否有进行中的更新被取消
+func (a *App) CancelUpdate() bool {
+	return a.updateService.CancelUpdate()
+}
+
+// GetLogPath 获取日志文件路径（logs/app.log），用于"打开日志"
+// 轮转后的历史日志位于同一目录（app.log.1 ~ app.log.3），崩溃信息位于 crash.log
+func (a *App) GetLogPath() (string, error) {
+	return a.logService.GetLogPath()
+}
+
 // RestartApplication 重启应用程序
 // 更新完成后调用此方法自动重启应用
 func (a *App) RestartApplication() error {
 	return a.updateService.RestartApplication()
 }
+
+// ===== 提示词模板服务方法 =====
+
+// SavePromptTemplate 保存提示词模板
+// templateJSON: {"id"?: string, "name": string, "description"?: string, "content": string, "defaults"?: {...}}
+// 内容中使用 {{name}} 作为占位符，返回保存后的模板 JSON
+func (a *App) SavePromptTemplate(templateJSON string) (string, error) {
+	return a.templateService.SaveTemplate(templateJSON)
+}
+
+// ListPromptTemplates 列出全部提示词模板
+// 返回 JSON 数组
+func (a *App) ListPromptTemplates() (string, error) {
+	return a.templateService.ListTemplates()
+}
+
+// DeletePromptTemplate 删除提示词模板
+func (a *App) DeletePromptTemplate(id string) error {
+	return a.templateService.DeleteTemplate(id)
+}
+
+// RenderPromptTemplate 渲染提示词模板
+// varsJSON: 变量名到值的 JSON 对象，如 {"subject": "a red sneaker"}
+func (a *App) RenderPromptTemplate(id string, varsJSON string) (string, error) {
+	return a.templateService.RenderTemplate(id, varsJSON)
+}
@@ -3,6 +3,9 @@ package provider
 import (
 	"context"
 	"artifex/core/types"
+	"fmt"
+	"math"
+	"strings"
 )
 
 // ==================== AI 功能枚举 ====================
@@ -19,8 +22,18 @@ const (
 	FeatureEnhancePrompt AIFeature = "enhancePrompt"
 	// FeatureRemoveBackground 背景移除功能
 	FeatureRemoveBackground AIFeature = "removeBackground"
+	// FeatureTransparentOutput 透明背景输出功能
+	FeatureTransparentOutput AIFeature = "transparentOutput"
+	// FeatureInpaint 遮罩局部重绘功能
+	FeatureInpaint AIFeature = "inpaint"
 	// FeatureReferenceImage 参考图像功能
 	FeatureReferenceImage AIFeature = "referenceImage"
+	// FeatureSeed 随机种子功能（可复现生成结果）
+	FeatureSeed AIFeature = "seed"
+	// FeatureNegativePrompt 反向提示词功能
+	FeatureNegativePrompt AIFeature = "negativePrompt"
+	// FeaturePreview 生成过程中的中间预览功能
+	FeaturePreview AIFeature = "preview"
 )
 
 // ==================== 提供商能力声明 ====================
@@ -36,8 +49,22 @@ type ProviderCapabilities struct {
 	EnhancePrompt bool `json:"enhancePrompt"`
 	// RemoveBackground 是否支持背景移除
 	RemoveBackground bool `json:"removeBackground"`
+	// TransparentOutput 是否能输出带透明通道的图像（不支持时背景移除结果可能是纯色背景）
+	TransparentOutput bool `json:"transparentOutput"`
+	// Inpaint 是否支持带遮罩的局部编辑（MultiImageEditParams.Mask）
+	Inpaint bool `json:"inpaint"`
 	// ReferenceImage 是否支持参考图像
 	ReferenceImage bool `json:"referenceImage"`
+	// Seed 是否支持随机种子（不支持时 Seed 参数会被忽略，结果不可复现）
+	Seed bool `json:"seed"`
+	// NegativePrompt 是否支持反向提示词（不支持时 NegativePrompt 参数会被忽略）
+	NegativePrompt bool `json:"negativePrompt"`
+	// Preview 是否能在生成过程中返回中间预览（不支持时 GenerateImageParams.Preview 会被忽略）
+	Preview bool `json:"preview"`
+	// SupportedSizes 支持的图像尺寸（如 "1K", "2K", "4K"），为空表示不限制
+	SupportedSizes []string `json:"supportedSizes,omitempty"`
+	// SupportedAspectRatios 支持的宽高比（如 "1:1", "16:9"），为空表示不限制
+	SupportedAspectRatios []string `json:"supportedAspectRatios,omitempty"`
 }
 
 // IsSupported 检查指定功能是否支持
@@ -51,13 +78,75 @@ func (c ProviderCapabilities) IsSupported(feature AIFeature) bool {
 		return c.EnhancePrompt
 	case FeatureRemoveBackground:
 		return c.RemoveBackground
+	case FeatureTransparentOutput:
+		return c.TransparentOutput
+	case FeatureInpaint:
+		return c.Inpaint
 	case FeatureReferenceImage:
 		return c.ReferenceImage
+	case FeatureSeed:
+		return c.Seed
+	case FeatureNegativePrompt:
+		return c.NegativePrompt
+	case FeaturePreview:
+		return c.Preview
 	default:
 		return false
 	}
 }
 
+// ValidateImageOptions 校验图像尺寸和宽高比是否在支持范围内
+// 参数为空时表示使用提供商默认值，不做校验
+func (c ProviderCapabilities) ValidateImageOptions(imageSize, aspectRatio string) error {
+	if imageSize != "" && len(c.SupportedSizes) > 0 && !containsString(c.SupportedSizes, imageSize) {
+		return fmt.Errorf("unsupported imageSize %q, allowed values: %s", imageSize, strings.Join(c.SupportedSizes, ", "))
+	}
+	if aspectRatio != "" && len(c.SupportedAspectRatios) > 0 && !containsString(c.SupportedAspectRatios, aspectRatio) {
+		return fmt.Errorf("unsupported aspectRatio %q, allowed values: %s", aspectRatio, strings.Join(c.SupportedAspectRatios, ", "))
+	}
+	return nil
+}
+
+// KnownImageSizes 所有提供商可能支持的图像尺寸档位
+var KnownImageSizes = []string{"1K", "2K", "4K"}
+
+// KnownAspectRatios 所有提供商可能支持的宽高比
+var KnownAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "9:21", "21:9"}
+
+// NormalizeImageOptions 规范化并校验图像尺寸和宽高比，返回规范化后的值
+// 尺寸不区分大小写（"2k" -> "2K"），宽高比忽略空白（" 16 : 9 " -> "16:9"）；
+// 不属于已知取值（如 "16;9"、"3K"）时返回列出有效值的错误。参数为空时表示使用提供商默认值
+func NormalizeImageOptions(imageSize, aspectRatio string) (string, string, error) {
+	imageSize = strings.ToUpper(strings.TrimSpace(imageSize))
+	if imageSize != "" && !containsString(KnownImageSizes, imageSize) {
+		return "", "", fmt.Errorf("invalid imageSize %q, valid values: %s", imageSize, strings.Join(KnownImageSizes, ", "))
+	}
+
+	aspectRatio = strings.Join(strings.Fields(aspectRatio), "")
+	if aspectRatio != "" && !containsString(KnownAspectRatios, aspectRatio) {
+		return "", "", fmt.Errorf("invalid aspectRatio %q, valid values: %s", aspectRatio, strings.Join(KnownAspectRatios, ", "))
+	}
+	return imageSize, aspectRatio, nil
+}
+
+// VariationSeed 返回批量生成中第 index 张变体使用的种子（第 0 张使用原种子）
+// 递增后的种子保持在 int32 范围内（Gemini 等仅支持 int32）：正数种子按模 MaxInt32
+// 回绕到 [1, MaxInt32]，负数种子递增时跳过表示随机的 0；seed 为 0 时始终返回 0
+func VariationSeed(seed int64, index int) int64 {
+	if seed == 0 || index <= 0 {
+		return seed
+	}
+	offset := int64(index)
+	if seed < 0 {
+		next := seed + offset
+		if next >= 0 {
+			next++
+		}
+		return next
+	}
+	return ((seed-1)%math.MaxInt32+offset%math.MaxInt32)%math.MaxInt32 + 1
+}
+
 // ==================== AI 提供商接口 ====================
 
 // AIProvider AI 提供商接口
@@ -71,27 +160,27 @@ type AIProvider interface {
 	//   - ctx: 上下文
 	//   - params: 图像生成参数
 	// 返回：
-	//   - base64 编码的图像数据（含 data URI 前缀）
+	//   - 图像结果（图像数据含 data URI 前缀，以及提供商回传的模型等元数据）
 	//   - 错误信息
-	GenerateImage(ctx context.Context, params types.GenerateImageParams) (string, error)
+	GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error)
 
 	// EditMultiImages 多图编辑/融合
 	// 参数：
 	//   - ctx: 上下文
 	//   - params: 多图编辑参数（支持单图或多图，单图时也使用此方法）
 	// 返回：
-	//   - base64 编码的图像数据（含 data URI 前缀）
+	//   - 图像结果（图像数据含 data URI 前缀，以及提供商回传的模型等元数据）
 	//   - 错误信息
-	EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (string, error)
+	EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error)
 
 	// EnhancePrompt 增强提示词
 	// 参数：
 	//   - ctx: 上下文
 	//   - params: 增强提示词参数（包含提示词和可选的参考图像）
 	// 返回：
-	//   - 增强后的提示词
+	//   - 提示词结果（增强后的提示词，以及提供商回传的模型和用量信息）
 	//   - 错误信息
-	EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (string, error)
+	EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error)
 
 	// GetCapabilities 返回提供商支持的功能
 	GetCapabilities() ProviderCapabilities
@@ -108,3 +197,56 @@ type AIProvider interface {
 	// 在提供商不再使用时调用，用于释放连接、清理缓存等
 	Close() error
 }
+
+// BatchImageGenerator 支持原生批量生成的提供商可选实现此接口
+// 未实现时 AIService 会依次调用 GenerateImage 生成多张图像
+type BatchImageGenerator interface {
+	// GenerateImages 一次调用生成 count 张图像
+	GenerateImages(ctx context.Context, params types.GenerateImageParams, count int) ([]*types.ImageResult, error)
+}
+
+// ModelLister 可选接口：列出提供商可用的模型
+// AIService 用它校验调用参数中的模型覆盖，未实现时不做校验
+type ModelLister interface {
+	// ListModels 返回可用的模型名称
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ==================== 通用辅助函数 ====================
+
+// resolveModel 返回调用参数中的模型覆盖，为空时使用配置的默认模型
+func resolveModel(override, fallback string) string {
+	if override = strings.TrimSpace(override); override != "" {
+		return override
+	}
+	return fallback
+}
+
+// containsString 判断切片中是否包含指定字符串
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMaskInstruction 为带遮罩的编辑附加说明
+// 遮罩以最后一张图像的形式发送给不支持独立遮罩参数的多模态模型
+func applyMaskInstruction(prompt, mask string) string {
+	if mask == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nThe last image is a mask. Only modify the regions that are white in the mask; keep everything in the black regions exactly unchanged.", prompt)
+}
+
+// applyNegativePrompt 将反向提示词以文字说明的形式附加到提示词后
+// 用于不支持独立反向提示词参数的模型
+func applyNegativePrompt(prompt, negativePrompt string) string {
+	negativePrompt = strings.TrimSpace(negativePrompt)
+	if negativePrompt == "" {
+		return prompt
+	}
+	return fmt.Sprintf("%s\n\nAvoid the following in the image: %s", prompt, negativePrompt)
+}
@@ -0,0 +1,37 @@
+package provider
+
+import (
+	"math"
+	"testing"
+)
+
+func TestVariationSeed(t *testing.T) {
+	cases := []struct {
+		name  string
+		seed  int64
+		index int
+		want  int64
+	}{
+		{name: "random stays random", seed: 0, index: 3, want: 0},
+		{name: "first keeps seed", seed: 42, index: 0, want: 42},
+		{name: "increments", seed: 42, index: 2, want: 44},
+		{name: "max int32 first", seed: math.MaxInt32, index: 0, want: math.MaxInt32},
+		{name: "wraps past max int32", seed: math.MaxInt32, index: 1, want: 1},
+		{name: "wraps near max int32", seed: math.MaxInt32 - 1, index: 3, want: 2},
+		{name: "negative increments", seed: -5, index: 2, want: -3},
+		{name: "negative skips zero", seed: -1, index: 1, want: 1},
+		{name: "negative past zero", seed: -2, index: 3, want: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := VariationSeed(tc.seed, tc.index)
+			if got != tc.want {
+				t.Fatalf("VariationSeed(%d, %d) = %d, want %d", tc.seed, tc.index, got, tc.want)
+			}
+			if _, err := geminiSeed(got); tc.seed >= math.MinInt32 && tc.seed <= math.MaxInt32 && err != nil {
+				t.Fatalf("variation seed %d is not accepted by Gemini: %v", got, err)
+			}
+		})
+	}
+}
@@ -16,11 +16,15 @@ import (
 
 // cloudCapabilities Cloud 提供商的功能支持矩阵
 var cloudCapabilities = ProviderCapabilities{
-	GenerateImage:    true,
-	EditImage:        true,
-	EnhancePrompt:    true,
-	RemoveBackground: true,
-	ReferenceImage:   true,
+	GenerateImage:     true,
+	EditImage:         true,
+	EnhancePrompt:     true,
+	RemoveBackground:  true,
+	TransparentOutput: true, // 由云服务负责输出透明 PNG
+	Inpaint:           true, // mask 参数直接转发
+	ReferenceImage:    true,
+	Seed:              true, // 参数直接转发，由云服务决定是否使用
+	NegativePrompt:    true,
 }
 
 // ==================== CloudProvider 实现 ====================
@@ -32,6 +36,7 @@ type CloudProvider struct {
 	endpointURL string
 	httpClient  *http.Client
 	settings    types.AISettings
+	rateLimits  *rateLimitTracker // 最近一次响应中的限额信息
 }
 
 // NewCloudProvider 创建云提供商实例
@@ -41,8 +46,10 @@ func NewCloudProvider(ctx context.Context, settings types.AISettings) (*CloudPro
 	}
 
 	// 创建 HTTP 客户端，设置合理的超时时间
+	rateLimits := newRateLimitTracker()
 	httpClient := &http.Client{
-		Timeout: 5 * time.Minute, // 图像生成可能需要较长时间
+		Transport: rateLimits.transport(NewHTTPTransport(settings)),
+		Timeout:   5 * time.Minute, // 图像生成可能需要较长时间
 	}
 
 	return &CloudProvider{
@@ -50,9 +57,15 @@ func NewCloudProvider(ctx context.Context, settings types.AISettings) (*CloudPro
 		endpointURL: settings.CloudEndpointURL,
 		httpClient:  httpClient,
 		settings:    settings,
+		rateLimits:  rateLimits,
 	}, nil
 }
 
+// RateLimits 返回最近一次响应中的限额信息（实现 RateLimitReporter 接口）
+func (p *CloudProvider) RateLimits() (RateLimitInfo, bool) {
+	return p.rateLimits.snapshot()
+}
+
 // Name 返回提供商名称
 func (p *CloudProvider) Name() string {
 	return "cloud"
@@ -134,29 +147,123 @@ func (p *CloudProvider) Close() error {
 // ==================== API 方法实现 ====================
 
 // GenerateImage 生成图像
-func (p *CloudProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (string, error) {
-	return p.callCloudAPI(ctx, "generateImage", params)
+func (p *CloudProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
+	response, err := p.callCloudAPI(ctx, "generateImage", params)
+	if err != nil {
+		return nil, err
+	}
+	return extractCloudImageResult(response)
 }
 
 // EditMultiImages 多图编辑/融合
 // 支持单图或多图编辑（单图时也使用此方法）
-func (p *CloudProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (string, error) {
+func (p *CloudProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
 	if len(params.Images) < 1 {
-		return "", fmt.Errorf("at least 1 image is required")
+		return nil, fmt.Errorf("at least 1 image is required")
+	}
+	response, err := p.callCloudAPI(ctx, "editMultiImages", params)
+	if err != nil {
+		return nil, err
 	}
-	return p.callCloudAPI(ctx, "editMultiImages", params)
+	return extractCloudImageResult(response)
 }
 
 // EnhancePrompt 增强提示词
-func (p *CloudProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (string, error) {
+func (p *CloudProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
 	// 直接传递 EnhancePromptParams 结构
-	return p.callCloudAPI(ctx, "enhancePrompt", params)
+	response, err := p.callCloudAPI(ctx, "enhancePrompt", params)
+	if err != nil {
+		return nil, err
+	}
+
+	// 增强提示词返回文本
+	result := &types.PromptResult{Usage: extractCloudUsage(response)}
+	if model, ok := response["model"].(string); ok {
+		result.Model = model
+	}
+	if text, ok := response["text"].(string); ok {
+		result.Text = text
+		return result, nil
+	}
+	if prompt, ok := response["prompt"].(string); ok {
+		result.Text = prompt
+		return result, nil
+	}
+	return nil, fmt.Errorf("invalid response format: expected 'text' or 'prompt' field")
 }
 
 // ==================== 辅助函数 ====================
 
-// callCloudAPI 调用云服务 API，直接转发参数
-func (p *CloudProvider) callCloudAPI(ctx context.Context, endpoint string, requestData interface{}) (string, error) {
+// extractCloudImageResult 从云服务响应中提取图像结果
+// 图像操作返回图像数据（data URI 格式），可选回传 model、revisedPrompt 等元数据
+func extractCloudImageResult(response map[string]interface{}) (*types.ImageResult, error) {
+	result := &types.ImageResult{}
+	if imageData, ok := response["image"].(string); ok {
+		result.Image = imageData
+	} else if imageData, ok := response["imageData"].(string); ok {
+		result.Image = imageData
+	} else {
+		return nil, fmt.Errorf("invalid response format: expected 'image' or 'imageData' field")
+	}
+
+	if model, ok := response["model"].(string); ok {
+		result.Model = model
+	}
+	if revisedPrompt, ok := response["revisedPrompt"].(string); ok {
+		result.RevisedPrompt = revisedPrompt
+	}
+	result.Usage = extractCloudUsage(response)
+
+	// 其他标量字段作为元数据透传给前端
+	for key, value := range response {
+		switch key {
+		case "image", "imageData", "model", "revisedPrompt", "usage":
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			setResultMetadata(result, key, v)
+		case float64, bool:
+			setResultMetadata(result, key, fmt.Sprint(v))
+		}
+	}
+
+	return result, nil
+}
+
+// extractCloudUsage 从云服务响应的 usage 字段中提取用量信息（可选字段）
+// 支持 {"usage": {"inputTokens": n, "outputTokens": n, "totalTokens": n, "images": n}}
+func extractCloudUsage(response map[string]interface{}) *types.Usage {
+	usageMap, ok := response["usage"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	readInt := func(key string) int {
+		if value, ok := usageMap[key].(float64); ok {
+			return int(value)
+		}
+		return 0
+	}
+
+	return &types.Usage{
+		InputTokens:  readInt("inputTokens"),
+		OutputTokens: readInt("outputTokens"),
+		TotalTokens:  readInt("totalTokens"),
+		Images:       readInt("images"),
+	}
+}
+
+// setResultMetadata 设置图像结果的元数据字段
+func setResultMetadata(result *types.ImageResult, key, value string) {
+	if result.Metadata == nil {
+		result.Metadata = make(map[string]string)
+	}
+	result.Metadata[key] = value
+}
+
+// callCloudAPI 调用云服务 API，直接转发参数，返回解析后的 JSON 响应
+func (p *CloudProvider) callCloudAPI(ctx context.Context, endpoint string, requestData interface{}) (map[string]interface{}, error) {
 	// 构建完整的 URL
 	baseURL := strings.TrimSuffix(p.endpointURL, "/")
 	var url string
@@ -172,13 +279,13 @@ func (p *CloudProvider) callCloudAPI(ctx context.Context, endpoint string, reque
 	// 序列化请求数据
 	requestBody, err := json.Marshal(requestData)
 	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
 	// 创建 HTTP 请求
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// 设置请求头
@@ -192,47 +299,27 @@ func (p *CloudProvider) callCloudAPI(ctx context.Context, endpoint string, reque
 	// 发送请求
 	resp, err := p.httpClient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// 检查 HTTP 状态码
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("cloud API returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("cloud API returned status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	// 读取响应
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	// 解析响应
 	var response map[string]interface{}
 	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
+		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	// 根据端点类型提取结果
-	switch endpoint {
-	case "enhancePrompt":
-		// 增强提示词返回文本
-		if text, ok := response["text"].(string); ok {
-			return text, nil
-		}
-		if prompt, ok := response["prompt"].(string); ok {
-			return prompt, nil
-		}
-		return "", fmt.Errorf("invalid response format: expected 'text' or 'prompt' field")
-	default:
-		// 图像操作返回图像数据（data URI 格式）
-		if imageData, ok := response["image"].(string); ok {
-			return imageData, nil
-		}
-		if imageData, ok := response["imageData"].(string); ok {
-			return imageData, nil
-		}
-		return "", fmt.Errorf("invalid response format: expected 'image' or 'imageData' field")
-	}
+	return response, nil
 }
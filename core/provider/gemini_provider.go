@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"math"
+	"net/http"
 	"strings"
 	"time"
 
@@ -17,11 +19,17 @@ import (
 
 // geminiCapabilities Gemini 提供商的功能支持矩阵
 var geminiCapabilities = ProviderCapabilities{
-	GenerateImage:    true,
-	EditImage:        true,
-	EnhancePrompt:    true,
-	RemoveBackground: true,
-	ReferenceImage:   true,
+	GenerateImage:         true,
+	EditImage:             true,
+	EnhancePrompt:         true,
+	RemoveBackground:      true,
+	TransparentOutput:     false, // 图像模型只返回不透明图像
+	Inpaint:               true,  // 以附加遮罩图像 + 提示词说明的方式实现
+	ReferenceImage:        true,
+	Seed:                  true,
+	NegativePrompt:        true, // 以提示词附加说明的方式实现
+	SupportedSizes:        []string{"1K", "2K", "4K"},
+	SupportedAspectRatios: []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"},
 }
 
 // ==================== GeminiProvider 实现 ====================
@@ -85,6 +93,7 @@ func createGeminiClient(ctx context.Context, settings types.AISettings) (*genai.
 		}
 
 		// 创建 Vertex AI 客户端
+		// 使用 genai 根据凭证创建的鉴权 HTTP 客户端，不应用连接配置（providerIdleConnTimeout 等）
 		client, err = genai.NewClient(ctx, &genai.ClientConfig{
 			Project:     settings.VertexProject,
 			Location:    settings.VertexLocation,
@@ -98,8 +107,9 @@ func createGeminiClient(ctx context.Context, settings types.AISettings) (*genai.
 		}
 
 		client, err = genai.NewClient(ctx, &genai.ClientConfig{
-			APIKey:  settings.APIKey,
-			Backend: genai.BackendGeminiAPI,
+			APIKey:     settings.APIKey,
+			Backend:    genai.BackendGeminiAPI,
+			HTTPClient: &http.Client{Transport: NewHTTPTransport(settings)},
 		})
 	}
 
@@ -165,21 +175,28 @@ func (p *GeminiProvider) Close() error {
 //   - aspectRatio: 宽高比，可选值："1:1", "16:9", "9:16", "3:4", "4:3"
 //
 // 注意：Gemini Provider 仅使用上述三个参数，忽略其他参数（如 ReferenceImage、SketchImage）
-func (p *GeminiProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (string, error) {
+func (p *GeminiProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
 	// 参数验证
 	if params.Prompt == "" {
-		return "", fmt.Errorf("prompt is required")
+		return nil, fmt.Errorf("prompt is required")
 	}
 	if params.ImageSize == "" {
-		return "", fmt.Errorf("imageSize is required")
+		return nil, fmt.Errorf("imageSize is required")
 	}
 	if params.AspectRatio == "" {
-		return "", fmt.Errorf("aspectRatio is required")
+		return nil, fmt.Errorf("aspectRatio is required")
 	}
 
-	// 构建内容部分（仅使用提示词）
+	model := resolveModel(params.Model, p.settings.ImageModel)
+
+	seed, err := geminiSeed(params.Seed)
+	if err != nil {
+		return nil, err
+	}
+
+	// 构建内容部分（仅使用提示词，反向提示词以附加说明的方式传递）
 	content := &genai.Content{
-		Parts: []*genai.Part{{Text: params.Prompt}},
+		Parts: []*genai.Part{{Text: applyNegativePrompt(params.Prompt, params.NegativePrompt)}},
 		Role:  genai.RoleUser,
 	}
 
@@ -188,11 +205,12 @@ func (p *GeminiProvider) GenerateImage(ctx context.Context, params types.Generat
 	topP := float32(0.95)
 
 	// 调用 Gemini API
-	response, err := p.client.Models.GenerateContent(ctx, p.settings.ImageModel,
+	response, err := p.client.Models.GenerateContent(ctx, model,
 		[]*genai.Content{content},
 		&genai.GenerateContentConfig{
 			Temperature:        &temperature,
 			TopP:               &topP,
+			Seed:               seed,
 			MaxOutputTokens:    32768,
 			ResponseModalities: []string{"text", "image"},
 			ImageConfig: &genai.ImageConfig{
@@ -202,10 +220,10 @@ func (p *GeminiProvider) GenerateImage(ctx context.Context, params types.Generat
 		})
 
 	if err != nil {
-		return "", fmt.Errorf("gemini API error: %w", err)
+		return nil, fmt.Errorf("gemini API error: %w", err)
 	}
 
-	return extractImageFromGeminiResponse(response)
+	return extractImageFromGeminiResponse(response, model)
 }
 
 // EditMultiImages 多图编辑/融合
@@ -215,26 +233,35 @@ func (p *GeminiProvider) GenerateImage(ctx context.Context, params types.Generat
 //   - prompt: 编辑提示词，描述要进行的编辑操作
 //   - imageSize: 图片尺寸，可选值："1K", "2K", "4K"（可选）
 //   - aspectRatio: 宽高比，可选值："1:1", "16:9", "9:16", "3:4", "4:3"（可选）
-func (p *GeminiProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (string, error) {
+func (p *GeminiProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
 	// 参数验证
 	if len(params.Images) < 1 {
-		return "", fmt.Errorf("at least 1 image is required")
+		return nil, fmt.Errorf("at least 1 image is required")
 	}
 	if params.Prompt == "" {
-		return "", fmt.Errorf("prompt is required")
+		return nil, fmt.Errorf("prompt is required")
+	}
+
+	seed, err := geminiSeed(params.Seed)
+	if err != nil {
+		return nil, err
 	}
 
 	// 构建请求部分：先添加提示词
 	parts := []*genai.Part{
-		{Text: params.Prompt},
+		{Text: applyMaskInstruction(applyNegativePrompt(params.Prompt, params.NegativePrompt), params.Mask)},
 	}
 
-	// 添加所有图片
-	for i, img := range params.Images {
+	// 添加所有图片（遮罩作为最后一张图片）
+	images := params.Images
+	if params.Mask != "" {
+		images = append(append([]string{}, params.Images...), params.Mask)
+	}
+	for i, img := range images {
 		imageData := extractBase64Data(img)
 		decodedData, err := base64.StdEncoding.DecodeString(imageData)
 		if err != nil {
-			return "", fmt.Errorf("failed to decode image %d: %w", i, err)
+			return nil, fmt.Errorf("failed to decode image %d: %w", i, err)
 		}
 
 		parts = append(parts, &genai.Part{
@@ -270,6 +297,7 @@ func (p *GeminiProvider) EditMultiImages(ctx context.Context, params types.Multi
 	config := &genai.GenerateContentConfig{
 		Temperature:        &temperature,
 		TopP:               &topP,
+		Seed:               seed,
 		MaxOutputTokens:    32768,
 		ResponseModalities: []string{"text", "image"},
 	}
@@ -279,19 +307,20 @@ func (p *GeminiProvider) EditMultiImages(ctx context.Context, params types.Multi
 		config.ImageConfig = imageConfig
 	}
 
-	response, err := p.client.Models.GenerateContent(ctx, p.settings.ImageModel,
+	model := resolveModel(params.Model, p.settings.ImageModel)
+	response, err := p.client.Models.GenerateContent(ctx, model,
 		[]*genai.Content{content},
 		config)
 
 	if err != nil {
-		return "", fmt.Errorf("Gemini multi-image edit API error: %w", err)
+		return nil, fmt.Errorf("Gemini multi-image edit API error: %w", err)
 	}
 
-	return extractImageFromGeminiResponse(response)
+	return extractImageFromGeminiResponse(response, model)
 }
 
 // EnhancePrompt 增强提示词
-func (p *GeminiProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (string, error) {
+func (p *GeminiProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
 	// 构建请求部分
 	parts := []*genai.Part{
 		{Text: params.Prompt},
@@ -302,7 +331,7 @@ func (p *GeminiProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 		imageData := extractBase64Data(img)
 		decodedData, err := base64.StdEncoding.DecodeString(imageData)
 		if err != nil {
-			return "", fmt.Errorf("failed to decode reference image %d: %w", i, err)
+			return nil, fmt.Errorf("failed to decode reference image %d: %w", i, err)
 		}
 
 		parts = append(parts, &genai.Part{
@@ -337,6 +366,7 @@ func (p *GeminiProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 			model = p.settings.TextModel // 回退到文本模型
 		}
 	}
+	model = resolveModel(params.Model, model)
 
 	// 构建系统指令内容
 	systemContent := &genai.Content{
@@ -353,23 +383,56 @@ func (p *GeminiProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 		})
 
 	if err != nil {
-		return "", fmt.Errorf("gemini prompt enhancement error: %w", err)
+		return nil, fmt.Errorf("gemini prompt enhancement error: %w", err)
+	}
+
+	result := &types.PromptResult{
+		Text:  params.Prompt, // 如果没有返回内容，返回原始提示词
+		Model: response.ModelVersion,
+		Usage: geminiUsage(response, 0),
+	}
+	if result.Model == "" {
+		result.Model = model
 	}
 
 	// 提取增强后的文本
 	if len(response.Candidates) > 0 && response.Candidates[0].Content != nil && len(response.Candidates[0].Content.Parts) > 0 {
 		enhancedText := response.Candidates[0].Content.Parts[0].Text
 		if enhancedText != "" {
-			return enhancedText, nil
+			result.Text = enhancedText
 		}
 	}
 
-	// 如果没有返回内容，返回原始提示词
-	return params.Prompt, nil
+	return result, nil
 }
 
 // ==================== 辅助函数 ====================
 
+// geminiSeed 将通用随机种子转换为 Gemini 配置格式
+// 0 表示随机（返回 nil，不设置种子）；Gemini 仅支持 int32 范围的种子
+func geminiSeed(seed int64) (*int32, error) {
+	if seed == 0 {
+		return nil, nil
+	}
+	if seed < math.MinInt32 || seed > math.MaxInt32 {
+		return nil, fmt.Errorf("seed %d is out of range for Gemini (must fit in int32)", seed)
+	}
+	value := int32(seed)
+	return &value, nil
+}
+
+// geminiUsage 从 Gemini 响应中提取用量信息
+// images 为本次调用生成的图像数量
+func geminiUsage(response *genai.GenerateContentResponse, images int) *types.Usage {
+	usage := &types.Usage{Images: images}
+	if response != nil && response.UsageMetadata != nil {
+		usage.InputTokens = int(response.UsageMetadata.PromptTokenCount)
+		usage.OutputTokens = int(response.UsageMetadata.CandidatesTokenCount)
+		usage.TotalTokens = int(response.UsageMetadata.TotalTokenCount)
+	}
+	return usage
+}
+
 // extractBase64Data 从 data URL 中提取 base64 数据
 func extractBase64Data(dataURL string) string {
 	parts := strings.Split(dataURL, ",")
@@ -380,9 +443,10 @@ func extractBase64Data(dataURL string) string {
 }
 
 // extractImageFromGeminiResponse 从 Gemini 响应中提取图像数据
-func extractImageFromGeminiResponse(response *genai.GenerateContentResponse) (string, error) {
+// requestedModel 用于响应未回传 ModelVersion 时作为结果中的模型名称
+func extractImageFromGeminiResponse(response *genai.GenerateContentResponse, requestedModel string) (*types.ImageResult, error) {
 	if response == nil || len(response.Candidates) == 0 {
-		return "", fmt.Errorf("no content generated")
+		return nil, fmt.Errorf("no content generated")
 	}
 
 	for _, candidate := range response.Candidates {
@@ -390,13 +454,55 @@ func extractImageFromGeminiResponse(response *genai.GenerateContentResponse) (st
 			continue
 		}
 
+		// 收集模型随图像返回的文本说明
+		var text strings.Builder
+		for _, part := range candidate.Content.Parts {
+			if part.Text != "" && !part.Thought {
+				text.WriteString(part.Text)
+			}
+		}
+
 		for _, part := range candidate.Content.Parts {
 			if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/") {
 				encoded := base64.StdEncoding.EncodeToString(part.InlineData.Data)
-				return fmt.Sprintf("data:%s;base64,%s", part.InlineData.MIMEType, encoded), nil
+				result := &types.ImageResult{
+					Image: fmt.Sprintf("data:%s;base64,%s", part.InlineData.MIMEType, encoded),
+					Model: response.ModelVersion,
+					Usage: geminiUsage(response, 1),
+				}
+				if result.Model == "" {
+					result.Model = requestedModel
+				}
+				if text.Len() > 0 {
+					setResultMetadata(result, "text", text.String())
+				}
+				if response.ResponseID != "" {
+					setResultMetadata(result, "responseId", response.ResponseID)
+				}
+				if candidate.FinishReason != "" {
+					setResultMetadata(result, "finishReason", string(candidate.FinishReason))
+				}
+				return result, nil
 			}
 		}
 	}
 
-	return "", fmt.Errorf("no image data found in response")
+	return nil, fmt.Errorf("no image data found in response")
+}
+
+// ListModels 列出可用的模型（实现 ModelLister 接口）
+// 返回去掉 "models/" 等资源前缀后的模型名称
+func (p *GeminiProvider) ListModels(ctx context.Context) ([]string, error) {
+	var models []string
+	for model, err := range p.client.Models.All(ctx) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list gemini models: %w", err)
+		}
+		name := model.Name
+		if idx := strings.LastIndex(name, "models/"); idx >= 0 {
+			name = name[idx+len("models/"):]
+		}
+		models = append(models, name)
+	}
+	return models, nil
 }
@@ -0,0 +1,49 @@
+package provider
+
+import (
+	"artifex/core/types"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 提供商 HTTP 连接默认配置（对应设置项 <= 0 时使用）
+const (
+	// DefaultProviderIdleConnTimeout 空闲连接保留时间（秒）
+	// 比 Go 默认的 90 秒短：网络不稳定时长时间闲置的 keep-alive 连接可能已被中间设备断开，
+	// 复用这样的连接会导致空闲后的第一次请求失败
+	DefaultProviderIdleConnTimeout = 30
+	// DefaultProviderMaxIdleConns 每个提供商保留的空闲连接数上限
+	DefaultProviderMaxIdleConns = 10
+	// DefaultProviderDialTimeout 建立 TCP 连接的超时时间（秒）
+	DefaultProviderDialTimeout = 30
+)
+
+// NewHTTPTransport 按设置中的连接配置创建提供商使用的 http.Transport
+// 每个提供商实例使用独立的连接池，配置变更后提供商重新创建时生效；
+// AIService 的连通性检测也使用它，保证检测与实际调用的连接方式一致
+func NewHTTPTransport(settings types.AISettings) *http.Transport {
+	idleTimeout := settings.ProviderIdleConnTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultProviderIdleConnTimeout
+	}
+	maxIdleConns := settings.ProviderMaxIdleConns
+	if maxIdleConns <= 0 {
+		maxIdleConns = DefaultProviderMaxIdleConns
+	}
+	dialTimeout := settings.ProviderDialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = DefaultProviderDialTimeout
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{
+		Timeout:   time.Duration(dialTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.IdleConnTimeout = time.Duration(idleTimeout) * time.Second
+	transport.MaxIdleConns = maxIdleConns
+	// 提供商的请求基本都发往同一主机，默认的每主机 2 个空闲连接在并发生成时不够用
+	transport.MaxIdleConnsPerHost = maxIdleConns
+	return transport
+}
@@ -0,0 +1,377 @@
+package provider
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== Local 能力声明 ====================
+
+// localCapabilities 本地 Stable Diffusion 提供商的功能支持矩阵
+var localCapabilities = ProviderCapabilities{
+	GenerateImage:         true,
+	EditImage:             true,  // img2img（单张输入图像）
+	EnhancePrompt:         false, // 无文本模型
+	RemoveBackground:      false,
+	TransparentOutput:     false,
+	Inpaint:               true, // img2img 原生支持 mask（白色为重绘区域）
+	ReferenceImage:        true, // 参考图像和草图都作为 img2img 的初始图像
+	Seed:                  true,
+	NegativePrompt:        true,
+	Preview:               true, // 轮询 /sdapi/v1/progress 获取实时预览（需要 WebUI 启用 Live previews）
+	SupportedSizes:        []string{"1K", "2K"},
+	SupportedAspectRatios: []string{"1:1", "16:9", "9:16", "3:4", "4:3"},
+}
+
+// 默认配置
+const (
+	defaultLocalEndpointURL = "http://127.0.0.1:7860"
+	defaultLocalSampler     = "Euler a"
+	defaultLocalSteps       = 30
+	// localEditDenoising img2img 默认重绘幅度（0-1，越大越偏离原图）
+	localEditDenoising = 0.6
+	// localSketchDenoising 草图生成的重绘幅度，草图只提供构图，需要较大幅度
+	localSketchDenoising = 0.8
+	// localPreviewPollInterval 请求预览时轮询生成进度的间隔
+	localPreviewPollInterval = time.Second
+)
+
+// ==================== LocalProvider 实现 ====================
+
+// LocalProvider 本地 Stable Diffusion 提供商
+// 调用 Automatic1111 WebUI 的 API（启动时需加 --api 参数）
+// - 文生图：POST /sdapi/v1/txt2img
+// - 图生图 / 局部重绘：POST /sdapi/v1/img2img
+type LocalProvider struct {
+	ctx         context.Context
+	endpointURL string
+	sampler     string
+	steps       int
+	httpClient  *http.Client
+}
+
+// NewLocalProvider 创建本地 Stable Diffusion 提供商实例
+func NewLocalProvider(ctx context.Context, settings types.AISettings) (*LocalProvider, error) {
+	endpointURL := strings.TrimSuffix(settings.LocalEndpointURL, "/")
+	if endpointURL == "" {
+		endpointURL = defaultLocalEndpointURL
+	}
+
+	sampler := strings.TrimSpace(settings.LocalSampler)
+	if sampler == "" {
+		sampler = defaultLocalSampler
+	}
+
+	steps := settings.LocalSteps
+	if steps <= 0 {
+		steps = defaultLocalSteps
+	}
+
+	return &LocalProvider{
+		ctx:         ctx,
+		endpointURL: endpointURL,
+		sampler:     sampler,
+		steps:       steps,
+		httpClient: &http.Client{
+			Transport: NewHTTPTransport(settings),
+			Timeout:   10 * time.Minute, // 本地显卡生成大尺寸图像可能非常慢
+		},
+	}, nil
+}
+
+// Name 返回提供商名称
+func (p *LocalProvider) Name() string {
+	return "local"
+}
+
+// GetCapabilities 返回提供商支持的功能
+func (p *LocalProvider) GetCapabilities() ProviderCapabilities {
+	return localCapabilities
+}
+
+// CheckAvailability 检测服务可用性
+// 查询采样器列表，验证 WebUI 已启动且开启了 API
+func (p *LocalProvider) CheckAvailability(ctx context.Context) (bool, error) {
+	testCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(testCtx, http.MethodGet, p.endpointURL+"/sdapi/v1/samplers", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("local service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("local service returned status %d (is the WebUI started with --api?)", resp.StatusCode)
+	}
+
+	return true, nil
+}
+
+// Close 清理资源
+func (p *LocalProvider) Close() error {
+	if p.httpClient != nil {
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// ==================== API 方法实现 ====================
+
+// localRequest txt2img / img2img 请求体（仅包含使用到的字段）
+type localRequest struct {
+	Prompt            string   `json:"prompt"`
+	NegativePrompt    string   `json:"negative_prompt,omitempty"`
+	Width             int      `json:"width"`
+	Height            int      `json:"height"`
+	Steps             int      `json:"steps"`
+	SamplerName       string   `json:"sampler_name"`
+	Seed              int64    `json:"seed"` // -1 表示随机
+	BatchSize         int      `json:"batch_size"`
+	InitImages        []string `json:"init_images,omitempty"`        // 仅 img2img
+	Mask              string   `json:"mask,omitempty"`               // 仅 img2img
+	DenoisingStrength float64  `json:"denoising_strength,omitempty"` // 仅 img2img
+	// 本次请求临时覆盖的 WebUI 设置（如 sd_model_checkpoint），请求结束后恢复
+	OverrideSettings map[string]interface{} `json:"override_settings,omitempty"`
+}
+
+// GenerateImage 生成图像
+// 提供草图或参考图像时使用 img2img，否则使用 txt2img
+func (p *LocalProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
+	request := p.newRequest(params.Prompt, params.NegativePrompt, params.ImageSize, params.AspectRatio, params.Seed)
+	if model := strings.TrimSpace(params.Model); model != "" {
+		// 模型覆盖对应 WebUI 的模型检查点名称
+		request.OverrideSettings = map[string]interface{}{"sd_model_checkpoint": model}
+	}
+
+	switch {
+	case params.SketchImage != "":
+		request.InitImages = []string{extractBase64Data(params.SketchImage)}
+		request.DenoisingStrength = localSketchDenoising
+		return p.callLocalAPI(ctx, "/sdapi/v1/img2img", request, params.Preview)
+	case params.ReferenceImage != "":
+		request.InitImages = []string{extractBase64Data(params.ReferenceImage)}
+		request.DenoisingStrength = localEditDenoising
+		return p.callLocalAPI(ctx, "/sdapi/v1/img2img", request, params.Preview)
+	default:
+		return p.callLocalAPI(ctx, "/sdapi/v1/txt2img", request, params.Preview)
+	}
+}
+
+// EditMultiImages 图像编辑
+// img2img 只接受单张初始图像；提供 mask 时进行局部重绘
+func (p *LocalProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
+	if len(params.Images) < 1 {
+		return nil, fmt.Errorf("at least 1 image is required")
+	}
+	if len(params.Images) > 1 {
+		return nil, fmt.Errorf("local provider supports editing a single image, got %d", len(params.Images))
+	}
+
+	request := p.newRequest(params.Prompt, params.NegativePrompt, params.ImageSize, params.AspectRatio, params.Seed)
+	request.InitImages = []string{extractBase64Data(params.Images[0])}
+	request.DenoisingStrength = localEditDenoising
+	if model := strings.TrimSpace(params.Model); model != "" {
+		request.OverrideSettings = map[string]interface{}{"sd_model_checkpoint": model}
+	}
+	if params.Mask != "" {
+		request.Mask = extractBase64Data(params.Mask)
+	}
+
+	return p.callLocalAPI(ctx, "/sdapi/v1/img2img", request, false)
+}
+
+// EnhancePrompt 增强提示词（本地 WebUI 不提供文本模型）
+func (p *LocalProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
+	return nil, fmt.Errorf("local provider does not support prompt enhancement")
+}
+
+// ==================== 辅助函数 ====================
+
+// newRequest 构建通用请求参数
+func (p *LocalProvider) newRequest(prompt, negativePrompt, imageSize, aspectRatio string, seed int64) *localRequest {
+	width, height := mapLocalImageSize(imageSize, aspectRatio)
+	if seed == 0 {
+		seed = -1
+	}
+	return &localRequest{
+		Prompt:         prompt,
+		NegativePrompt: strings.TrimSpace(negativePrompt),
+		Width:          width,
+		Height:         height,
+		Steps:          p.steps,
+		SamplerName:    p.sampler,
+		Seed:           seed,
+		BatchSize:      1,
+	}
+}
+
+// mapLocalImageSize 将尺寸档位和宽高比映射为像素宽高
+// 面积约等于边长的平方（1K 边长 1024，2K 边长 2048），宽高取 64 的倍数
+func mapLocalImageSize(sizeLevel, aspectRatio string) (int, int) {
+	side := 1024.0
+	if sizeLevel == "2K" {
+		side = 2048
+	}
+
+	ratioW, ratioH := 1.0, 1.0
+	if parts := strings.Split(aspectRatio, ":"); len(parts) == 2 {
+		w, errW := strconv.ParseFloat(parts[0], 64)
+		h, errH := strconv.ParseFloat(parts[1], 64)
+		if errW == nil && errH == nil && w > 0 && h > 0 {
+			ratioW, ratioH = w, h
+		}
+	}
+
+	width := side * math.Sqrt(ratioW/ratioH)
+	height := side * math.Sqrt(ratioH/ratioW)
+	roundTo64 := func(v float64) int {
+		return int(math.Max(64, math.Round(v/64)*64))
+	}
+	return roundTo64(width), roundTo64(height)
+}
+
+// localResponse txt2img / img2img 响应
+type localResponse struct {
+	Images []string `json:"images"` // base64 编码的 PNG 图像
+	Info   string   `json:"info"`   // JSON 字符串，包含实际使用的种子和模型
+}
+
+// localInfo 响应 info 字段中使用到的信息
+type localInfo struct {
+	Seed        int64  `json:"seed"`
+	SDModelName string `json:"sd_model_name"`
+}
+
+// callLocalAPI 调用本地 WebUI API 并解析图像结果
+// preview 为 true 时在生成期间轮询进度，通过进度回调上报第一张实时预览
+func (p *LocalProvider) callLocalAPI(ctx context.Context, endpoint string, request *localRequest, preview bool) (*types.ImageResult, error) {
+	if preview {
+		pollCtx, stopPolling := context.WithCancel(ctx)
+		defer stopPolling()
+		go p.pollPreview(pollCtx)
+	}
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpointURL+endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("local API returned status %d: %s", resp.StatusCode, truncateString(string(bodyBytes), 500))
+	}
+
+	var response localResponse
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if len(response.Images) == 0 || response.Images[0] == "" {
+		return nil, fmt.Errorf("invalid response format: missing 'images' field")
+	}
+
+	result := &types.ImageResult{
+		Image: "data:image/png;base64," + response.Images[0],
+		Usage: &types.Usage{Images: 1},
+	}
+
+	// info 解析失败不影响结果，仅缺少元数据
+	var info localInfo
+	if response.Info != "" && json.Unmarshal([]byte(response.Info), &info) == nil {
+		result.Model = info.SDModelName
+		if info.Seed > 0 {
+			setResultMetadata(result, "seed", strconv.FormatInt(info.Seed, 10))
+		}
+	}
+	setResultMetadata(result, "sampler", request.SamplerName)
+	return result, nil
+}
+
+// localProgress /sdapi/v1/progress 的响应（只解析需要的字段）
+type localProgress struct {
+	Progress     float64 `json:"progress"`
+	CurrentImage string  `json:"current_image"` // 实时预览图像（base64，未生成或未启用实时预览时为空）
+}
+
+// pollPreview 轮询生成进度，上报第一张实时预览后停止（ctx 取消时退出）
+// WebUI 未启用实时预览或查询失败时不会上报任何预览
+func (p *LocalProvider) pollPreview(ctx context.Context) {
+	ticker := time.NewTicker(localPreviewPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpointURL+"/sdapi/v1/progress?skip_current_image=false", nil)
+		if err != nil {
+			return
+		}
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			continue
+		}
+		var progress localProgress
+		decodeErr := json.NewDecoder(resp.Body).Decode(&progress)
+		resp.Body.Close()
+		if decodeErr != nil || resp.StatusCode != http.StatusOK || progress.CurrentImage == "" {
+			continue
+		}
+
+		if ctx.Err() == nil {
+			reportProgress(ctx, ProgressUpdate{
+				Stage:   "preview",
+				Preview: localPreviewDataURI(progress.CurrentImage),
+			})
+		}
+		return
+	}
+}
+
+// localPreviewDataURI 为实时预览图像添加 data URI 前缀
+// 预览格式由 WebUI 的 live_previews_image_format 设置决定（png、jpeg 或 webp）
+func localPreviewDataURI(preview string) string {
+	if strings.HasPrefix(preview, "data:") {
+		return preview
+	}
+	mimeType := "image/png"
+	switch {
+	case strings.HasPrefix(preview, "/9j/"):
+		mimeType = "image/jpeg"
+	case strings.HasPrefix(preview, "UklGR"):
+		mimeType = "image/webp"
+	}
+	return "data:" + mimeType + ";base64," + preview
+}
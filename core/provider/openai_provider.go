@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -15,20 +16,31 @@ import (
 
 // openaiImageAPICapabilities 使用专用 Image API 时的功能支持矩阵
 var openaiImageAPICapabilities = ProviderCapabilities{
-	GenerateImage:    true,
-	EditImage:        false, // DALL-E 3 不支持，GPT Image 1 需要单独配置
-	EnhancePrompt:    true,
-	RemoveBackground: false,
-	ReferenceImage:   false,
+	GenerateImage:     true,
+	EditImage:         false, // DALL-E 3 不支持，GPT Image 1 需要单独配置
+	EnhancePrompt:     true,
+	RemoveBackground:  false,
+	TransparentOutput: false, // 未传递 background=transparent，Image API 返回不透明图像
+	Inpaint:           false,
+	ReferenceImage:    false,
+	Seed:              false, // Image API 不支持随机种子
+	NegativePrompt:    true,  // 以提示词附加说明的方式实现
+	// DALL-E / GPT Image 1 仅输出约 1024 像素级别的图像，宽高比见 mapOpenAIImageSize
+	SupportedSizes:        []string{"1K"},
+	SupportedAspectRatios: []string{"1:1", "16:9", "4:3", "9:16", "3:4"},
 }
 
 // openaiChatCapabilities 使用 Chat API 时的功能支持矩阵（类似 Gemini）
 var openaiChatCapabilities = ProviderCapabilities{
-	GenerateImage:    true,
-	EditImage:        true,
-	EnhancePrompt:    true,
-	RemoveBackground: true,
-	ReferenceImage:   true,
+	GenerateImage:     true,
+	EditImage:         true,
+	EnhancePrompt:     true,
+	RemoveBackground:  true,
+	TransparentOutput: false, // 多模态模型通常只返回不透明图像
+	Inpaint:           true,  // 以附加遮罩图像 + 提示词说明的方式实现
+	ReferenceImage:    true,
+	Seed:              true, // 通过 Chat Completion 的 seed 参数传递（尽力复现）
+	NegativePrompt:    true, // 以提示词附加说明的方式实现
 }
 
 // ==================== OpenAIProvider 实现 ====================
@@ -42,7 +54,8 @@ type OpenAIProvider struct {
 	chatClient  *openai.Client // 用于 Chat/文本相关的 API
 	imageClient *openai.Client // 用于图像相关的 API
 	settings    types.AISettings
-	imageMode   string // 实际使用的图像模式
+	imageMode   string            // 实际使用的图像模式
+	rateLimits  *rateLimitTracker // 最近一次响应中的限额信息
 }
 
 // NewOpenAIProvider 创建 OpenAI 提供商实例
@@ -52,8 +65,13 @@ func NewOpenAIProvider(ctx context.Context, settings types.AISettings) (*OpenAIP
 		return nil, fmt.Errorf("OpenAI API key not configured")
 	}
 
+	// 两个客户端共用一个限额记录器，记录最近一次响应中的 x-ratelimit-* 响应头
+	rateLimits := newRateLimitTracker()
+	httpClient := &http.Client{Transport: rateLimits.transport(NewHTTPTransport(settings))}
+
 	// 创建 Chat 客户端（用于文本/聊天相关 API）
 	chatConfig := openai.DefaultConfig(apiKey)
+	chatConfig.HTTPClient = httpClient
 	if settings.OpenAIBaseURL != "" {
 		chatConfig.BaseURL = settings.OpenAIBaseURL
 	}
@@ -67,6 +85,7 @@ func NewOpenAIProvider(ctx context.Context, settings types.AISettings) (*OpenAIP
 	}
 
 	imageConfig := openai.DefaultConfig(imageAPIKey)
+	imageConfig.HTTPClient = httpClient
 	if settings.OpenAIImageBaseURL != "" {
 		// 使用独立的图像 API Base URL
 		imageConfig.BaseURL = settings.OpenAIImageBaseURL
@@ -85,6 +104,7 @@ func NewOpenAIProvider(ctx context.Context, settings types.AISettings) (*OpenAIP
 		imageClient: imageClient,
 		settings:    settings,
 		imageMode:   imageMode,
+		rateLimits:  rateLimits,
 	}, nil
 }
 
@@ -112,6 +132,36 @@ func determineImageMode(settings types.AISettings) string {
 	return types.OpenAIImageModeChat
 }
 
+// imageModelFor 返回本次图像调用使用的模型及对应的图像模式
+// 参数中提供了模型覆盖时，按覆盖的模型重新判断图像模式
+func (p *OpenAIProvider) imageModelFor(override string) (string, string) {
+	model := resolveModel(override, p.settings.OpenAIImageModel)
+	if model == p.settings.OpenAIImageModel {
+		return model, p.imageMode
+	}
+	settings := p.settings
+	settings.OpenAIImageModel = model
+	return model, determineImageMode(settings)
+}
+
+// ListModels 列出可用的模型（实现 ModelLister 接口）
+func (p *OpenAIProvider) ListModels(ctx context.Context) ([]string, error) {
+	list, err := p.chatClient.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list openai models: %w", err)
+	}
+	models := make([]string, 0, len(list.Models))
+	for _, model := range list.Models {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
+
+// RateLimits 返回最近一次响应中的限额信息（实现 RateLimitReporter 接口）
+func (p *OpenAIProvider) RateLimits() (RateLimitInfo, bool) {
+	return p.rateLimits.snapshot()
+}
+
 // Name 返回提供商名称
 func (p *OpenAIProvider) Name() string {
 	return "openai"
@@ -177,27 +227,27 @@ func (p *OpenAIProvider) Close() error {
 // ==================== 图像生成 ====================
 
 // GenerateImage 生成图像
-func (p *OpenAIProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (string, error) {
-	if p.imageMode == types.OpenAIImageModeChat {
+func (p *OpenAIProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
+	if _, mode := p.imageModelFor(params.Model); mode == types.OpenAIImageModeChat {
 		return p.generateImageViaChat(ctx, params)
 	}
 	return p.generateImageViaImageAPI(ctx, params)
 }
 
 // generateImageViaImageAPI 通过专用 Image API 生成图像
-func (p *OpenAIProvider) generateImageViaImageAPI(ctx context.Context, params types.GenerateImageParams) (string, error) {
+func (p *OpenAIProvider) generateImageViaImageAPI(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
 	// 映射图像尺寸
 	size := mapOpenAIImageSize(params.ImageSize, params.AspectRatio)
 
 	// 确定使用的模型
-	model := p.settings.OpenAIImageModel
+	model, _ := p.imageModelFor(params.Model)
 	if model == "" {
 		model = openai.CreateImageModelDallE3
 	}
 
 	// 构建请求
 	req := openai.ImageRequest{
-		Prompt:         params.Prompt,
+		Prompt:         applyNegativePrompt(params.Prompt, params.NegativePrompt),
 		Model:          model,
 		N:              1,
 		Size:           size,
@@ -209,32 +259,96 @@ func (p *OpenAIProvider) generateImageViaImageAPI(ctx context.Context, params ty
 	// 调用 Image API（使用 imageClient）
 	resp, err := p.imageClient.CreateImage(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI image generation error: %w", err)
+		return nil, fmt.Errorf("OpenAI image generation error: %w", err)
 	}
 
 	if len(resp.Data) == 0 {
-		return "", fmt.Errorf("no image data returned from OpenAI")
+		return nil, fmt.Errorf("no image data returned from OpenAI")
 	}
 
-	return "data:image/png;base64," + resp.Data[0].B64JSON, nil
+	return &types.ImageResult{
+		Image:         "data:image/png;base64," + resp.Data[0].B64JSON,
+		Model:         model,
+		RevisedPrompt: resp.Data[0].RevisedPrompt,
+		Usage:         openaiImageUsage(resp.Usage, 1),
+	}, nil
+}
+
+// GenerateImages 批量生成图像
+// Image API 模式下（DALL-E 3 除外，其仅支持 n=1）使用请求的 n 参数一次生成多张，
+// 其他情况依次调用 GenerateImage
+func (p *OpenAIProvider) GenerateImages(ctx context.Context, params types.GenerateImageParams, count int) ([]*types.ImageResult, error) {
+	model, mode := p.imageModelFor(params.Model)
+	if model == "" {
+		model = openai.CreateImageModelDallE3
+	}
+
+	if mode == types.OpenAIImageModeChat || model == openai.CreateImageModelDallE3 {
+		results := make([]*types.ImageResult, 0, count)
+		for i := 0; i < count; i++ {
+			variation := params
+			variation.Seed = VariationSeed(params.Seed, i)
+			result, err := p.GenerateImage(ctx, variation)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+		return results, nil
+	}
+
+	req := openai.ImageRequest{
+		Prompt:         applyNegativePrompt(params.Prompt, params.NegativePrompt),
+		Model:          model,
+		N:              count,
+		Size:           mapOpenAIImageSize(params.ImageSize, params.AspectRatio),
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+		Quality:        openai.CreateImageQualityHD,
+		Style:          openai.CreateImageStyleVivid,
+	}
+
+	resp, err := p.imageClient.CreateImage(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("OpenAI image generation error: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("no image data returned from OpenAI")
+	}
+
+	// 用量信息是整个批次的汇总，记录在第一张图像上
+	results := make([]*types.ImageResult, 0, len(resp.Data))
+	for i, item := range resp.Data {
+		result := &types.ImageResult{
+			Image:         "data:image/png;base64," + item.B64JSON,
+			Model:         model,
+			RevisedPrompt: item.RevisedPrompt,
+			Usage:         &types.Usage{Images: 1},
+		}
+		if i == 0 {
+			result.Usage = openaiImageUsage(resp.Usage, 1)
+		}
+		results = append(results, result)
+	}
+	return results, nil
 }
 
 // generateImageViaChat 通过 Chat Completion API 生成图像
-func (p *OpenAIProvider) generateImageViaChat(ctx context.Context, params types.GenerateImageParams) (string, error) {
+func (p *OpenAIProvider) generateImageViaChat(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
 	// 构建消息内容
 	var multiContent []openai.ChatMessagePart
 
 	// 添加文本提示
 	multiContent = append(multiContent, openai.ChatMessagePart{
 		Type: openai.ChatMessagePartTypeText,
-		Text: buildImageGenerationPrompt(params.Prompt, params.AspectRatio),
+		Text: buildImageGenerationPrompt(applyNegativePrompt(params.Prompt, params.NegativePrompt), params.AspectRatio),
 	})
 
 	// 如果有草图图像，添加到请求中
 	if params.SketchImage != "" {
 		imageURL, err := buildImageURL(params.SketchImage)
 		if err != nil {
-			return "", fmt.Errorf("failed to process sketch image: %w", err)
+			return nil, fmt.Errorf("failed to process sketch image: %w", err)
 		}
 		multiContent = append(multiContent, openai.ChatMessagePart{
 			Type: openai.ChatMessagePartTypeImageURL,
@@ -249,7 +363,7 @@ func (p *OpenAIProvider) generateImageViaChat(ctx context.Context, params types.
 	if params.ReferenceImage != "" {
 		imageURL, err := buildImageURL(params.ReferenceImage)
 		if err != nil {
-			return "", fmt.Errorf("failed to process reference image: %w", err)
+			return nil, fmt.Errorf("failed to process reference image: %w", err)
 		}
 		multiContent = append(multiContent, openai.ChatMessagePart{
 			Type: openai.ChatMessagePartTypeImageURL,
@@ -261,7 +375,7 @@ func (p *OpenAIProvider) generateImageViaChat(ctx context.Context, params types.
 	}
 
 	// 确定使用的模型
-	model := p.settings.OpenAIImageModel
+	model, _ := p.imageModelFor(params.Model)
 	if model == "" {
 		model = "gpt-4o" // 默认使用 GPT-4o
 	}
@@ -276,48 +390,33 @@ func (p *OpenAIProvider) generateImageViaChat(ctx context.Context, params types.
 			},
 		},
 		MaxTokens: 131072,
-	}
-
-	// 根据配置决定是否使用流式请求（图像模型流式模式）
-	if p.settings.OpenAIImageStream {
-		content, err := p.createChatCompletionStream(ctx, p.imageClient, req)
-		if err != nil {
-			return "", err
-		}
-		// 从流式响应中提取图像
-		return extractImageFromChatContent(content)
+		Seed:      openaiSeed(params.Seed),
 	}
 
 	// 调用图像 API（使用 imageClient，因为这是图像生成操作）
-	resp, err := p.imageClient.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return "", fmt.Errorf("OpenAI chat completion error: %w", err)
-	}
-
-	// 从响应中提取图像
-	return extractImageFromChatResponse(resp)
+	return p.createImageChatCompletion(ctx, req)
 }
 
 // ==================== 图像编辑 ====================
 
 // EditMultiImages 多图编辑/融合
 // 支持单图或多图编辑（单图时也使用此方法）
-func (p *OpenAIProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (string, error) {
+func (p *OpenAIProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
 	if len(params.Images) < 1 {
-		return "", fmt.Errorf("at least 1 image is required")
+		return nil, fmt.Errorf("at least 1 image is required")
 	}
 
-	if p.imageMode == types.OpenAIImageModeChat {
+	if _, mode := p.imageModelFor(params.Model); mode == types.OpenAIImageModeChat {
 		return p.editMultiImagesViaChat(ctx, params)
 	}
-	return "", fmt.Errorf("multi-image editing is only supported in 'chat' mode. Please set openaiImageMode to 'chat'")
+	return nil, fmt.Errorf("multi-image editing is only supported in 'chat' mode. Please set openaiImageMode to 'chat'")
 }
 
 // editMultiImagesViaChat 通过 Chat Completion API 进行多图编辑
 // 支持单图或多图编辑
-func (p *OpenAIProvider) editMultiImagesViaChat(ctx context.Context, params types.MultiImageEditParams) (string, error) {
+func (p *OpenAIProvider) editMultiImagesViaChat(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
 	if len(params.Images) < 1 {
-		return "", fmt.Errorf("at least 1 image is required")
+		return nil, fmt.Errorf("at least 1 image is required")
 	}
 
 	// 构建消息内容
@@ -326,14 +425,18 @@ func (p *OpenAIProvider) editMultiImagesViaChat(ctx context.Context, params type
 	// 添加提示词
 	multiContent = append(multiContent, openai.ChatMessagePart{
 		Type: openai.ChatMessagePartTypeText,
-		Text: params.Prompt,
+		Text: applyMaskInstruction(applyNegativePrompt(params.Prompt, params.NegativePrompt), params.Mask),
 	})
 
-	// 添加所有图片
-	for i, img := range params.Images {
+	// 添加所有图片（遮罩作为最后一张图片）
+	images := params.Images
+	if params.Mask != "" {
+		images = append(append([]string{}, params.Images...), params.Mask)
+	}
+	for i, img := range images {
 		imageURL, err := buildImageURL(img)
 		if err != nil {
-			return "", fmt.Errorf("failed to process image %d: %w", i, err)
+			return nil, fmt.Errorf("failed to process image %d: %w", i, err)
 		}
 		multiContent = append(multiContent, openai.ChatMessagePart{
 			Type: openai.ChatMessagePartTypeImageURL,
@@ -345,7 +448,7 @@ func (p *OpenAIProvider) editMultiImagesViaChat(ctx context.Context, params type
 	}
 
 	// 确定使用的模型
-	model := p.settings.OpenAIImageModel
+	model, _ := p.imageModelFor(params.Model)
 	if model == "" {
 		model = "gpt-4o"
 	}
@@ -360,31 +463,53 @@ func (p *OpenAIProvider) editMultiImagesViaChat(ctx context.Context, params type
 			},
 		},
 		MaxTokens: 4096,
+		Seed:      openaiSeed(params.Seed),
 	}
 
-	// 根据配置决定是否使用流式请求（图像模型流式模式）
+	// 调用图像 API（使用 imageClient，因为这是多图编辑操作）
+	return p.createImageChatCompletion(ctx, req)
+}
+
+// createImageChatCompletion 通过 imageClient 发送 Chat Completion 请求并提取图像结果
+// 根据配置决定是否使用流式请求（图像模型流式模式）
+func (p *OpenAIProvider) createImageChatCompletion(ctx context.Context, req openai.ChatCompletionRequest) (*types.ImageResult, error) {
 	if p.settings.OpenAIImageStream {
 		content, err := p.createChatCompletionStream(ctx, p.imageClient, req)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 		// 从流式响应中提取图像
-		return extractImageFromChatContent(content)
+		image, err := extractImageFromChatContent(content)
+		if err != nil {
+			return nil, err
+		}
+		return &types.ImageResult{Image: image, Model: req.Model, Usage: &types.Usage{Images: 1}}, nil
 	}
 
-	// 调用图像 API（使用 imageClient，因为这是多图编辑操作）
 	resp, err := p.imageClient.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI chat completion error: %w", err)
+		return nil, fmt.Errorf("OpenAI chat completion error: %w", err)
 	}
 
-	return extractImageFromChatResponse(resp)
+	image, err := extractImageFromChatResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.ImageResult{Image: image, Model: resp.Model, Usage: openaiChatUsage(resp.Usage, 1)}
+	if result.Model == "" {
+		result.Model = req.Model
+	}
+	if resp.ID != "" {
+		setResultMetadata(result, "responseId", resp.ID)
+	}
+	return result, nil
 }
 
 // ==================== 提示词增强 ====================
 
 // EnhancePrompt 增强提示词
-func (p *OpenAIProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (string, error) {
+func (p *OpenAIProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
 	// 确定使用的模型
 	model := p.settings.OpenAITextModel
 	if model == "" {
@@ -400,6 +525,7 @@ func (p *OpenAIProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 			model = "gpt-4o" // 默认使用支持视觉的模型
 		}
 	}
+	model = resolveModel(params.Model, model)
 
 	// 构建消息内容
 	var multiContent []openai.ChatMessagePart
@@ -414,7 +540,7 @@ func (p *OpenAIProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 	for i, img := range params.ReferenceImages {
 		imageURL, err := buildImageURL(img)
 		if err != nil {
-			return "", fmt.Errorf("failed to process reference image %d: %w", i, err)
+			return nil, fmt.Errorf("failed to process reference image %d: %w", i, err)
 		}
 		multiContent = append(multiContent, openai.ChatMessagePart{
 			Type: openai.ChatMessagePartTypeImageURL,
@@ -450,25 +576,33 @@ func (p *OpenAIProvider) EnhancePrompt(ctx context.Context, params types.Enhance
 
 	// 根据配置决定是否使用流式请求
 	if p.settings.OpenAITextStream {
-		return p.createChatCompletionStream(ctx, p.chatClient, req)
+		content, err := p.createChatCompletionStream(ctx, p.chatClient, req)
+		if err != nil {
+			return nil, err
+		}
+		return &types.PromptResult{Text: content, Model: model}, nil
 	}
 
 	// 调用 Chat API（使用 chatClient，因为这是文本处理操作）
 	resp, err := p.chatClient.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI chat API error: %w", err)
+		return nil, fmt.Errorf("OpenAI chat API error: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
-		return params.Prompt, nil
+	result := &types.PromptResult{
+		Text:  params.Prompt,
+		Model: resp.Model,
+		Usage: openaiChatUsage(resp.Usage, 0),
+	}
+	if result.Model == "" {
+		result.Model = model
 	}
 
-	enhancedPrompt := resp.Choices[0].Message.Content
-	if enhancedPrompt == "" {
-		return params.Prompt, nil
+	if len(resp.Choices) > 0 && resp.Choices[0].Message.Content != "" {
+		result.Text = resp.Choices[0].Message.Content
 	}
 
-	return enhancedPrompt, nil
+	return result, nil
 }
 
 // ==================== 辅助函数 ====================
@@ -488,6 +622,36 @@ func mapOpenAIImageSize(sizeLevel, aspectRatio string) string {
 	}
 }
 
+// openaiSeed 将通用随机种子转换为 Chat Completion 的 seed 参数
+// 0 表示随机（返回 nil，不设置种子）
+func openaiSeed(seed int64) *int {
+	if seed == 0 {
+		return nil
+	}
+	value := int(seed)
+	return &value
+}
+
+// openaiChatUsage 将 Chat Completion 用量转换为通用用量信息
+func openaiChatUsage(usage openai.Usage, images int) *types.Usage {
+	return &types.Usage{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+		TotalTokens:  usage.TotalTokens,
+		Images:       images,
+	}
+}
+
+// openaiImageUsage 将 Image API 用量转换为通用用量信息
+func openaiImageUsage(usage openai.ImageResponseUsage, images int) *types.Usage {
+	return &types.Usage{
+		InputTokens:  usage.InputTokens,
+		OutputTokens: usage.OutputTokens,
+		TotalTokens:  usage.TotalTokens,
+		Images:       images,
+	}
+}
+
 // buildImageURL 构建图像 URL（支持 base64 和 http URL）
 func buildImageURL(imageData string) (string, error) {
 	// 如果已经是 data URL，直接返回
@@ -614,6 +778,10 @@ func (p *OpenAIProvider) createChatCompletionStream(ctx context.Context, client
 			delta := response.Choices[0].Delta.Content
 			if delta != "" {
 				fullContent.WriteString(delta)
+				reportProgress(ctx, ProgressUpdate{
+					Stage:         "streaming",
+					BytesReceived: fullContent.Len(),
+				})
 			}
 		}
 	}
@@ -0,0 +1,36 @@
+package provider
+
+import "context"
+
+// ==================== 进度上报 ====================
+
+// ProgressUpdate 提供商调用过程中的进度信息
+type ProgressUpdate struct {
+	// Stage 当前阶段，如 "streaming"
+	Stage string
+	// BytesReceived 已接收的响应字节数（流式模式下）
+	BytesReceived int
+	// Preview 中间预览图像（data URI，可选）
+	Preview string
+}
+
+// ProgressReporter 接收提供商进度通知的回调函数
+type ProgressReporter func(update ProgressUpdate)
+
+// progressReporterKey context 中存放 ProgressReporter 的键
+type progressReporterKey struct{}
+
+// WithProgressReporter 返回携带进度回调的 context
+// 提供商在流式接收等长耗时过程中通过该回调上报进度，不支持进度的提供商直接忽略
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// reportProgress 向 context 中的进度回调上报进度（未设置回调时不做任何处理）
+func reportProgress(ctx context.Context, update ProgressUpdate) {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	reporter(update)
+}
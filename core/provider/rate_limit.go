@@ -0,0 +1,117 @@
+package provider
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitInfo 提供商最近一次响应中返回的限额信息
+// 各字段仅在响应头中出现时才有值，为空表示提供商未返回该项
+type RateLimitInfo struct {
+	LimitRequests     *int64    `json:"limitRequests,omitempty"`     // 周期内允许的请求数
+	RemainingRequests *int64    `json:"remainingRequests,omitempty"` // 周期内剩余的请求数
+	ResetRequests     string    `json:"resetRequests,omitempty"`     // 请求数限额的重置时间（提供商原始格式，如 "1s"、"6m0s"）
+	LimitTokens       *int64    `json:"limitTokens,omitempty"`       // 周期内允许的 token 数
+	RemainingTokens   *int64    `json:"remainingTokens,omitempty"`   // 周期内剩余的 token 数
+	ResetTokens       string    `json:"resetTokens,omitempty"`       // token 限额的重置时间（提供商原始格式）
+	RetryAfter        string    `json:"retryAfter,omitempty"`        // 被限流（429）时提供商要求的等待时间
+	ObservedAt        time.Time `json:"observedAt"`                  // 收到该响应的时间
+}
+
+// RateLimitReporter 可选接口：返回提供商最近一次观察到的限额信息
+// 未实现或尚未收到带限额响应头的响应时，AIService 报告限额未知
+type RateLimitReporter interface {
+	// RateLimits 返回最近一次观察到的限额信息，尚无信息时第二个返回值为 false
+	RateLimits() (RateLimitInfo, bool)
+}
+
+// rateLimitTracker 记录响应头中的限额信息
+// 支持 OpenAI 风格的 x-ratelimit-{limit,remaining,reset}-{requests,tokens}、
+// 通用的 x-ratelimit-{limit,remaining,reset} 以及 Retry-After
+type rateLimitTracker struct {
+	mu   sync.Mutex
+	info RateLimitInfo
+	seen bool
+}
+
+// newRateLimitTracker 创建限额记录器
+func newRateLimitTracker() *rateLimitTracker {
+	return &rateLimitTracker{}
+}
+
+// snapshot 返回最近一次记录的限额信息
+func (t *rateLimitTracker) snapshot() (RateLimitInfo, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.info, t.seen
+}
+
+// record 从响应头中提取限额信息，响应头不含任何限额字段时保留上次的记录
+func (t *rateLimitTracker) record(header http.Header) {
+	info := RateLimitInfo{
+		LimitRequests:     headerInt(header, "X-Ratelimit-Limit-Requests", "X-Ratelimit-Limit"),
+		RemainingRequests: headerInt(header, "X-Ratelimit-Remaining-Requests", "X-Ratelimit-Remaining"),
+		ResetRequests:     headerValue(header, "X-Ratelimit-Reset-Requests", "X-Ratelimit-Reset"),
+		LimitTokens:       headerInt(header, "X-Ratelimit-Limit-Tokens"),
+		RemainingTokens:   headerInt(header, "X-Ratelimit-Remaining-Tokens"),
+		ResetTokens:       headerValue(header, "X-Ratelimit-Reset-Tokens"),
+		RetryAfter:        headerValue(header, "Retry-After"),
+	}
+	if info.LimitRequests == nil && info.RemainingRequests == nil && info.ResetRequests == "" &&
+		info.LimitTokens == nil && info.RemainingTokens == nil && info.ResetTokens == "" && info.RetryAfter == "" {
+		return
+	}
+	info.ObservedAt = time.Now()
+
+	t.mu.Lock()
+	t.info = info
+	t.seen = true
+	t.mu.Unlock()
+}
+
+// transport 返回记录限额信息的 http.RoundTripper（base 为 nil 时使用 http.DefaultTransport）
+func (t *rateLimitTracker) transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{base: base, tracker: t}
+}
+
+// rateLimitTransport 在转发请求的同时记录响应头中的限额信息
+type rateLimitTransport struct {
+	base    http.RoundTripper
+	tracker *rateLimitTracker
+}
+
+// RoundTrip 实现 http.RoundTripper 接口
+func (rt *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := rt.base.RoundTrip(req)
+	if err == nil && resp != nil {
+		rt.tracker.record(resp.Header)
+	}
+	return resp, err
+}
+
+// headerValue 返回第一个非空的响应头值
+func headerValue(header http.Header, names ...string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(header.Get(name)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// headerInt 返回第一个可解析为整数的响应头值，均不存在时返回 nil
+func headerInt(header http.Header, names ...string) *int64 {
+	for _, name := range names {
+		value, err := strconv.ParseInt(strings.TrimSpace(header.Get(name)), 10, 64)
+		if err == nil {
+			return &value
+		}
+	}
+	return nil
+}
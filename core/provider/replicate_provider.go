@@ -0,0 +1,341 @@
+package provider
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ==================== Replicate 能力声明 ====================
+
+// replicateCapabilities Replicate 提供商的功能支持矩阵
+// 实际可用的输入取决于所选模型，这里按 FLUX 系列模型的输入约定声明
+var replicateCapabilities = ProviderCapabilities{
+	GenerateImage:         true,
+	EditImage:             true,  // 单图编辑（input_image，如 flux-kontext 系列）
+	EnhancePrompt:         false, // 无文本模型
+	RemoveBackground:      false,
+	TransparentOutput:     false,
+	Inpaint:               false,
+	ReferenceImage:        true, // 参考图像作为 input_image 传递
+	Seed:                  true,
+	NegativePrompt:        false, // FLUX 系列不支持反向提示词
+	SupportedSizes:        []string{"1K"},
+	SupportedAspectRatios: []string{"1:1", "16:9", "9:16", "3:4", "4:3"},
+}
+
+// 默认配置
+const (
+	replicateBaseURL      = "https://api.replicate.com/v1"
+	defaultReplicateModel = "black-forest-labs/flux-schnell"
+	// replicatePollInterval 轮询预测状态的间隔
+	replicatePollInterval = time.Second
+)
+
+// ==================== ReplicateProvider 实现 ====================
+
+// ReplicateProvider Replicate 提供商
+// Replicate 的预测是异步执行的：先创建预测，再轮询状态直到完成
+// 结果为图像 URL（约一小时后过期），由 AIService 通过 SaveImageFromURL 立即保存到本地
+type ReplicateProvider struct {
+	ctx        context.Context
+	apiToken   string
+	model      string
+	httpClient *http.Client
+	rateLimits *rateLimitTracker // 最近一次响应中的限额信息
+}
+
+// NewReplicateProvider 创建 Replicate 提供商实例
+func NewReplicateProvider(ctx context.Context, settings types.AISettings) (*ReplicateProvider, error) {
+	if settings.ReplicateAPIToken == "" {
+		return nil, fmt.Errorf("replicate API token not configured")
+	}
+
+	model := strings.TrimSpace(settings.ReplicateModel)
+	if model == "" {
+		model = defaultReplicateModel
+	}
+
+	rateLimits := newRateLimitTracker()
+	return &ReplicateProvider{
+		ctx:      ctx,
+		apiToken: settings.ReplicateAPIToken,
+		model:    model,
+		httpClient: &http.Client{
+			Transport: rateLimits.transport(NewHTTPTransport(settings)),
+			Timeout:   60 * time.Second, // 单次请求超时，整体等待时间由调用方的 ctx 控制
+		},
+		rateLimits: rateLimits,
+	}, nil
+}
+
+// RateLimits 返回最近一次响应中的限额信息（实现 RateLimitReporter 接口）
+func (p *ReplicateProvider) RateLimits() (RateLimitInfo, bool) {
+	return p.rateLimits.snapshot()
+}
+
+// Name 返回提供商名称
+func (p *ReplicateProvider) Name() string {
+	return "replicate"
+}
+
+// GetCapabilities 返回提供商支持的功能
+func (p *ReplicateProvider) GetCapabilities() ProviderCapabilities {
+	return replicateCapabilities
+}
+
+// CheckAvailability 检测服务可用性
+// 查询账户信息接口，验证 API Token 有效且不消耗额度
+func (p *ReplicateProvider) CheckAvailability(ctx context.Context) (bool, error) {
+	testCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := p.doRequest(testCtx, http.MethodGet, replicateBaseURL+"/account", nil); err != nil {
+		return false, fmt.Errorf("replicate service unavailable: %w", err)
+	}
+	return true, nil
+}
+
+// Close 清理资源
+func (p *ReplicateProvider) Close() error {
+	if p.httpClient != nil {
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// ==================== API 方法实现 ====================
+
+// GenerateImage 生成图像
+// 提供参考图像（或草图）时作为 input_image 传递
+func (p *ReplicateProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
+	input := map[string]interface{}{
+		"prompt":        params.Prompt,
+		"output_format": "png",
+	}
+	if params.AspectRatio != "" {
+		input["aspect_ratio"] = params.AspectRatio
+	}
+	if params.Seed != 0 {
+		input["seed"] = params.Seed
+	}
+
+	referenceImage := params.ReferenceImage
+	if referenceImage == "" {
+		referenceImage = params.SketchImage
+	}
+	if referenceImage != "" {
+		imageURL, err := buildImageURL(referenceImage)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build image URL: %w", err)
+		}
+		input["input_image"] = imageURL
+	}
+
+	return p.runPrediction(ctx, resolveModel(params.Model, p.model), input)
+}
+
+// EditMultiImages 图像编辑
+// 输入图像作为 input_image 传递，仅支持单张图像
+func (p *ReplicateProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
+	if len(params.Images) < 1 {
+		return nil, fmt.Errorf("at least 1 image is required")
+	}
+	if len(params.Images) > 1 {
+		return nil, fmt.Errorf("replicate supports editing a single image, got %d", len(params.Images))
+	}
+
+	imageURL, err := buildImageURL(params.Images[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to build image URL: %w", err)
+	}
+
+	input := map[string]interface{}{
+		"prompt":        params.Prompt,
+		"input_image":   imageURL,
+		"output_format": "png",
+	}
+	if params.AspectRatio != "" {
+		input["aspect_ratio"] = params.AspectRatio
+	}
+	if params.Seed != 0 {
+		input["seed"] = params.Seed
+	}
+
+	return p.runPrediction(ctx, resolveModel(params.Model, p.model), input)
+}
+
+// EnhancePrompt 增强提示词（图像模型不支持）
+func (p *ReplicateProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
+	return nil, fmt.Errorf("replicate provider does not support prompt enhancement")
+}
+
+// ==================== 预测与轮询 ====================
+
+// replicatePrediction 预测对象（仅包含使用到的字段）
+type replicatePrediction struct {
+	ID     string          `json:"id"`
+	Status string          `json:"status"` // starting、processing、succeeded、failed、canceled
+	Output json.RawMessage `json:"output"` // 字符串或字符串数组
+	Error  interface{}     `json:"error"`
+	URLs   struct {
+		Get    string `json:"get"`
+		Cancel string `json:"cancel"`
+	} `json:"urls"`
+}
+
+// runPrediction 使用指定模型创建预测并轮询至完成
+// ctx 被取消时尝试取消远端预测，并返回 ctx 的错误
+func (p *ReplicateProvider) runPrediction(ctx context.Context, model string, input map[string]interface{}) (*types.ImageResult, error) {
+	// "owner/name" 使用模型的最新版本；"owner/name:version" 使用指定版本
+	var endpoint string
+	body := map[string]interface{}{"input": input}
+	if _, version, ok := strings.Cut(model, ":"); ok {
+		endpoint = replicateBaseURL + "/predictions"
+		body["version"] = version
+	} else {
+		endpoint = replicateBaseURL + "/models/" + model + "/predictions"
+	}
+
+	prediction, err := p.createPrediction(ctx, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(replicatePollInterval)
+	defer ticker.Stop()
+
+	for !isReplicateTerminal(prediction.Status) {
+		select {
+		case <-ctx.Done():
+			p.cancelPrediction(prediction)
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		if prediction.URLs.Get == "" {
+			return nil, fmt.Errorf("invalid response format: missing prediction status URL")
+		}
+		data, err := p.doRequest(ctx, http.MethodGet, prediction.URLs.Get, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				p.cancelPrediction(prediction)
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to poll prediction: %w", err)
+		}
+		if err := json.Unmarshal(data, prediction); err != nil {
+			return nil, fmt.Errorf("failed to parse prediction: %w", err)
+		}
+	}
+
+	switch prediction.Status {
+	case "succeeded":
+	case "canceled":
+		return nil, fmt.Errorf("replicate prediction %s was canceled", prediction.ID)
+	default:
+		return nil, fmt.Errorf("replicate prediction %s failed: %v", prediction.ID, prediction.Error)
+	}
+
+	imageURL, err := replicateOutputURL(prediction.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.ImageResult{
+		Image: imageURL,
+		Model: model,
+		Usage: &types.Usage{Images: 1},
+	}
+	setResultMetadata(result, "predictionId", prediction.ID)
+	return result, nil
+}
+
+// createPrediction 创建预测
+func (p *ReplicateProvider) createPrediction(ctx context.Context, endpoint string, body map[string]interface{}) (*replicatePrediction, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	data, err := p.doRequest(ctx, http.MethodPost, endpoint, jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prediction: %w", err)
+	}
+
+	var prediction replicatePrediction
+	if err := json.Unmarshal(data, &prediction); err != nil {
+		return nil, fmt.Errorf("failed to parse prediction: %w", err)
+	}
+	return &prediction, nil
+}
+
+// cancelPrediction 尽力取消远端预测，避免继续计费（使用独立的短超时上下文）
+func (p *ReplicateProvider) cancelPrediction(prediction *replicatePrediction) {
+	if prediction.URLs.Cancel == "" {
+		return
+	}
+	cancelCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := p.doRequest(cancelCtx, http.MethodPost, prediction.URLs.Cancel, nil); err != nil {
+		fmt.Printf("[ReplicateProvider] Warning: failed to cancel prediction %s: %v\n", prediction.ID, err)
+	}
+}
+
+// doRequest 发送带认证的请求并返回响应体，非 2xx 状态码视为错误
+func (p *ReplicateProvider) doRequest(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("replicate API returned status %d: %s", resp.StatusCode, truncateString(string(data), 500))
+	}
+	return data, nil
+}
+
+// isReplicateTerminal 判断预测是否已结束
+func isReplicateTerminal(status string) bool {
+	return status == "succeeded" || status == "failed" || status == "canceled"
+}
+
+// replicateOutputURL 从预测输出中提取第一张图像的 URL（输出可能是字符串或字符串数组）
+func replicateOutputURL(output json.RawMessage) (string, error) {
+	var single string
+	if err := json.Unmarshal(output, &single); err == nil && single != "" {
+		return single, nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(output, &list); err == nil && len(list) > 0 && list[0] != "" {
+		return list[0], nil
+	}
+
+	return "", fmt.Errorf("invalid response format: prediction output contains no image URL")
+}
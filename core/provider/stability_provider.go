@@ -0,0 +1,355 @@
+package provider
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ==================== Stability 能力声明 ====================
+
+// stabilityAspectRatios Stability API 原生支持的宽高比
+var stabilityAspectRatios = []string{"1:1", "16:9", "9:16", "21:9", "9:21", "2:3", "3:2", "4:5", "5:4"}
+
+// stabilityCapabilities Stability 提供商的功能支持矩阵
+var stabilityCapabilities = ProviderCapabilities{
+	GenerateImage:     true,
+	EditImage:         true,  // 单图 image-to-image（SD3 端点）
+	EnhancePrompt:     false, // 无文本模型
+	RemoveBackground:  false,
+	TransparentOutput: false,
+	Inpaint:           true, // edit/inpaint 端点原生支持 mask
+	ReferenceImage:    true, // 参考图像走 image-to-image，草图走 control/sketch
+	Seed:              true,
+	NegativePrompt:    true,
+	SupportedSizes:    []string{"1K"}, // 输出分辨率由模型决定（约 1 百万像素）
+	// 应用常用的 3:4、4:3 映射为最接近的 4:5、5:4
+	SupportedAspectRatios: append(append([]string{}, stabilityAspectRatios...), "3:4", "4:3"),
+}
+
+// 默认配置
+const (
+	defaultStabilityBaseURL = "https://api.stability.ai"
+	defaultStabilityModel   = "core"
+	// defaultStabilityEditModel 编辑使用的默认 SD3 模型（core/ultra 不支持 image-to-image）
+	defaultStabilityEditModel = "sd3.5-large"
+	// stabilityEditStrength image-to-image 的默认重绘强度（0-1，越大越偏离原图）
+	stabilityEditStrength = "0.7"
+	// stabilitySketchStrength 草图控制强度（0-1，越大越贴合草图）
+	stabilitySketchStrength = "0.7"
+)
+
+// ==================== StabilityProvider 实现 ====================
+
+// StabilityProvider Stability AI 提供商
+// 调用 Stability REST API（v2beta stable-image），请求为 multipart/form-data
+// - 文生图：generate/core、generate/ultra 或 generate/sd3（由 StabilityModel 决定）
+// - 图生图：generate/sd3（mode=image-to-image）
+// - 局部重绘：edit/inpaint
+// - 草图生成：control/sketch
+type StabilityProvider struct {
+	ctx        context.Context
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	rateLimits *rateLimitTracker // 最近一次响应中的限额信息
+}
+
+// NewStabilityProvider 创建 Stability 提供商实例
+func NewStabilityProvider(ctx context.Context, settings types.AISettings) (*StabilityProvider, error) {
+	if settings.StabilityAPIKey == "" {
+		return nil, fmt.Errorf("stability API key not configured")
+	}
+
+	baseURL := strings.TrimSuffix(settings.StabilityBaseURL, "/")
+	if baseURL == "" {
+		baseURL = defaultStabilityBaseURL
+	}
+
+	model := strings.TrimSpace(settings.StabilityModel)
+	if model == "" {
+		model = defaultStabilityModel
+	}
+
+	rateLimits := newRateLimitTracker()
+	return &StabilityProvider{
+		ctx:     ctx,
+		baseURL: baseURL,
+		apiKey:  settings.StabilityAPIKey,
+		model:   model,
+		httpClient: &http.Client{
+			Transport: rateLimits.transport(NewHTTPTransport(settings)),
+			Timeout:   5 * time.Minute, // 图像生成可能需要较长时间
+		},
+		rateLimits: rateLimits,
+	}, nil
+}
+
+// RateLimits 返回最近一次响应中的限额信息（实现 RateLimitReporter 接口）
+func (p *StabilityProvider) RateLimits() (RateLimitInfo, bool) {
+	return p.rateLimits.snapshot()
+}
+
+// Name 返回提供商名称
+func (p *StabilityProvider) Name() string {
+	return "stability"
+}
+
+// GetCapabilities 返回提供商支持的功能
+func (p *StabilityProvider) GetCapabilities() ProviderCapabilities {
+	return stabilityCapabilities
+}
+
+// CheckAvailability 检测服务可用性
+// 查询账户信息接口，验证 API Key 有效且不消耗额度
+func (p *StabilityProvider) CheckAvailability(ctx context.Context) (bool, error) {
+	testCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(testCtx, http.MethodGet, p.baseURL+"/v1/user/account", nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("stability service unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return false, fmt.Errorf("stability service returned status %d: %s", resp.StatusCode, truncateString(string(bodyBytes), 500))
+	}
+
+	return true, nil
+}
+
+// Close 清理资源
+func (p *StabilityProvider) Close() error {
+	if p.httpClient != nil {
+		p.httpClient.CloseIdleConnections()
+	}
+	return nil
+}
+
+// ==================== API 方法实现 ====================
+
+// GenerateImage 生成图像
+// 提供草图时使用 control/sketch，提供参考图像时使用 SD3 image-to-image，否则按配置的模型文生图
+func (p *StabilityProvider) GenerateImage(ctx context.Context, params types.GenerateImageParams) (*types.ImageResult, error) {
+	fields := map[string]string{
+		"prompt":        params.Prompt,
+		"output_format": "png",
+	}
+	setStabilityCommonFields(fields, params.NegativePrompt, params.Seed)
+
+	configured := resolveModel(params.Model, p.model)
+	var files map[string]string
+	var endpoint, model string
+	switch {
+	case params.SketchImage != "":
+		endpoint = "/v2beta/stable-image/control/sketch"
+		fields["control_strength"] = stabilitySketchStrength
+		files = map[string]string{"image": params.SketchImage}
+	case params.ReferenceImage != "":
+		endpoint, model = stabilitySD3Endpoint(configured)
+		fields["mode"] = "image-to-image"
+		fields["strength"] = stabilityEditStrength
+		files = map[string]string{"image": params.ReferenceImage}
+	default:
+		endpoint, model = stabilityGenerateEndpoint(configured)
+		fields["aspect_ratio"] = mapStabilityAspectRatio(params.AspectRatio)
+	}
+	if model != "" {
+		fields["model"] = model
+	}
+
+	if model == "" {
+		model = configured
+	}
+	return p.callStabilityAPI(ctx, endpoint, fields, files, model)
+}
+
+// EditMultiImages 图像编辑
+// Stability 只接受单张输入图像；提供 mask 时使用 edit/inpaint 局部重绘
+func (p *StabilityProvider) EditMultiImages(ctx context.Context, params types.MultiImageEditParams) (*types.ImageResult, error) {
+	if len(params.Images) < 1 {
+		return nil, fmt.Errorf("at least 1 image is required")
+	}
+	if len(params.Images) > 1 {
+		return nil, fmt.Errorf("stability supports editing a single image, got %d", len(params.Images))
+	}
+
+	fields := map[string]string{
+		"prompt":        params.Prompt,
+		"output_format": "png",
+	}
+	setStabilityCommonFields(fields, params.NegativePrompt, params.Seed)
+	files := map[string]string{"image": params.Images[0]}
+
+	if params.Mask != "" {
+		files["mask"] = params.Mask
+		return p.callStabilityAPI(ctx, "/v2beta/stable-image/edit/inpaint", fields, files, "inpaint")
+	}
+
+	endpoint, model := stabilitySD3Endpoint(resolveModel(params.Model, p.model))
+	fields["model"] = model
+	fields["mode"] = "image-to-image"
+	fields["strength"] = stabilityEditStrength
+	return p.callStabilityAPI(ctx, endpoint, fields, files, model)
+}
+
+// EnhancePrompt 增强提示词（Stability 不提供文本模型）
+func (p *StabilityProvider) EnhancePrompt(ctx context.Context, params types.EnhancePromptParams) (*types.PromptResult, error) {
+	return nil, fmt.Errorf("stability provider does not support prompt enhancement")
+}
+
+// ==================== 辅助函数 ====================
+
+// stabilityGenerateEndpoint 返回文生图端点和需要传递的 model 字段
+// "core"、"ultra" 对应独立端点，其余（如 "sd3.5-large"）使用 SD3 端点并传递 model
+func stabilityGenerateEndpoint(model string) (string, string) {
+	switch model {
+	case "core", "ultra":
+		return "/v2beta/stable-image/generate/" + model, ""
+	default:
+		return "/v2beta/stable-image/generate/sd3", model
+	}
+}
+
+// stabilitySD3Endpoint 返回 image-to-image 使用的 SD3 端点和模型
+// 非 SD3 模型（core/ultra 不支持 image-to-image）使用默认的 SD3 模型
+func stabilitySD3Endpoint(model string) (string, string) {
+	if !strings.HasPrefix(model, "sd3") {
+		model = defaultStabilityEditModel
+	}
+	return "/v2beta/stable-image/generate/sd3", model
+}
+
+// setStabilityCommonFields 设置反向提示词和随机种子（0 表示随机，不传递）
+func setStabilityCommonFields(fields map[string]string, negativePrompt string, seed int64) {
+	if negativePrompt = strings.TrimSpace(negativePrompt); negativePrompt != "" {
+		fields["negative_prompt"] = negativePrompt
+	}
+	if seed != 0 {
+		fields["seed"] = strconv.FormatInt(seed, 10)
+	}
+}
+
+// mapStabilityAspectRatio 将应用的宽高比映射为 Stability 支持的取值
+// 不支持的 3:4、4:3 映射为最接近的 4:5、5:4，空值或未知值使用 1:1
+func mapStabilityAspectRatio(aspectRatio string) string {
+	switch aspectRatio {
+	case "3:4":
+		return "4:5"
+	case "4:3":
+		return "5:4"
+	}
+	if containsString(stabilityAspectRatios, aspectRatio) {
+		return aspectRatio
+	}
+	return "1:1"
+}
+
+// decodeStabilityImage 将 data URL 或裸 base64 图像解码为字节
+func decodeStabilityImage(imageData string) ([]byte, error) {
+	if strings.HasPrefix(imageData, "http://") || strings.HasPrefix(imageData, "https://") {
+		return nil, fmt.Errorf("image URLs are not supported by the stability provider")
+	}
+	data, err := base64.StdEncoding.DecodeString(extractBase64Data(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return data, nil
+}
+
+// stabilityResponse Stability API 的 JSON 响应（Accept: application/json）
+type stabilityResponse struct {
+	Image        string `json:"image"`         // base64 编码的图像
+	FinishReason string `json:"finish_reason"` // "SUCCESS" 或 "CONTENT_FILTERED"
+	Seed         int64  `json:"seed"`
+}
+
+// callStabilityAPI 以 multipart/form-data 调用 Stability API 并解析图像结果
+// files 为字段名到图像数据（data URL 或 base64）的映射
+func (p *StabilityProvider) callStabilityAPI(ctx context.Context, endpoint string, fields map[string]string, files map[string]string, model string) (*types.ImageResult, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for key, value := range fields {
+		if err := writer.WriteField(key, value); err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+	}
+	for key, imageData := range files {
+		data, err := decodeStabilityImage(imageData)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", key, err)
+		}
+		part, err := writer.CreateFormFile(key, key+".png")
+		if err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+		if _, err := part.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to build request: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+endpoint, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("stability API returned status %d: %s", resp.StatusCode, truncateString(string(bodyBytes), 500))
+	}
+
+	var response stabilityResponse
+	if err := json.Unmarshal(bodyBytes, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if response.FinishReason == "CONTENT_FILTERED" {
+		return nil, fmt.Errorf("stability API filtered the result due to content moderation")
+	}
+	if response.Image == "" {
+		return nil, fmt.Errorf("invalid response format: missing 'image' field")
+	}
+
+	result := &types.ImageResult{
+		Image: "data:image/png;base64," + response.Image,
+		Model: model,
+		Usage: &types.Usage{Images: 1},
+	}
+	if response.Seed != 0 {
+		setResultMetadata(result, "seed", strconv.FormatInt(response.Seed, 10))
+	}
+	return result, nil
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"artifex/core/provider"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// generationProgressEvent 图像生成进度事件名称
+	generationProgressEvent = "ai:generation-progress"
+	// enhanceProgressEvent 提示词增强进度事件名称
+	enhanceProgressEvent = "ai:enhance-progress"
+	// generationPreviewEvent 图像生成中间预览事件名称（GenerateImageParams.Preview）
+	generationPreviewEvent = "ai:preview"
+	// progressHeartbeatInterval 心跳事件发送间隔
+	progressHeartbeatInterval = 2 * time.Second
+	// progressStreamInterval 流式进度事件的最小发送间隔，避免事件过于频繁
+	progressStreamInterval = 500 * time.Millisecond
+)
+
+// GenerationProgress 图像生成进度信息
+type GenerationProgress struct {
+	RequestID     string `json:"requestId"`
+	Status        string `json:"status"`                  // "generating", "streaming", "completed", "error"
+	ElapsedMs     int64  `json:"elapsedMs"`               // 已耗时（毫秒）
+	BytesReceived int    `json:"bytesReceived,omitempty"` // 流式模式下已接收的字节数
+	Preview       string `json:"preview,omitempty"`       // 中间预览图像（data URI，可选）
+	Message       string `json:"message,omitempty"`       // 状态消息
+}
+
+// GenerationPreview 图像生成中间预览
+// 每个请求最多发送一次（提供商上报的第一张预览），最终结果仍通过调用返回值获取
+type GenerationPreview struct {
+	RequestID string `json:"requestId"`
+	Preview   string `json:"preview"`   // 低分辨率预览图像（data URI）
+	ElapsedMs int64  `json:"elapsedMs"` // 收到预览时的已耗时（毫秒）
+}
+
+// EnhanceProgress 提示词增强进度信息
+type EnhanceProgress struct {
+	RequestID string `json:"requestId"`
+	Status    string `json:"status"`            // "enhancing", "completed", "error"
+	ElapsedMs int64  `json:"elapsedMs"`         // 已耗时（毫秒）
+	Message   string `json:"message,omitempty"` // 状态消息
+}
+
+// emitGenerationProgress 发送图像生成进度事件
+func (a *AIService) emitGenerationProgress(progress GenerationProgress) {
+	if a.ctx == nil {
+		return
+	}
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: failed to serialize progress: %v\n", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, generationProgressEvent, string(progressJSON))
+}
+
+// emitGenerationPreview 发送图像生成中间预览事件
+func (a *AIService) emitGenerationPreview(preview GenerationPreview) {
+	if a.ctx == nil {
+		return
+	}
+	previewJSON, err := json.Marshal(preview)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: failed to serialize preview: %v\n", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, generationPreviewEvent, string(previewJSON))
+}
+
+// trackGenerationProgress 跟踪一次提供商调用的进度
+// 调用期间定期发送心跳事件，并把提供商上报的流式进度转发为 ai:generation-progress 事件；
+// 提供商上报的第一张预览同时发送为 ai:preview 事件
+// 返回携带进度回调的 context，以及调用结束时必须执行的 finish 函数
+func (a *AIService) trackGenerationProgress(ctx context.Context, requestID string) (context.Context, func(err error)) {
+	startTime := time.Now()
+	done := make(chan struct{})
+
+	var mu sync.Mutex
+	var lastStreamEmit time.Time
+	var previewSent bool
+
+	a.emitGenerationProgress(GenerationProgress{
+		RequestID: requestID,
+		Status:    "generating",
+	})
+
+	reporter := func(update provider.ProgressUpdate) {
+		mu.Lock()
+		// 预览图像总是立即发送，普通流式进度按间隔节流
+		if update.Preview == "" && time.Since(lastStreamEmit) < progressStreamInterval {
+			mu.Unlock()
+			return
+		}
+		lastStreamEmit = time.Now()
+		firstPreview := update.Preview != "" && !previewSent
+		if firstPreview {
+			previewSent = true
+		}
+		mu.Unlock()
+
+		if firstPreview {
+			a.emitGenerationPreview(GenerationPreview{
+				RequestID: requestID,
+				Preview:   update.Preview,
+				ElapsedMs: time.Since(startTime).Milliseconds(),
+			})
+		}
+
+		a.emitGenerationProgress(GenerationProgress{
+			RequestID:     requestID,
+			Status:        update.Stage,
+			ElapsedMs:     time.Since(startTime).Milliseconds(),
+			BytesReceived: update.BytesReceived,
+			Preview:       update.Preview,
+		})
+	}
+
+	// 心跳协程：随调用结束或请求取消而退出
+	go func() {
+		ticker := time.NewTicker(progressHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.emitGenerationProgress(GenerationProgress{
+					RequestID: requestID,
+					Status:    "generating",
+					ElapsedMs: time.Since(startTime).Milliseconds(),
+				})
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	finish := func(err error) {
+		once.Do(func() {
+			close(done)
+			progress := GenerationProgress{
+				RequestID: requestID,
+				Status:    "completed",
+				ElapsedMs: time.Since(startTime).Milliseconds(),
+			}
+			if err != nil {
+				progress.Status = "error"
+				progress.Message = a.sanitizeMessage(err.Error())
+			}
+			a.emitGenerationProgress(progress)
+		})
+	}
+
+	return provider.WithProgressReporter(ctx, reporter), finish
+}
+
+// emitEnhanceProgress 发送提示词增强进度事件
+func (a *AIService) emitEnhanceProgress(progress EnhanceProgress) {
+	if a.ctx == nil {
+		return
+	}
+	progressJSON, err := json.Marshal(progress)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: failed to serialize progress: %v\n", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, enhanceProgressEvent, string(progressJSON))
+}
+
+// trackEnhanceProgress 跟踪一次提示词增强调用
+// 调用期间定期发送 ai:enhance-progress 心跳事件，返回调用结束时必须执行的 finish 函数
+func (a *AIService) trackEnhanceProgress(ctx context.Context, requestID string) func(err error) {
+	startTime := time.Now()
+	done := make(chan struct{})
+
+	a.emitEnhanceProgress(EnhanceProgress{
+		RequestID: requestID,
+		Status:    "enhancing",
+	})
+
+	// 心跳协程：随调用结束或请求取消而退出
+	go func() {
+		ticker := time.NewTicker(progressHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				a.emitEnhanceProgress(EnhanceProgress{
+					RequestID: requestID,
+					Status:    "enhancing",
+					ElapsedMs: time.Since(startTime).Milliseconds(),
+				})
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func(err error) {
+		once.Do(func() {
+			close(done)
+			progress := EnhanceProgress{
+				RequestID: requestID,
+				Status:    "completed",
+				ElapsedMs: time.Since(startTime).Milliseconds(),
+			}
+			if err != nil {
+				progress.Status = "error"
+				progress.Message = a.sanitizeMessage(err.Error())
+			}
+			a.emitEnhanceProgress(progress)
+		})
+	}
+}
@@ -3,12 +3,18 @@ package service
 import (
 	"artifex/core/provider"
 	"artifex/core/types"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"path/filepath"
+	"image"
+	"image/color"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
 // ==================== AIService 提供商管理器 ====================
@@ -27,6 +33,34 @@ type AIService struct {
 	// Context 管理器，用于管理每个请求的 context
 	contextManager *ContextManager
 	imageStorage   *ImageStorage
+
+	// 请求限流器，限制同时进行的图像生成/编辑调用数量
+	limiter *RequestLimiter
+
+	// 输入图像大小上限（字节），超过时发送前缩小
+	maxInputImageBytes atomic.Int64
+
+	// 结果缓存（按配置启用）
+	resultCache       *ResultCache
+	cacheEnabled      atomic.Bool
+	cacheImageResults atomic.Bool
+
+	// 用量统计器（持久化到 config/usage.json）
+	usageTracker *UsageTracker
+
+	// 进行中请求的记录器（持久化到 config/pending_requests.json），用于异常退出后恢复
+	requestRecovery *RequestRecovery
+
+	// 编辑提示词改写器（规则来自 config/prompt_rewrites.json）
+	promptRewriter       *PromptRewriter
+	promptRewriteEnabled atomic.Bool
+
+	// 提供商模型列表缓存，用于校验调用参数中的模型覆盖
+	modelLists  map[string]modelList
+	modelListMu sync.Mutex
+
+	// 可用性检测结果缓存，避免设置页频繁检测时反复请求提供商
+	availability *availabilityCache
 }
 
 // NewAIService 创建 AI 服务实例
@@ -34,6 +68,9 @@ func NewAIService(configService *ConfigService) *AIService {
 	return &AIService{
 		configService: configService,
 		providers:     make(map[string]provider.AIProvider),
+		limiter:       NewRequestLimiter(defaultMaxConcurrentRequests),
+		resultCache:   NewResultCache(defaultResultCacheSize),
+		availability:  newAvailabilityCache(),
 	}
 }
 
@@ -41,22 +78,63 @@ func NewAIService(configService *ConfigService) *AIService {
 func (a *AIService) Startup(ctx context.Context) {
 	a.ctx = ctx
 	a.contextManager = NewContextManager(ctx)
-	a.contextManager.StartCleanupRoutine()
+	a.contextManager.StartCleanupRoutine(ctx)
+	a.applyRuntimeSettings()
+
+	// 设置变更后重新加载提供商（见 ConfigChangedEvent）
+	// 只改动运行时设置时就地应用，不中断进行中的请求
+	runtime.EventsOn(ctx, ConfigChangedEvent, func(data ...interface{}) {
+		if !providerSettingsChanged(data) {
+			a.mu.Lock()
+			a.reloadRuntimeSettings()
+			a.mu.Unlock()
+			return
+		}
+		if err := a.ReloadProviders(); err != nil {
+			fmt.Printf("[AIService] Warning: failed to reload AI providers: %v\n", err)
+		}
+		a.PreloadProviders()
+	})
 
-	exeDir, err := getExecutableDir()
+	dataDir, err := ResolveDataDir()
 	if err != nil {
-		fmt.Printf("[AIService] Warning: failed to get executable dir: %v\n", err)
+		fmt.Printf("[AIService] Warning: failed to resolve data dir: %v\n", err)
 		return
 	}
 
-	dataDir := filepath.Join(exeDir, "config")
 	a.imageStorage = NewImageStorage(dataDir)
 	if err := a.imageStorage.Initialize(); err != nil {
 		fmt.Printf("[AIService] Warning: failed to initialize image storage: %v\n", err)
 	}
+	if aiSettings, err := a.loadAISettings(); err == nil {
+		a.imageStorage.SetStoredFormat(aiSettings.StoredImageFormat)
+		a.imageStorage.SetSaveQuality(aiSettings.StoredImageQuality)
+		a.imageStorage.SetAllowedMimeTypes(aiSettings.AllowedImageMimeTypes)
+	}
+
+	a.usageTracker = NewUsageTracker(dataDir)
+	if err := a.usageTracker.Load(); err != nil {
+		fmt.Printf("[AIService] Warning: failed to load usage records: %v\n", err)
+	}
+
+	a.requestRecovery = NewRequestRecovery(dataDir)
+	if err := a.requestRecovery.Load(); err != nil {
+		fmt.Printf("[AIService] Warning: failed to load pending requests: %v\n", err)
+	}
+	a.emitUnfinishedRequests()
+
+	a.promptRewriter = NewPromptRewriter(dataDir)
+	if err := a.promptRewriter.Load(); err != nil {
+		fmt.Printf("[AIService] Warning: failed to load prompt rewrite rules, using defaults: %v\n", err)
+	}
+
+	a.PreloadProviders()
 }
 
 
+// maxImageBatchCount 单次批量生成的最大图像数量
+const maxImageBatchCount = 10
+
 // ==================== 提供商管理方法 ====================
 
 // RegisterProvider 注册提供商
@@ -91,23 +169,67 @@ func (a *AIService) GetProviderCapabilities(providerName string) (*provider.Prov
 	return &caps, nil
 }
 
+// CurrentCapabilitiesResult 当前提供商的能力声明
+type CurrentCapabilitiesResult struct {
+	Provider string `json:"provider"` // 当前配置的提供商名称
+	provider.ProviderCapabilities
+}
+
+// CurrentCapabilities 获取当前配置的提供商的能力
+// 返回 JSON：{"provider": string, "generateImage": bool, ..., "supportedSizes": [], "supportedAspectRatios": []}
+// 前端无需知道提供商名称即可决定启用哪些功能
+func (a *AIService) CurrentCapabilities() (capsJSON string, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
+	aiProvider, err := a.getCurrentProvider()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(CurrentCapabilitiesResult{
+		Provider:             aiProvider.Name(),
+		ProviderCapabilities: aiProvider.GetCapabilities(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize capabilities: %w", err)
+	}
+	return string(data), nil
+}
+
 // CheckProviderAvailability 检测提供商可用性
-func (a *AIService) CheckProviderAvailability(providerName string) (bool, string, error) {
-	aiProvider, err := a.GetProvider(providerName)
+// 相同提供商和配置的检测结果缓存几秒，频繁的重复检测复用上次结果（见 availabilityCache）；
+// force 为 true 时跳过缓存立即检测，用于用户显式点击"测试连接"
+func (a *AIService) CheckProviderAvailability(providerName string, force bool) (bool, string, error) {
+	// 先校验配置，直接提示缺失的字段，而不是等到调用 API 时才返回难以理解的错误
+	problems, err := a.ValidateSettings(providerName)
 	if err != nil {
-		return false, "", fmt.Errorf("failed to get provider: %w", err)
+		return false, "", a.sanitizeError(err)
+	}
+	if len(problems) > 0 {
+		return false, formatSettingsProblems(problems), nil
 	}
 
-	available, err := aiProvider.CheckAvailability(a.ctx)
+	aiSettings, err := a.loadAISettings()
 	if err != nil {
-		return false, err.Error(), nil
+		return false, "", a.sanitizeError(err)
 	}
 
-	if !available {
-		return false, "服务不可用", nil
+	aiProvider, err := a.GetProvider(providerName)
+	if err != nil {
+		return false, "", a.sanitizeError(fmt.Errorf("failed to get provider: %w", err))
 	}
 
-	return true, "", nil
+	available, message := a.availability.check(providerName, availabilityKey(providerName, aiSettings), force, func() (bool, string) {
+		available, err := aiProvider.CheckAvailability(a.ctx)
+		if err != nil {
+			return false, a.sanitizeMessage(err.Error())
+		}
+		if !available {
+			return false, "服务不可用"
+		}
+		return true, ""
+	})
+	return available, message, nil
 }
 
 // createProvider 创建提供商（内部方法）
@@ -126,6 +248,10 @@ func (a *AIService) createProvider(name string) (provider.AIProvider, error) {
 		return aiProvider, nil
 	}
 
+	if problems := validateProviderSettings(name, aiSettings); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid %s settings: %s", name, strings.Join(problems, "; "))
+	}
+
 	var aiProvider provider.AIProvider
 
 	switch name {
@@ -135,6 +261,12 @@ func (a *AIService) createProvider(name string) (provider.AIProvider, error) {
 		aiProvider, err = provider.NewOpenAIProvider(a.ctx, aiSettings)
 	case "cloud":
 		aiProvider, err = provider.NewCloudProvider(a.ctx, aiSettings)
+	case "stability":
+		aiProvider, err = provider.NewStabilityProvider(a.ctx, aiSettings)
+	case "local":
+		aiProvider, err = provider.NewLocalProvider(a.ctx, aiSettings)
+	case "replicate":
+		aiProvider, err = provider.NewReplicateProvider(a.ctx, aiSettings)
 	default:
 		return nil, fmt.Errorf("unsupported AI provider: %s", name)
 	}
@@ -171,6 +303,39 @@ func (a *AIService) getCurrentProvider() (provider.AIProvider, error) {
 	return a.GetProvider(aiSettings.Provider)
 }
 
+// resolveProvider 获取调用使用的提供商（内部方法）
+// name 为调用参数中的提供商覆盖，为空时使用当前配置的提供商
+func (a *AIService) resolveProvider(name string) (provider.AIProvider, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return a.getCurrentProvider()
+	}
+	return a.GetProvider(name)
+}
+
+// PreloadProviders 在后台预先创建当前配置的提供商，避免首次请求时才初始化 HTTP 客户端和认证
+// 立即返回，不阻塞调用方；配置缺失或无效时直接跳过，等到实际调用时再报告错误
+func (a *AIService) PreloadProviders() {
+	go func() {
+		aiSettings, err := a.loadAISettings()
+		if err != nil {
+			fmt.Printf("[AIService] Skipping provider preload: %v\n", a.sanitizeError(err))
+			return
+		}
+		if problems := validateProviderSettings(aiSettings.Provider, aiSettings); len(problems) > 0 {
+			fmt.Printf("[AIService] Skipping provider preload for %s: settings incomplete\n", aiSettings.Provider)
+			return
+		}
+
+		startTime := time.Now()
+		if _, err := a.GetProvider(aiSettings.Provider); err != nil {
+			fmt.Printf("[AIService] Warning: failed to preload provider %s: %v\n", aiSettings.Provider, a.sanitizeError(err))
+			return
+		}
+		fmt.Printf("[AIService] Preloaded provider %s in %v\n", aiSettings.Provider, time.Since(startTime).Round(time.Millisecond))
+	}()
+}
+
 // ReloadProviders 重新加载所有提供商（配置变更时调用）
 // 关闭现有提供商并清除缓存，下次调用时会使用新配置重新创建
 func (a *AIService) ReloadProviders() error {
@@ -179,74 +344,456 @@ func (a *AIService) ReloadProviders() error {
 
 	fmt.Printf("[AIService] Reloading providers due to configuration change\n")
 
+	// 取消所有进行中的请求，避免切换提供商后旧请求继续使用旧配置
+	if a.contextManager != nil {
+		if cancelled := a.contextManager.CancelAll(); cancelled > 0 {
+			fmt.Printf("[AIService] Cancelled %d in-flight request(s)\n", cancelled)
+		}
+	}
+
 	var lastErr error
 	for name, aiProvider := range a.providers {
 		if err := aiProvider.Close(); err != nil {
-			lastErr = fmt.Errorf("failed to close provider %s: %w", name, err)
-			fmt.Printf("[AIService] Error closing provider %s: %v\n", name, err)
+			lastErr = a.sanitizeError(fmt.Errorf("failed to close provider %s: %w", name, err))
+			fmt.Printf("[AIService] Error closing provider %s: %v\n", name, a.sanitizeError(err))
 		}
 	}
 
-	// 清除缓存
+	// 清除缓存（提供商配置变化后旧结果不再对应当前模型）
 	a.providers = make(map[string]provider.AIProvider)
+	a.clearModelLists()
+	a.resultCache.Clear()
+
+	a.reloadRuntimeSettings()
 
 	return lastErr
 }
 
-// Close 关闭所有提供商，释放资源
+// runtimeOnlySettings 无需重建提供商即可生效的设置字段（由 applyRuntimeSettings 等就地应用）
+var runtimeOnlySettings = map[string]bool{
+	"version":               true,
+	"maxConcurrentRequests": true,
+	"maxInputImageBytes":    true,
+	"storedImageFormat":     true,
+	"storedImageQuality":    true,
+	"allowedImageMimeTypes": true,
+	"resultCacheEnabled":    true,
+	"resultCacheImages":     true,
+	"resultCacheMaxEntries": true,
+	"fileLoggingEnabled":    true,
+	"compressHistoryFiles":  true,
+	"promptRewriteEnabled":  true,
+}
+
+// providerSettingsChanged 判断设置变更事件是否涉及提供商相关字段（内部方法）
+// 事件数据无法解析时按涉及处理，保证提供商使用最新配置
+func providerSettingsChanged(data []interface{}) bool {
+	if len(data) == 0 {
+		return true
+	}
+	changeJSON, ok := data[0].(string)
+	if !ok {
+		return true
+	}
+	var change ConfigChange
+	if err := json.Unmarshal([]byte(changeJSON), &change); err != nil || len(change.Keys) == 0 {
+		return true
+	}
+	for _, key := range change.Keys {
+		if !runtimeOnlySettings[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// reloadRuntimeSettings 重新应用运行时设置并重新加载改写规则（内部方法，调用方需持有 a.mu）
+func (a *AIService) reloadRuntimeSettings() {
+	a.applyRuntimeSettings()
+
+	// 重新加载改写规则，使规则文件的修改无需重启即可生效
+	if a.promptRewriter != nil {
+		if err := a.promptRewriter.Load(); err != nil {
+			fmt.Printf("[AIService] Warning: failed to reload prompt rewrite rules, using defaults: %v\n", err)
+		}
+	}
+}
+
+// applyRuntimeSettings 根据配置调整请求并发上限和结果缓存（内部方法）
+func (a *AIService) applyRuntimeSettings() {
+	aiSettings, err := a.loadAISettings()
+	if err != nil {
+		return
+	}
+	a.limiter.SetLimit(aiSettings.MaxConcurrentRequests)
+	a.maxInputImageBytes.Store(aiSettings.MaxInputImageBytes)
+	if a.imageStorage != nil {
+		a.imageStorage.SetStoredFormat(aiSettings.StoredImageFormat)
+		a.imageStorage.SetSaveQuality(aiSettings.StoredImageQuality)
+		a.imageStorage.SetAllowedMimeTypes(aiSettings.AllowedImageMimeTypes)
+	}
+
+	a.cacheEnabled.Store(aiSettings.ResultCacheEnabled)
+	a.cacheImageResults.Store(aiSettings.ResultCacheEnabled && aiSettings.ResultCacheImages)
+	a.resultCache.SetMaxEntries(aiSettings.ResultCacheMaxEntries)
+	if !aiSettings.ResultCacheEnabled {
+		a.resultCache.Clear()
+	}
+
+	a.promptRewriteEnabled.Store(aiSettings.PromptRewriteEnabled)
+}
+
+// configuredModel 返回提供商当前配置的模型名称（内部方法）
+// imageOperation 为 true 时返回图像模型，否则返回文本模型
+// local 的模型由 WebUI 当前加载的检查点决定，无法从设置得知，ReloadProviders 时清空结果缓存
+func configuredModel(settings types.AISettings, providerName string, imageOperation bool) string {
+	switch providerName {
+	case "stability":
+		return settings.StabilityModel
+	case "replicate":
+		return settings.ReplicateModel
+	case "gemini":
+		if imageOperation {
+			return settings.ImageModel
+		}
+		return settings.TextModel
+	case "openai":
+		if imageOperation {
+			return settings.OpenAIImageModel
+		}
+		return settings.OpenAITextModel
+	default:
+		return ""
+	}
+}
+
+// imageResultCacheKey 计算图像生成结果的缓存键（内部方法）
+// 未启用图像缓存或使用随机种子时返回空字符串，表示不使用缓存
+func (a *AIService) imageResultCacheKey(providerName string, params types.GenerateImageParams) string {
+	if !a.cacheImageResults.Load() || params.Seed == 0 {
+		return ""
+	}
+	// 预览只影响生成过程中的事件，不影响结果；提供商已包含在 providerName 中
+	params.Preview = false
+	params.Provider = ""
+	aiSettings, err := a.loadAISettings()
+	if err != nil {
+		return ""
+	}
+	key, err := ResultCacheKey(providerName, configuredModel(aiSettings, providerName, true), "generateImage", params)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// recordUsage 记录一次提供商调用的用量（内部方法）
+// 写盘由 UsageTracker 延迟合并执行，失败只打印警告，不影响调用结果
+func (a *AIService) recordUsage(providerName, model, operation string, usage *types.Usage) {
+	if a.usageTracker == nil {
+		return
+	}
+	a.usageTracker.Record(providerName, model, operation, usage)
+}
+
+// GetUsageStats 获取用量统计
+// sinceUnix: 起始 Unix 时间戳（秒），<= 0 表示统计全部记录
+// 返回 JSON 格式的 UsageStats（总计以及按提供商、模型、操作类型分组的统计）
+func (a *AIService) GetUsageStats(sinceUnix int64) (string, error) {
+	if a.usageTracker == nil {
+		return "", fmt.Errorf("usage tracker not initialized")
+	}
+
+	data, err := json.Marshal(a.usageTracker.Stats(sinceUnix))
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize usage stats: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetQueueStatus 获取请求队列状态（正在执行和排队中的请求数）
+func (a *AIService) GetQueueStatus() RequestQueueStatus {
+	return a.limiter.Status()
+}
+
+// Close 关闭所有提供商，释放资源，并写入尚未保存的用量记录
 func (a *AIService) Close() error {
+	if a.usageTracker != nil {
+		if err := a.usageTracker.Flush(); err != nil {
+			fmt.Printf("[AIService] Warning: failed to save usage records: %v\n", err)
+		}
+	}
 	return a.ReloadProviders()
 }
 
 // ==================== 公共 API 方法 ====================
 
+// GenerationResult 详细的图像生成结果
+type GenerationResult struct {
+	Image         string            `json:"image"`                   // image ref (images/...)
+	RequestID     string            `json:"requestId"`               // 请求 ID（调用方传空字符串时为服务端生成的 ID）
+	Provider      string            `json:"provider"`                // 提供商名称
+	Model         string            `json:"model,omitempty"`         // 实际使用的模型
+	DurationMs    int64             `json:"durationMs"`              // 提供商调用耗时（毫秒，不含排队时间）
+	CacheHit      bool              `json:"cacheHit,omitempty"`      // 是否命中结果缓存
+	RevisedPrompt string            `json:"revisedPrompt,omitempty"` // 提供商改写后的提示词
+	Metadata      map[string]string `json:"metadata,omitempty"`      // 提供商回传的其他元数据
+	SkippedImages []SkippedImage    `json:"skippedImages,omitempty"` // 尽力模式下被跳过的输入图像（仅编辑）
+}
+
+// SkippedImage 尽力模式下被跳过的输入图像
+type SkippedImage struct {
+	Index  int    `json:"index"`  // 输入图像在 images 数组中的下标
+	Reason string `json:"reason"` // 跳过原因
+}
+
 // GenerateImage 生成图像
-// 返回 base64 编码的图像数据
+// 返回图像 ref（委托给 GenerateImageDetailed 的内部实现，仅返回图像部分）
 // requestID: 请求 ID，用于管理 context 和取消请求
 func (a *AIService) GenerateImage(paramsJSON string, requestID string) (string, error) {
+	result, err := a.generateImage(paramsJSON, requestID)
+	if err != nil {
+		return "", err
+	}
+	return result.Image, nil
+}
+
+// GenerateImageDetailed 生成图像并返回详细结果
+// 返回 JSON 格式的 GenerationResult，包含图像 ref、请求 ID、提供商、模型、耗时及提供商回传的元数据
+// requestID: 请求 ID，用于管理 context 和取消请求；传空字符串时由服务端生成并在结果中返回
+func (a *AIService) GenerateImageDetailed(paramsJSON string, requestID string) (string, error) {
+	result, err := a.generateImage(paramsJSON, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return string(data), nil
+}
+
+// GenerateImages 批量生成图像（同一提示词生成多张变体）
+// 数量由 params.count 指定（默认 1，最多 maxImageBatchCount）
+// 提供商支持原生批量生成时一次调用完成，否则在同一请求 context 下依次生成，取消请求会终止整个批次
+// 返回 JSON 格式的图像 ref 数组
+// requestID: 请求 ID，用于管理 context 和取消请求
+func (a *AIService) GenerateImages(paramsJSON string, requestID string) (refsJSON string, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
 	var params types.GenerateImageParams
 	if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
 		return "", fmt.Errorf("invalid parameters: %w", err)
 	}
 
-	reqCtx, err := a.contextManager.CreateRequestContext(requestID)
+	count := params.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > maxImageBatchCount {
+		return "", fmt.Errorf("count must be between 1 and %d", maxImageBatchCount)
+	}
+
+	requestID = ensureRequestID(requestID)
+	reqCtx, err := a.contextManager.CreateRequestContext(requestOpGenerate, requestID)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request context: %w", err)
 	}
-	defer a.contextManager.CleanupRequest(requestID)
+	defer a.contextManager.CleanupRequest(requestOpGenerate, requestID, reqCtx)
 
-	aiProvider, err := a.getCurrentProvider()
+	aiProvider, err := a.prepareGenerateImage(&params)
 	if err != nil {
 		return "", err
 	}
+	defer a.trackPendingRequest(requestID, "generateImages", aiProvider.Name(), paramsJSON)()
+
+	refs := make([]string, 0, count)
+	if batchProvider, ok := aiProvider.(provider.BatchImageGenerator); ok && count > 1 {
+		// 原生批量生成：只占用一个并发名额
+		release, err := a.limiter.Acquire(reqCtx)
+		if err != nil {
+			return "", fmt.Errorf("request cancelled while queued: %w", err)
+		}
+		progressCtx, finish := a.trackGenerationProgress(reqCtx, requestID)
+		results, err := batchProvider.GenerateImages(progressCtx, params, count)
+		finish(err)
+		release()
+		if err != nil {
+			return "", err
+		}
+		for _, result := range results {
+			if result == nil {
+				continue
+			}
+			a.recordUsage(aiProvider.Name(), result.Model, "generateImage", result.Usage)
+			ref, err := a.storeImageResult(reqCtx, result.Image)
+			if err != nil {
+				return "", err
+			}
+			refs = append(refs, ref)
+		}
+	} else {
+		for i := 0; i < count; i++ {
+			variation := params
+			// 固定种子时为每张变体使用不同种子，避免生成完全相同的图像
+			variation.Seed = provider.VariationSeed(params.Seed, i)
+
+			result, err := a.callGenerateImage(reqCtx, requestID, aiProvider, variation)
+			if err != nil {
+				return "", err
+			}
+			refs = append(refs, result.Image)
+		}
+	}
+
+	data, err := json.Marshal(refs)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return string(data), nil
+}
+
+// generateImage 生成图像（内部方法）
+func (a *AIService) generateImage(paramsJSON string, requestID string) (generated *GenerationResult, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
+	var params types.GenerateImageParams
+	if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	requestID = ensureRequestID(requestID)
+	reqCtx, err := a.contextManager.CreateRequestContext(requestOpGenerate, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request context: %w", err)
+	}
+	defer a.contextManager.CleanupRequest(requestOpGenerate, requestID, reqCtx)
+
+	aiProvider, err := a.prepareGenerateImage(&params)
+	if err != nil {
+		return nil, err
+	}
+	defer a.trackPendingRequest(requestID, "generateImage", aiProvider.Name(), paramsJSON)()
+
+	cacheKey := a.imageResultCacheKey(aiProvider.Name(), params)
+	if cacheKey != "" {
+		if cached, ok := a.resultCache.Get(cacheKey); ok {
+			hit := *cached.(*GenerationResult)
+			hit.CacheHit = true
+			hit.DurationMs = 0
+			hit.RequestID = requestID
+			return &hit, nil
+		}
+	}
+
+	result, err := a.callGenerateImage(reqCtx, requestID, aiProvider, params)
+	if err != nil {
+		return nil, err
+	}
+	result.RequestID = requestID
+	if cacheKey != "" {
+		cached := *result
+		a.resultCache.Put(cacheKey, &cached)
+	}
+	return result, nil
+}
+
+// prepareGenerateImage 获取提供商（params.Provider 覆盖或当前配置）、检查能力并规范化输入图像（内部方法）
+func (a *AIService) prepareGenerateImage(params *types.GenerateImageParams) (provider.AIProvider, error) {
+	aiProvider, err := a.resolveProvider(params.Provider)
+	if err != nil {
+		return nil, err
+	}
 
 	caps := aiProvider.GetCapabilities()
 	if !caps.GenerateImage {
-		return "", fmt.Errorf("aiProvider %s does not support image generation", aiProvider.Name())
+		return nil, fmt.Errorf("aiProvider %s does not support image generation", aiProvider.Name())
 	}
 
 	if params.ReferenceImage != "" && !caps.ReferenceImage {
-		return "", fmt.Errorf("aiProvider %s does not support reference image", aiProvider.Name())
+		return nil, fmt.Errorf("aiProvider %s does not support reference image", aiProvider.Name())
+	}
+
+	if err := validateImageOptions(aiProvider, caps, &params.ImageSize, &params.AspectRatio); err != nil {
+		return nil, err
+	}
+
+	params.Model = strings.TrimSpace(params.Model)
+	if err := a.validateModelOverride(aiProvider, params.Model); err != nil {
+		return nil, err
 	}
 
 	if params.ReferenceImage != "" {
 		params.ReferenceImage, err = a.normalizeImageInput(params.ReferenceImage)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
 	if params.SketchImage != "" {
 		params.SketchImage, err = a.normalizeImageInput(params.SketchImage)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 	}
+	params.ReferenceImage = a.shrinkImageInput(params.ReferenceImage, "reference image")
+	params.SketchImage = a.shrinkImageInput(params.SketchImage, "sketch image")
 
-	result, err := aiProvider.GenerateImage(reqCtx, params)
+	return aiProvider, nil
+}
+
+// validateImageOptions 规范化图像尺寸和宽高比，并校验当前提供商是否支持（内部方法，生成和编辑共用）
+func validateImageOptions(aiProvider provider.AIProvider, caps provider.ProviderCapabilities, imageSize, aspectRatio *string) error {
+	size, ratio, err := provider.NormalizeImageOptions(*imageSize, *aspectRatio)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if err := caps.ValidateImageOptions(size, ratio); err != nil {
+		return fmt.Errorf("aiProvider %s: %w", aiProvider.Name(), err)
 	}
-	return a.storeImageResult(result)
+	*imageSize, *aspectRatio = size, ratio
+	return nil
+}
+
+// callGenerateImage 在并发限制下调用提供商生成单张图像并存储结果（内部方法）
+// 调用期间通过 ai:generation-progress 事件推送进度
+func (a *AIService) callGenerateImage(ctx context.Context, requestID string, aiProvider provider.AIProvider, params types.GenerateImageParams) (*GenerationResult, error) {
+	release, err := a.limiter.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("request cancelled while queued: %w", err)
+	}
+	defer release()
+
+	progressCtx, finish := a.trackGenerationProgress(ctx, requestID)
+	startTime := time.Now()
+	result, err := aiProvider.GenerateImage(progressCtx, params)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	a.recordUsage(aiProvider.Name(), result.Model, "generateImage", result.Usage)
+	return a.buildGenerationResult(ctx, aiProvider.Name(), result, time.Since(startTime))
+}
+
+// buildGenerationResult 存储提供商返回的图像并构建详细结果（内部方法）
+func (a *AIService) buildGenerationResult(ctx context.Context, providerName string, result *types.ImageResult, duration time.Duration) (*GenerationResult, error) {
+	if result == nil {
+		return nil, fmt.Errorf("empty image data")
+	}
+
+	imageRef, err := a.storeImageResult(ctx, result.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GenerationResult{
+		Image:         imageRef,
+		Provider:      providerName,
+		Model:         result.Model,
+		DurationMs:    duration.Milliseconds(),
+		RevisedPrompt: result.RevisedPrompt,
+		Metadata:      result.Metadata,
+	}, nil
 }
 
 
@@ -254,94 +801,230 @@ func (a *AIService) GenerateImage(paramsJSON string, requestID string) (string,
 // 统一使用多图编辑方法，即使只有一张图也使用此方法
 // requestID: 请求 ID，用于管理 context 和取消请求
 func (a *AIService) EditMultiImages(paramsJSON string, requestID string) (string, error) {
+	result, err := a.editMultiImages(paramsJSON, requestID)
+	if err != nil {
+		return "", err
+	}
+	return result.Image, nil
+}
+
+// EditMultiImagesDetailed 编辑图像并返回详细结果
+// 返回 JSON 格式的 GenerationResult；params.bestEffort 为 true 时，
+// skippedImages 列出被跳过的输入图像下标及原因
+// requestID: 请求 ID，用于管理 context 和取消请求
+func (a *AIService) EditMultiImagesDetailed(paramsJSON string, requestID string) (string, error) {
+	result, err := a.editMultiImages(paramsJSON, requestID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return string(data), nil
+}
+
+// editMultiImages 编辑图像（内部方法）
+func (a *AIService) editMultiImages(paramsJSON string, requestID string) (edited *GenerationResult, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
 	var params types.MultiImageEditParams
 	if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
-		return "", fmt.Errorf("invalid parameters: %w", err)
+		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
 	if len(params.Images) < 1 {
-		return "", fmt.Errorf("at least 1 image is required")
+		return nil, fmt.Errorf("at least 1 image is required")
 	}
-	reqCtx, err := a.contextManager.CreateRequestContext(requestID)
+	requestID = ensureRequestID(requestID)
+	reqCtx, err := a.contextManager.CreateRequestContext(requestOpEdit, requestID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request context: %w", err)
+		return nil, fmt.Errorf("failed to create request context: %w", err)
 	}
-	defer a.contextManager.CleanupRequest(requestID)
+	defer a.contextManager.CleanupRequest(requestOpEdit, requestID, reqCtx)
 
-	aiProvider, err := a.getCurrentProvider()
+	aiProvider, err := a.resolveProvider(params.Provider)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer a.trackPendingRequest(requestID, "editImage", aiProvider.Name(), paramsJSON)()
 
 	caps := aiProvider.GetCapabilities()
 	if !caps.EditImage {
-		return "", fmt.Errorf("aiProvider %s does not support image editing", aiProvider.Name())
+		return nil, fmt.Errorf("aiProvider %s does not support image editing", aiProvider.Name())
+	}
+	if params.Mask != "" && !caps.Inpaint {
+		return nil, fmt.Errorf("aiProvider %s does not support masked editing (inpaint)", aiProvider.Name())
+	}
+	if err := validateImageOptions(aiProvider, caps, &params.ImageSize, &params.AspectRatio); err != nil {
+		return nil, err
 	}
 
-	params.Images, err = a.normalizeImageInputs(params.Images)
+	params.Model = strings.TrimSpace(params.Model)
+	if err := a.validateModelOverride(aiProvider, params.Model); err != nil {
+		return nil, err
+	}
+
+	var skipped []SkippedImage
+	if params.BestEffort {
+		params.Images, skipped, err = a.normalizeImageInputsBestEffort(params.Images)
+	} else {
+		params.Images, err = a.normalizeImageInputs(params.Images)
+	}
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	params.Mask, err = a.normalizeImageInput(params.Mask)
+	if err != nil {
+		return nil, err
+	}
+	// 遮罩需要与输入图像尺寸一致，局部重绘时不缩小
+	if params.Mask == "" {
+		params.Images = a.shrinkImageInputs(params.Images, "input image")
+	}
+	if !params.SkipPromptRewrite {
+		params.Prompt = a.rewritePromptIfNeeded(params.Prompt)
 	}
 
-	result, err := aiProvider.EditMultiImages(reqCtx, params)
+	release, err := a.limiter.Acquire(reqCtx)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("request cancelled while queued: %w", err)
 	}
-	return a.storeImageResult(result)
+	defer release()
+
+	progressCtx, finish := a.trackGenerationProgress(reqCtx, requestID)
+	startTime := time.Now()
+	result, err := aiProvider.EditMultiImages(progressCtx, params)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	a.recordUsage(aiProvider.Name(), result.Model, "editImage", result.Usage)
+
+	edited, err = a.buildGenerationResult(reqCtx, aiProvider.Name(), result, time.Since(startTime))
+	if err != nil {
+		return nil, err
+	}
+	edited.RequestID = requestID
+	edited.SkippedImages = skipped
+	return edited, nil
 }
 
 
 // RemoveBackground 移除背景
 // requestID: 请求 ID，用于管理 context 和取消请求
+// 结果始终保存为 PNG；需要透明度检测结果或纯色回退时使用 RemoveBackgroundWithOptions
 func (a *AIService) RemoveBackground(imageData string, requestID string) (string, error) {
-	reqCtx, err := a.contextManager.CreateRequestContext(requestID)
+	result, err := a.removeBackground(types.RemoveBackgroundParams{Image: imageData}, requestID)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request context: %w", err)
+		return "", err
 	}
-	defer a.contextManager.CleanupRequest(requestID)
+	return result.Image, nil
+}
 
-	aiProvider, err := a.getCurrentProvider()
+// RemoveBackgroundWithOptions 移除背景（带选项）
+// paramsJSON: JSON 格式的 RemoveBackgroundParams
+// 返回 JSON 格式的 RemoveBackgroundResult，包含结果是否透明以及警告信息
+func (a *AIService) RemoveBackgroundWithOptions(paramsJSON string, requestID string) (string, error) {
+	var params types.RemoveBackgroundParams
+	if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
+		return "", fmt.Errorf("invalid parameters: %w", err)
+	}
+
+	result, err := a.removeBackground(params, requestID)
 	if err != nil {
 		return "", err
 	}
 
+	data, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+	return string(data), nil
+}
+
+// removeBackground 移除背景并对结果做透明度后处理（内部方法）
+func (a *AIService) removeBackground(params types.RemoveBackgroundParams, requestID string) (result *types.RemoveBackgroundResult, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
+	var fallback *color.NRGBA
+	if params.FallbackColor != "" {
+		parsed, err := parseHexColor(params.FallbackColor)
+		if err != nil {
+			return nil, err
+		}
+		fallback = &parsed
+	}
+
+	requestID = ensureRequestID(requestID)
+	reqCtx, err := a.contextManager.CreateRequestContext(requestOpRemoveBackground, requestID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request context: %w", err)
+	}
+	defer a.contextManager.CleanupRequest(requestOpRemoveBackground, requestID, reqCtx)
+
+	aiProvider, err := a.getCurrentProvider()
+	if err != nil {
+		return nil, err
+	}
+
 	caps := aiProvider.GetCapabilities()
 	if !caps.RemoveBackground {
-		return "", fmt.Errorf("aiProvider %s does not support background removal", aiProvider.Name())
+		return nil, fmt.Errorf("aiProvider %s does not support background removal", aiProvider.Name())
 	}
 
-	normalized, err := a.normalizeImageInput(imageData)
+	normalized, err := a.normalizeImageInput(params.Image)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	prompt := "Remove the background from this image. Keep the main subject intact with high quality. Return the image with transparent background."
+	if !caps.TransparentOutput && fallback != nil {
+		// 提供商无法输出透明图像时，直接要求纯色背景，便于后续使用
+		prompt = fmt.Sprintf("Remove the background from this image. Keep the main subject intact with high quality. Replace the background with a solid flat color %s.", strings.ToLower(params.FallbackColor))
 	}
 
 	multiParams := types.MultiImageEditParams{
 		Images: []string{normalized},
-		Prompt: "Remove the background from this image. Keep the main subject intact with high quality. Return the image with transparent background.",
+		Prompt: prompt,
 	}
 
-	result, err := aiProvider.EditMultiImages(reqCtx, multiParams)
+	release, err := a.limiter.Acquire(reqCtx)
 	if err != nil {
-		return "", err
+		return nil, fmt.Errorf("request cancelled while queued: %w", err)
 	}
-	return a.storeImageResult(result)
-}
+	defer release()
+
+	progressCtx, finish := a.trackGenerationProgress(reqCtx, requestID)
+	imageResult, err := aiProvider.EditMultiImages(progressCtx, multiParams)
+	finish(err)
+	if err != nil {
+		return nil, err
+	}
+	a.recordUsage(aiProvider.Name(), imageResult.Model, "removeBackground", imageResult.Usage)
 
+	return a.storeTransparentResult(reqCtx, imageResult.Image, fallback, caps.TransparentOutput)
+}
 
 // EnhancePrompt 增强提示词
 // paramsJSON: JSON 格式的 EnhancePromptParams，包含 prompt 和可选的 referenceImages
 // requestID: 请求 ID，用于管理 context 和取消请求
-func (a *AIService) EnhancePrompt(paramsJSON string, requestID string) (string, error) {
+// 调用提供商期间通过 ai:enhance-progress 事件定期发送心跳
+func (a *AIService) EnhancePrompt(paramsJSON string, requestID string) (enhancedPrompt string, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
 	var params types.EnhancePromptParams
 	if err := json.Unmarshal([]byte(paramsJSON), &params); err != nil {
 		return "", fmt.Errorf("invalid parameters: %w", err)
 	}
 
-	reqCtx, err := a.contextManager.CreateRequestContext(requestID)
+	requestID = ensureRequestID(requestID)
+	reqCtx, err := a.contextManager.CreateRequestContext(requestOpEnhance, requestID)
 	if err != nil {
 		return "", fmt.Errorf("failed to create request context: %w", err)
 	}
-	defer a.contextManager.CleanupRequest(requestID)
+	defer a.contextManager.CleanupRequest(requestOpEnhance, requestID, reqCtx)
 
 	aiProvider, err := a.getCurrentProvider()
 	if err != nil {
@@ -357,14 +1040,43 @@ func (a *AIService) EnhancePrompt(paramsJSON string, requestID string) (string,
 		return "", fmt.Errorf("aiProvider %s does not support reference images for prompt enhancement", aiProvider.Name())
 	}
 
+	params.Model = strings.TrimSpace(params.Model)
+	if err := a.validateModelOverride(aiProvider, params.Model); err != nil {
+		return "", err
+	}
+
 	if len(params.ReferenceImages) > 0 {
 		params.ReferenceImages, err = a.normalizeImageInputs(params.ReferenceImages)
 		if err != nil {
 			return "", err
 		}
+		params.ReferenceImages = a.shrinkImageInputs(params.ReferenceImages, "reference image")
+	}
+
+	var cacheKey string
+	if a.cacheEnabled.Load() {
+		aiSettings, err := a.loadAISettings()
+		if err == nil {
+			cacheKey, _ = ResultCacheKey(aiProvider.Name(), configuredModel(aiSettings, aiProvider.Name(), len(params.ReferenceImages) > 0), "enhancePrompt", params)
+		}
+		if cached, ok := a.resultCache.Get(cacheKey); ok && cacheKey != "" {
+			return cached.(string), nil
+		}
+	}
+
+	finish := a.trackEnhanceProgress(reqCtx, requestID)
+	result, err := aiProvider.EnhancePrompt(reqCtx, params)
+	finish(err)
+	if err != nil {
+		return "", err
 	}
+	a.recordUsage(aiProvider.Name(), result.Model, "enhancePrompt", result.Usage)
 
-	return aiProvider.EnhancePrompt(reqCtx, params)
+	enhanced := result.Text
+	if cacheKey != "" {
+		a.resultCache.Put(cacheKey, enhanced)
+	}
+	return enhanced, nil
 }
 
 
@@ -404,7 +1116,58 @@ func (a *AIService) normalizeImageInputs(images []string) ([]string, error) {
 	return result, nil
 }
 
-func (a *AIService) storeImageResult(imageData string) (string, error) {
+// normalizeImageInputsBestEffort 规范化输入图像，跳过无法读取或解码的图像（内部方法）
+// 返回保留的图像及被跳过图像的原始下标和原因；全部图像都无效时返回错误
+func (a *AIService) normalizeImageInputsBestEffort(images []string) ([]string, []SkippedImage, error) {
+	result := make([]string, 0, len(images))
+	var skipped []SkippedImage
+	for i, img := range images {
+		normalized, err := a.normalizeImageInput(img)
+		if err == nil {
+			err = checkImageInput(normalized)
+		}
+		if err != nil {
+			skipped = append(skipped, SkippedImage{Index: i, Reason: err.Error()})
+			continue
+		}
+		result = append(result, normalized)
+	}
+
+	if len(result) == 0 {
+		return nil, skipped, fmt.Errorf("all %d input images were rejected", len(images))
+	}
+	return result, skipped, nil
+}
+
+// checkImageInput 检查输入图像能否被正常读取（http URL 由提供商获取，不做检查）
+// 可识别 PNG、JPEG、GIF、WebP；除 WebP 外还会校验图像头能否解码
+func checkImageInput(imageData string) error {
+	if imageData == "" {
+		return fmt.Errorf("empty image data")
+	}
+	if strings.HasPrefix(imageData, "http://") || strings.HasPrefix(imageData, "https://") {
+		return nil
+	}
+
+	data, _, err := decodeImageDataURL(imageData)
+	if err != nil {
+		return fmt.Errorf("invalid image data: %w", err)
+	}
+
+	switch detectImageFormat(data) {
+	case "":
+		return fmt.Errorf("unrecognized image format")
+	case "webp":
+		// 无 WebP 解码器，只能确认格式
+		return nil
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	return nil
+}
+
+func (a *AIService) storeImageResult(ctx context.Context, imageData string) (string, error) {
 	if imageData == "" {
 		return "", fmt.Errorf("empty image data")
 	}
@@ -421,7 +1184,7 @@ func (a *AIService) storeImageResult(imageData string) (string, error) {
 		return a.imageStorage.SaveImage(imageData)
 	}
 	if strings.HasPrefix(imageData, "http://") || strings.HasPrefix(imageData, "https://") {
-		return a.imageStorage.SaveImageFromURL(imageData)
+		return a.imageStorage.SaveImageFromURL(ctx, imageData)
 	}
 	if looksLikeBase64Image(imageData) {
 		return a.imageStorage.SaveImage("data:image/png;base64," + imageData)
@@ -440,3 +1203,27 @@ func (a *AIService) CancelRequest(requestID string) error {
 	}
 	return a.contextManager.CancelRequest(requestID)
 }
+
+// ListActiveRequests 列出所有进行中的 AI 请求
+// 返回 JSON 数组：[{"requestId": string, "operation": string, "createdAt": int, "ageMs": int}]
+// 同一请求 ID 在不同操作（"gen"、"edit"、"rmbg"、"enh"）下各占一项
+func (a *AIService) ListActiveRequests() (string, error) {
+	if a.contextManager == nil {
+		return "", fmt.Errorf("context manager not initialized")
+	}
+
+	data, err := json.Marshal(a.contextManager.ListActiveRequests())
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize active requests: %w", err)
+	}
+	return string(data), nil
+}
+
+// CancelAllRequests 取消所有进行中的 AI 请求
+// 返回被取消的请求数量
+func (a *AIService) CancelAllRequests() (int, error) {
+	if a.contextManager == nil {
+		return 0, fmt.Errorf("context manager not initialized")
+	}
+	return a.contextManager.CancelAll(), nil
+}
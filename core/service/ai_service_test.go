@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+
+	"artifex/core/types"
+)
+
+func TestProviderSettingsChanged(t *testing.T) {
+	cases := []struct {
+		name string
+		data []interface{}
+		want bool
+	}{
+		{name: "runtime only", data: []interface{}{`{"keys":["maxConcurrentRequests","resultCacheEnabled"]}`}, want: false},
+		{name: "version bump", data: []interface{}{`{"keys":["version","compressHistoryFiles"]}`}, want: false},
+		{name: "provider switch", data: []interface{}{`{"keys":["provider"]}`}, want: true},
+		{name: "api key", data: []interface{}{`{"keys":["storedImageFormat","openaiApiKey"]}`}, want: true},
+		{name: "base url", data: []interface{}{`{"keys":["openaiBaseUrl"]}`}, want: true},
+		{name: "model", data: []interface{}{`{"keys":["imageModel"]}`}, want: true},
+		{name: "transport", data: []interface{}{`{"keys":["providerDialTimeout"]}`}, want: true},
+		{name: "no data", data: nil, want: true},
+		{name: "not a string", data: []interface{}{42}, want: true},
+		{name: "invalid json", data: []interface{}{`{"keys":`}, want: true},
+		{name: "no keys", data: []interface{}{`{"keys":[]}`}, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := providerSettingsChanged(tc.data); got != tc.want {
+				t.Fatalf("providerSettingsChanged(%v) = %v, want %v", tc.data, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConfiguredModel(t *testing.T) {
+	settings := types.AISettings{
+		ImageModel:       "gemini-image",
+		TextModel:        "gemini-text",
+		OpenAIImageModel: "gpt-image-1",
+		OpenAITextModel:  "gpt-4o-mini",
+		StabilityModel:   "sd3.5-large",
+		ReplicateModel:   "black-forest-labs/flux-schnell",
+	}
+
+	cases := []struct {
+		provider string
+		image    bool
+		want     string
+	}{
+		{provider: "gemini", image: true, want: "gemini-image"},
+		{provider: "gemini", image: false, want: "gemini-text"},
+		{provider: "openai", image: true, want: "gpt-image-1"},
+		{provider: "openai", image: false, want: "gpt-4o-mini"},
+		{provider: "stability", image: true, want: "sd3.5-large"},
+		{provider: "replicate", image: true, want: "black-forest-labs/flux-schnell"},
+		{provider: "local", image: true, want: ""},
+	}
+
+	for _, tc := range cases {
+		if got := configuredModel(settings, tc.provider, tc.image); got != tc.want {
+			t.Fatalf("configuredModel(%s, image=%v) = %q, want %q", tc.provider, tc.image, got, tc.want)
+		}
+	}
+}
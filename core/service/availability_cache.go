@@ -0,0 +1,110 @@
+package service
+
+import (
+	"artifex/core/types"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// 可用性检测缓存配置
+const (
+	// availabilityCacheTTL 可用性检测结果的缓存时间
+	availabilityCacheTTL = 5 * time.Second
+	// availabilityCheckInterval 同一提供商相邻两次实际检测的最小间隔
+	availabilityCheckInterval = time.Second
+)
+
+// availabilityResult 一次可用性检测的结果
+type availabilityResult struct {
+	available bool
+	message   string
+	checkedAt time.Time
+}
+
+// availabilityCall 进行中的可用性检测，相同配置的并发检测等待同一次调用
+type availabilityCall struct {
+	done   chan struct{}
+	result availabilityResult
+}
+
+// availabilityCache 提供商可用性检测缓存
+// 设置页在编辑 API Key 时会频繁触发检测：结果按提供商和配置哈希缓存 availabilityCacheTTL，
+// 相同配置的并发检测共享一次调用，同一提供商的实际检测至少间隔 availabilityCheckInterval
+type availabilityCache struct {
+	mu        sync.Mutex
+	results   map[string]availabilityResult
+	inflight  map[string]*availabilityCall
+	nextCheck map[string]time.Time // 每个提供商下一次允许开始检测的时间
+}
+
+// newAvailabilityCache 创建可用性检测缓存
+func newAvailabilityCache() *availabilityCache {
+	return &availabilityCache{
+		results:   make(map[string]availabilityResult),
+		inflight:  make(map[string]*availabilityCall),
+		nextCheck: make(map[string]time.Time),
+	}
+}
+
+// availabilityKey 根据提供商名称和当前配置生成缓存键
+// 任意配置字段（如 API Key、服务地址）变化都会得到不同的键
+func availabilityKey(providerName string, settings types.AISettings) string {
+	data, _ := json.Marshal(settings)
+	hash := sha256.New()
+	hash.Write([]byte(providerName))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return providerName + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+// check 返回 key 对应的检测结果，必要时调用 run 执行实际检测
+// force 为 true 时跳过缓存和限流立即检测，检测结果仍会写入缓存
+func (c *availabilityCache) check(providerName, key string, force bool, run func() (bool, string)) (bool, string) {
+	c.mu.Lock()
+	if !force {
+		if cached, ok := c.results[key]; ok && time.Since(cached.checkedAt) < availabilityCacheTTL {
+			c.mu.Unlock()
+			return cached.available, cached.message
+		}
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.mu.Unlock()
+		<-call.done
+		return call.result.available, call.result.message
+	}
+
+	call := &availabilityCall{done: make(chan struct{})}
+	c.inflight[key] = call
+
+	now := time.Now()
+	slot := c.nextCheck[providerName]
+	if force || slot.Before(now) {
+		slot = now
+	}
+	c.nextCheck[providerName] = slot.Add(availabilityCheckInterval)
+	c.mu.Unlock()
+
+	if wait := time.Until(slot); wait > 0 {
+		time.Sleep(wait)
+	}
+
+	available, message := run()
+	call.result = availabilityResult{available: available, message: message, checkedAt: time.Now()}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.results[key] = call.result
+	// 清理过期的结果，避免频繁修改配置后无限增长
+	for k, cached := range c.results {
+		if time.Since(cached.checkedAt) >= availabilityCacheTTL {
+			delete(c.results, k)
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+
+	return available, message
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// maxCompositeDimension 合成画布的最大边长（像素），避免超大画布耗尽内存
+const maxCompositeDimension = 16384
+
+// canvasCompositeRequest 画布合成导出请求
+// 与 LoadCanvasHistory 返回的结构兼容，额外支持 background 选项
+type canvasCompositeRequest struct {
+	Images     []ImageRecord `json:"images"`
+	Background string        `json:"background,omitempty"` // 背景色（如 "#ffffff"），为空时为透明背景
+}
+
+// ExportCanvasComposite 将整个画布合成为一张 PNG 并通过保存对话框导出
+// canvasJSON: {"images": [ImageRecord...], "background"?: "#ffffff"}
+// 按 ZIndex 从低到高绘制，支持位置、尺寸和旋转（角度，绕图像中心顺时针）
+// 返回保存的文件路径，用户取消时返回空字符串
+func (f *FileService) ExportCanvasComposite(canvasJSON string) (string, error) {
+	if f.ctx == nil {
+		return "", fmt.Errorf("service not initialized")
+	}
+
+	var request canvasCompositeRequest
+	if err := json.Unmarshal([]byte(canvasJSON), &request); err != nil {
+		return "", fmt.Errorf("invalid canvas data: %w", err)
+	}
+
+	var background *color.NRGBA
+	if request.Background != "" {
+		parsed, err := parseHexColor(request.Background)
+		if err != nil {
+			return "", err
+		}
+		background = &parsed
+	}
+
+	composite, err := f.compositeCanvas(request.Images, background)
+	if err != nil {
+		return "", err
+	}
+
+	filePath, err := runtime.SaveFileDialog(f.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("artifexBot-canvas-%d.png", time.Now().Unix()),
+		Title:           "Export Canvas",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "PNG Image (*.png)",
+				Pattern:     "*.png",
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("save dialog error: %w", err)
+	}
+
+	// 用户取消了保存
+	if filePath == "" {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, composite); err != nil {
+		return "", fmt.Errorf("failed to encode png: %w", err)
+	}
+
+	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write image file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// compositeCanvas 将画布中的图像绘制到同一张图上（内部方法）
+func (f *FileService) compositeCanvas(records []ImageRecord, background *color.NRGBA) (*image.RGBA, error) {
+	// 过滤无效记录并按 ZIndex 排序（ZIndex 相同时保持原顺序）
+	layers := make([]ImageRecord, 0, len(records))
+	for _, record := range records {
+		if record.Src == "" || record.Width <= 0 || record.Height <= 0 {
+			continue
+		}
+		layers = append(layers, record)
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("no images to export")
+	}
+	sort.SliceStable(layers, func(i, j int) bool {
+		return layers[i].ZIndex < layers[j].ZIndex
+	})
+
+	// 计算所有图像（含旋转）的外接矩形
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, layer := range layers {
+		x0, y0, x1, y1 := rotatedBounds(layer)
+		minX, minY = math.Min(minX, x0), math.Min(minY, y0)
+		maxX, maxY = math.Max(maxX, x1), math.Max(maxY, y1)
+	}
+
+	width := int(math.Ceil(maxX - minX))
+	height := int(math.Ceil(maxY - minY))
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("canvas is empty")
+	}
+	if width > maxCompositeDimension || height > maxCompositeDimension {
+		return nil, fmt.Errorf("canvas too large to export: %dx%d (limit %d)", width, height, maxCompositeDimension)
+	}
+
+	composite := image.NewRGBA(image.Rect(0, 0, width, height))
+	if background != nil {
+		r, g, b, a := background.RGBA()
+		fill := color.RGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)}
+		for i := 0; i < len(composite.Pix); i += 4 {
+			composite.Pix[i], composite.Pix[i+1], composite.Pix[i+2], composite.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+		}
+	}
+
+	for _, layer := range layers {
+		imageData, err := f.loadImageBytes(layer.Src)
+		if err != nil {
+			fmt.Printf("[FileService] Warning: skipping canvas image %s: %v\n", layer.ID, err)
+			continue
+		}
+		src, _, err := image.Decode(bytes.NewReader(imageData))
+		if err != nil {
+			fmt.Printf("[FileService] Warning: skipping canvas image %s: %v\n", layer.ID, err)
+			continue
+		}
+		drawTransformed(composite, src, layer, minX, minY)
+	}
+
+	return composite, nil
+}
+
+// rotatedBounds 返回图像旋转后在画布坐标系中的外接矩形
+func rotatedBounds(record ImageRecord) (float64, float64, float64, float64) {
+	cx := record.X + record.Width/2
+	cy := record.Y + record.Height/2
+	sin, cos := math.Sincos(record.Rotation * math.Pi / 180)
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{
+		{-record.Width / 2, -record.Height / 2},
+		{record.Width / 2, -record.Height / 2},
+		{record.Width / 2, record.Height / 2},
+		{-record.Width / 2, record.Height / 2},
+	} {
+		x := cx + corner[0]*cos - corner[1]*sin
+		y := cy + corner[0]*sin + corner[1]*cos
+		minX, minY = math.Min(minX, x), math.Min(minY, y)
+		maxX, maxY = math.Max(maxX, x), math.Max(maxY, y)
+	}
+	return minX, minY, maxX, maxY
+}
+
+// drawTransformed 将源图缩放、旋转后以 Over 方式绘制到目标图上
+// offsetX/offsetY 为画布坐标系到目标图像素坐标的偏移
+func drawTransformed(dst *image.RGBA, src image.Image, record ImageRecord, offsetX, offsetY float64) {
+	srcBounds := src.Bounds()
+	scaleX := float64(srcBounds.Dx()) / record.Width
+	scaleY := float64(srcBounds.Dy()) / record.Height
+
+	cx := record.X + record.Width/2 - offsetX
+	cy := record.Y + record.Height/2 - offsetY
+	sin, cos := math.Sincos(record.Rotation * math.Pi / 180)
+
+	x0, y0, x1, y1 := rotatedBounds(record)
+	startX := int(math.Max(0, math.Floor(x0-offsetX)))
+	startY := int(math.Max(0, math.Floor(y0-offsetY)))
+	endX := int(math.Min(float64(dst.Bounds().Dx()), math.Ceil(x1-offsetX)))
+	endY := int(math.Min(float64(dst.Bounds().Dy()), math.Ceil(y1-offsetY)))
+
+	for py := startY; py < endY; py++ {
+		for px := startX; px < endX; px++ {
+			// 反向旋转到图像局部坐标系
+			dx := float64(px) + 0.5 - cx
+			dy := float64(py) + 0.5 - cy
+			lx := dx*cos + dy*sin + record.Width/2
+			ly := -dx*sin + dy*cos + record.Height/2
+			if lx < 0 || ly < 0 || lx >= record.Width || ly >= record.Height {
+				continue
+			}
+
+			sx := srcBounds.Min.X + int(lx*scaleX)
+			sy := srcBounds.Min.Y + int(ly*scaleY)
+			sr, sg, sb, sa := src.At(sx, sy).RGBA()
+			if sa == 0 {
+				continue
+			}
+
+			// 预乘 alpha 的 Over 合成
+			i := dst.PixOffset(px, py)
+			inv := 0xffff - sa
+			dst.Pix[i] = uint8((sr + uint32(dst.Pix[i])*0x101*inv/0xffff) >> 8)
+			dst.Pix[i+1] = uint8((sg + uint32(dst.Pix[i+1])*0x101*inv/0xffff) >> 8)
+			dst.Pix[i+2] = uint8((sb + uint32(dst.Pix[i+2])*0x101*inv/0xffff) >> 8)
+			dst.Pix[i+3] = uint8((sa + uint32(dst.Pix[i+3])*0x101*inv/0xffff) >> 8)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// ConfigChangedEvent 设置变更事件名称
+// 事件数据为 JSON 字符串：{"keys": ["provider", "openaiBaseUrl", ...]}
+// keys 为发生变化的设置字段（"version" 或 ai 下的字段名），密钥字段只报告名称不含取值
+// 前端和各服务（通过 runtime.EventsOn）订阅该事件以重新应用配置
+const ConfigChangedEvent = "config:changed"
+
+// ConfigChange 设置变更事件数据
+type ConfigChange struct {
+	Keys []string `json:"keys"`
+}
+
+// currentSettingsFields 读取当前配置文件中的设置字段（已解密），用于变更比较
+// 配置文件不存在或无法解析时返回 nil
+func (c *ConfigService) currentSettingsFields() map[string]interface{} {
+	data, err := os.ReadFile(c.configFile)
+	if err != nil {
+		return nil
+	}
+	settingsJSON, err := c.decodeSettings(data)
+	if err != nil {
+		return nil
+	}
+	return settingsFields([]byte(settingsJSON))
+}
+
+// settingsFields 将设置 JSON 展开为 "version" 和 ai 字段组成的扁平映射
+// 先按 Settings 结构规范化，缺失字段与零值视为相同
+func settingsFields(settingsJSON []byte) map[string]interface{} {
+	var settings types.Settings
+	if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+		return nil
+	}
+	normalized, err := json.Marshal(settings)
+	if err != nil {
+		return nil
+	}
+
+	var raw struct {
+		Version interface{}            `json:"version"`
+		AI      map[string]interface{} `json:"ai"`
+	}
+	if err := json.Unmarshal(normalized, &raw); err != nil {
+		return nil
+	}
+
+	fields := make(map[string]interface{}, len(raw.AI)+1)
+	for key, value := range raw.AI {
+		fields[key] = value
+	}
+	fields["version"] = raw.Version
+	return fields
+}
+
+// changedSettingsKeys 比较两次设置，返回发生变化的字段（按字母排序）
+func changedSettingsKeys(previous, current map[string]interface{}) []string {
+	keys := make([]string, 0)
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !reflect.DeepEqual(old, value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// emitConfigChanged 发送设置变更事件，没有变化时不发送
+func (c *ConfigService) emitConfigChanged(keys []string) {
+	if c.ctx == nil || len(keys) == 0 {
+		return
+	}
+
+	changeJSON, err := json.Marshal(ConfigChange{Keys: keys})
+	if err != nil {
+		fmt.Printf("[ConfigService] Warning: failed to serialize config change: %v\n", err)
+		return
+	}
+	runtime.EventsEmit(c.ctx, ConfigChangedEvent, string(changeJSON))
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// normalizeSettingsData 按 Settings 结构校验配置文件数据并补全默认值
+// 返回修正后的文件数据和修正说明；数据无法解析时返回错误
+// 敏感字段保持加密状态，不做处理
+func normalizeSettingsData(data []byte) ([]byte, []string, error) {
+	var raw struct {
+		Version *string                    `json:"version"`
+		AI      map[string]json.RawMessage `json:"ai"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	defaults := defaultSettings()
+	// 在默认设置上解析，缺失的字段自动保留默认值
+	settings := defaultSettings()
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, nil, err
+	}
+
+	var corrections []string
+	if raw.Version == nil {
+		corrections = append(corrections, fmt.Sprintf("missing field version, using default %q", defaults.Version))
+	}
+
+	known := settingsFieldNames()
+	var missing, unknown []string
+	for name, required := range known {
+		if _, ok := raw.AI[name]; !ok && required {
+			missing = append(missing, name)
+		}
+	}
+	for name := range raw.AI {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unknown)
+	for _, name := range missing {
+		corrections = append(corrections, fmt.Sprintf("missing field ai.%s, using default", name))
+	}
+	for _, name := range unknown {
+		corrections = append(corrections, fmt.Sprintf("ignored unknown field ai.%s", name))
+	}
+
+	ai := &settings.AI
+	switch ai.Provider {
+	case "gemini", "openai", "cloud", "stability", "local", "replicate":
+	default:
+		corrections = append(corrections, fmt.Sprintf("invalid provider %q, reset to %q", ai.Provider, defaults.AI.Provider))
+		ai.Provider = defaults.AI.Provider
+	}
+
+	switch ai.OpenAIImageMode {
+	case types.OpenAIImageModeAuto, types.OpenAIImageModeImageAPI, types.OpenAIImageModeChat:
+	default:
+		corrections = append(corrections, fmt.Sprintf("invalid openaiImageMode %q, reset to %q", ai.OpenAIImageMode, defaults.AI.OpenAIImageMode))
+		ai.OpenAIImageMode = defaults.AI.OpenAIImageMode
+	}
+
+	switch ai.StoredImageFormat {
+	case "", types.StoredImageFormatPNG, types.StoredImageFormatJPEG:
+	default:
+		corrections = append(corrections, fmt.Sprintf("unsupported storedImageFormat %q (expected png or jpeg), keeping original formats", ai.StoredImageFormat))
+		ai.StoredImageFormat = defaults.AI.StoredImageFormat
+	}
+
+	if ai.StoredImageQuality < 0 || ai.StoredImageQuality > 100 {
+		corrections = append(corrections, fmt.Sprintf("invalid storedImageQuality %d (expected 1-100), reset to default", ai.StoredImageQuality))
+		ai.StoredImageQuality = defaults.AI.StoredImageQuality
+	}
+
+	if len(ai.AllowedImageMimeTypes) > 0 {
+		allowed := make([]string, 0, len(ai.AllowedImageMimeTypes))
+		for _, mimeType := range ai.AllowedImageMimeTypes {
+			if !strings.HasPrefix(normalizeImageMime(mimeType), "image/") {
+				corrections = append(corrections, fmt.Sprintf("invalid allowedImageMimeTypes entry %q, removed", mimeType))
+				continue
+			}
+			allowed = append(allowed, mimeType)
+		}
+		ai.AllowedImageMimeTypes = allowed
+	}
+
+	if ai.MaxConcurrentRequests < 0 {
+		corrections = append(corrections, fmt.Sprintf("invalid maxConcurrentRequests %d, reset to %d", ai.MaxConcurrentRequests, defaults.AI.MaxConcurrentRequests))
+		ai.MaxConcurrentRequests = defaults.AI.MaxConcurrentRequests
+	}
+	if ai.ProviderIdleConnTimeout < 0 || ai.ProviderMaxIdleConns < 0 || ai.ProviderDialTimeout < 0 {
+		corrections = append(corrections, "invalid provider connection settings, reset to defaults")
+		ai.ProviderIdleConnTimeout = defaults.AI.ProviderIdleConnTimeout
+		ai.ProviderMaxIdleConns = defaults.AI.ProviderMaxIdleConns
+		ai.ProviderDialTimeout = defaults.AI.ProviderDialTimeout
+	}
+	if ai.ResultCacheMaxEntries < 0 {
+		corrections = append(corrections, fmt.Sprintf("invalid resultCacheMaxEntries %d, reset to default", ai.ResultCacheMaxEntries))
+		ai.ResultCacheMaxEntries = defaults.AI.ResultCacheMaxEntries
+	}
+	if ai.MaxInputImageBytes < 0 {
+		corrections = append(corrections, fmt.Sprintf("invalid maxInputImageBytes %d, reset to default", ai.MaxInputImageBytes))
+		ai.MaxInputImageBytes = defaults.AI.MaxInputImageBytes
+	}
+	if ai.LocalSteps < 0 {
+		corrections = append(corrections, fmt.Sprintf("invalid localSteps %d, reset to %d", ai.LocalSteps, defaults.AI.LocalSteps))
+		ai.LocalSteps = defaults.AI.LocalSteps
+	}
+
+	if len(corrections) == 0 {
+		return data, nil, nil
+	}
+
+	normalized, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize settings: %w", err)
+	}
+	return normalized, corrections, nil
+}
+
+// settingsFieldNames 返回 AISettings 的全部 JSON 字段名，值表示保存的配置文件中是否总是包含该字段
+// 从结构体标签读取而不是序列化零值，omitempty 字段（如 allowedImageMimeTypes）同样计入，
+// 但值为 false：未设置时不会写入文件，缺失不视为需要修正
+func settingsFieldNames() map[string]bool {
+	settingsType := reflect.TypeOf(types.AISettings{})
+	names := make(map[string]bool, settingsType.NumField())
+	for i := 0; i < settingsType.NumField(); i++ {
+		field := settingsType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = !strings.Contains(","+options+",", ",omitempty,")
+	}
+	return names
+}
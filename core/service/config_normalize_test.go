@@ -0,0 +1,60 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNormalizeSettingsDataAcceptsOmitemptyFields(t *testing.T) {
+	cases := []struct {
+		name      string
+		mimeTypes []string
+	}{
+		{name: "unset", mimeTypes: nil},
+		{name: "set", mimeTypes: []string{"image/png", "image/jpeg"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			settings := defaultSettings()
+			settings.AI.AllowedImageMimeTypes = tc.mimeTypes
+			data, err := json.Marshal(settings)
+			if err != nil {
+				t.Fatalf("marshal settings: %v", err)
+			}
+
+			_, corrections, err := normalizeSettingsData(data)
+			if err != nil {
+				t.Fatalf("normalizeSettingsData: %v", err)
+			}
+			if len(corrections) != 0 {
+				t.Fatalf("expected no corrections, got %v", corrections)
+			}
+		})
+	}
+}
+
+func TestNormalizeSettingsDataReportsUnknownFields(t *testing.T) {
+	settings := defaultSettings()
+	data, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("marshal settings: %v", err)
+	}
+	var raw struct {
+		Version string                 `json:"version"`
+		AI      map[string]interface{} `json:"ai"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("unmarshal settings: %v", err)
+	}
+	raw.AI["notARealSetting"] = true
+	data, _ = json.Marshal(raw)
+
+	_, corrections, err := normalizeSettingsData(data)
+	if err != nil {
+		t.Fatalf("normalizeSettingsData: %v", err)
+	}
+	if len(corrections) != 1 || corrections[0] != "ignored unknown field ai.notARealSetting" {
+		t.Fatalf("unexpected corrections: %v", corrections)
+	}
+}
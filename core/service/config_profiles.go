@@ -0,0 +1,249 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxProfileNameLength 配置档案名称的最大长度（字符数）
+const maxProfileNameLength = 64
+
+// ConfigProfile 配置档案摘要信息
+type ConfigProfile struct {
+	Name      string `json:"name"`
+	Provider  string `json:"provider"`  // 档案中配置的提供商
+	UpdatedAt int64  `json:"updatedAt"` // 最后保存时间（毫秒）
+	Active    bool   `json:"active"`    // 是否为当前启用的档案
+}
+
+// activeProfileState 当前启用档案的持久化状态
+type activeProfileState struct {
+	Active string `json:"active"`
+}
+
+// profilesDir 配置档案目录
+func (c *ConfigService) profilesDir() string {
+	return filepath.Join(c.configDir, "profiles")
+}
+
+// activeProfileFile 记录当前启用档案名称的文件
+func (c *ConfigService) activeProfileFile() string {
+	return filepath.Join(c.configDir, "active_profile.json")
+}
+
+// profilePath 校验档案名称并返回档案文件路径
+func (c *ConfigService) profilePath(name string) (string, error) {
+	if c.configDir == "" {
+		return "", fmt.Errorf("service not initialized")
+	}
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+	return filepath.Join(c.profilesDir(), name+".json"), nil
+}
+
+// validateProfileName 校验档案名称，名称将直接用作文件名
+// 允许字母（含中文）、数字、空格、"-"、"_" 和 "."，不能以 "." 开头
+func validateProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if len([]rune(name)) > maxProfileNameLength {
+		return fmt.Errorf("profile name is too long (max %d characters)", maxProfileNameLength)
+	}
+	if strings.HasPrefix(name, ".") || name != strings.TrimSpace(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '-' || r == '_' || r == '.' {
+			continue
+		}
+		return fmt.Errorf("invalid profile name %q: only letters, digits, spaces, '-', '_' and '.' are allowed", name)
+	}
+	return nil
+}
+
+// SaveProfile 保存命名配置档案，敏感信息与主配置一样加密存储
+// 同名档案会被覆盖
+func (c *ConfigService) SaveProfile(name string, settingsJSON string) error {
+	filePath, err := c.profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := c.encodeSettings(settingsJSON)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.profilesDir(), 0700); err != nil {
+		return fmt.Errorf("failed to create profiles dir: %w", err)
+	}
+
+	// 先写临时文件再原子替换，避免写入中断导致档案损坏
+	tmpFile := filePath + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write profile file: %w", err)
+	}
+	if err := os.Rename(tmpFile, filePath); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to save profile file: %w", err)
+	}
+
+	return nil
+}
+
+// ListProfiles 列出全部配置档案
+// 返回 JSON：{"profiles": [ConfigProfile...], "active": "当前档案名称"}
+func (c *ConfigService) ListProfiles() (string, error) {
+	if c.configDir == "" {
+		return "", fmt.Errorf("service not initialized")
+	}
+
+	active := c.ActiveProfile()
+	profiles := make([]ConfigProfile, 0)
+
+	entries, err := os.ReadDir(c.profilesDir())
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read profiles dir: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".json")
+		if validateProfileName(name) != nil {
+			continue
+		}
+
+		profile := ConfigProfile{Name: name, Active: name == active}
+		if info, err := entry.Info(); err == nil {
+			profile.UpdatedAt = info.ModTime().UnixMilli()
+		}
+
+		// 只读取提供商字段，无需解密
+		if data, err := os.ReadFile(filepath.Join(c.profilesDir(), entry.Name())); err == nil {
+			var settings types.Settings
+			if err := json.Unmarshal(data, &settings); err != nil {
+				fmt.Printf("[ConfigService] Warning: invalid profile file %s: %v\n", entry.Name(), err)
+				continue
+			}
+			profile.Provider = settings.AI.Provider
+		}
+
+		profiles = append(profiles, profile)
+	}
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+
+	result, err := json.Marshal(map[string]interface{}{
+		"profiles": profiles,
+		"active":   active,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize profiles: %w", err)
+	}
+	return string(result), nil
+}
+
+// ActivateProfile 将配置档案写入为当前生效的设置，并记录当前档案名称
+// 设置写入后发送 config:changed 事件
+func (c *ConfigService) ActivateProfile(name string) error {
+	filePath, err := c.profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+		return fmt.Errorf("failed to read profile file: %w", err)
+	}
+
+	// 解密后再通过 SaveSettings 写入，确保与主配置的格式和加密方式一致
+	settingsJSON, err := c.decodeSettings(data)
+	if err != nil {
+		return fmt.Errorf("invalid profile file: %w", err)
+	}
+	if err := c.SaveSettings(settingsJSON); err != nil {
+		return err
+	}
+
+	return c.setActiveProfile(name)
+}
+
+// DeleteProfile 删除配置档案
+// 删除当前启用的档案时同时清除启用状态，当前生效的设置保持不变
+func (c *ConfigService) DeleteProfile(name string) error {
+	filePath, err := c.profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filePath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("profile not found: %s", name)
+		}
+		return fmt.Errorf("failed to delete profile file: %w", err)
+	}
+
+	if c.ActiveProfile() == name {
+		return c.setActiveProfile("")
+	}
+	return nil
+}
+
+// ActiveProfile 返回当前启用的档案名称，未启用任何档案时返回空字符串
+func (c *ConfigService) ActiveProfile() string {
+	if c.configDir == "" {
+		return ""
+	}
+
+	data, err := os.ReadFile(c.activeProfileFile())
+	if err != nil {
+		return ""
+	}
+
+	var state activeProfileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		fmt.Printf("[ConfigService] Warning: invalid active profile file: %v\n", err)
+		return ""
+	}
+	return state.Active
+}
+
+// setActiveProfile 持久化当前启用的档案名称，name 为空时清除
+func (c *ConfigService) setActiveProfile(name string) error {
+	if name == "" {
+		if err := os.Remove(c.activeProfileFile()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear active profile: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(activeProfileState{Active: name})
+	if err != nil {
+		return fmt.Errorf("failed to serialize active profile: %w", err)
+	}
+
+	tmpFile := c.activeProfileFile() + ".tmp"
+	if err := os.WriteFile(tmpFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write active profile: %w", err)
+	}
+	if err := os.Rename(tmpFile, c.activeProfileFile()); err != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("failed to save active profile: %w", err)
+	}
+	return nil
+}
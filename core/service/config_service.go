@@ -1,6 +1,7 @@
 package service
 
 import (
+	"artifex/core/provider"
 	"artifex/core/types"
 	"context"
 	"crypto/aes"
@@ -13,7 +14,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 	"golang.org/x/crypto/pbkdf2"
 )
 
@@ -46,14 +49,18 @@ func getExecutableDir() (string, error) {
 func (c *ConfigService) Startup(ctx context.Context) error {
 	c.ctx = ctx
 
-	// 获取执行文件所在目录
-	exeDir, err := getExecutableDir()
+	// 创建应用配置目录（默认在执行文件所在目录下，可通过 ARTIFEX_DATA_DIR 覆盖）
+	// 默认目录不可写时已回退到用户配置目录，通知前端数据的实际位置
+	dataDirInfo, err := ResolveDataDirInfo()
 	if err != nil {
-		return fmt.Errorf("failed to get executable dir: %w", err)
+		return fmt.Errorf("failed to resolve data dir: %w", err)
+	}
+	c.configDir = dataDirInfo.Path
+	if dataDirInfo.Relocated {
+		if infoJSON, err := json.Marshal(dataDirInfo); err == nil {
+			runtime.EventsEmit(ctx, DataDirRelocatedEvent, string(infoJSON))
+		}
 	}
-
-	// 创建应用配置目录（在执行文件所在目录下）
-	c.configDir = filepath.Join(exeDir, "config")
 
 	if err := os.MkdirAll(c.configDir, 0700); err != nil {
 		return fmt.Errorf("failed to create config dir: %w", err)
@@ -152,17 +159,37 @@ func (c *ConfigService) decrypt(ciphertext string) (string, error) {
 }
 
 // SaveSettings 保存设置
+// 保存成功且设置有变化时发送 config:changed 事件（见 ConfigChangedEvent）
 func (c *ConfigService) SaveSettings(settingsJSON string) error {
+	data, err := c.encodeSettings(settingsJSON)
+	if err != nil {
+		return err
+	}
+
+	previous := c.currentSettingsFields()
+
+	// 写入文件
+	if err := os.WriteFile(c.configFile, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+
+	c.emitConfigChanged(changedSettingsKeys(previous, settingsFields([]byte(settingsJSON))))
+
+	return nil
+}
+
+// encodeSettings 解析设置 JSON，加密敏感信息后序列化为待写入文件的数据
+func (c *ConfigService) encodeSettings(settingsJSON string) ([]byte, error) {
 	var settings types.Settings
 	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
-		return fmt.Errorf("invalid settings format: %w", err)
+		return nil, fmt.Errorf("invalid settings format: %w", err)
 	}
 
 	// 加密敏感信息
 	if settings.AI.APIKey != "" {
 		encrypted, err := c.encrypt(settings.AI.APIKey)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt API key: %w", err)
+			return nil, fmt.Errorf("failed to encrypt API key: %w", err)
 		}
 		settings.AI.APIKey = encrypted
 	}
@@ -170,7 +197,7 @@ func (c *ConfigService) SaveSettings(settingsJSON string) error {
 	if settings.AI.VertexCredentials != "" {
 		encrypted, err := c.encrypt(settings.AI.VertexCredentials)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt Vertex credentials: %w", err)
+			return nil, fmt.Errorf("failed to encrypt Vertex credentials: %w", err)
 		}
 		settings.AI.VertexCredentials = encrypted
 	}
@@ -178,7 +205,7 @@ func (c *ConfigService) SaveSettings(settingsJSON string) error {
 	if settings.AI.OpenAIAPIKey != "" {
 		encrypted, err := c.encrypt(settings.AI.OpenAIAPIKey)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt OpenAI API key: %w", err)
+			return nil, fmt.Errorf("failed to encrypt OpenAI API key: %w", err)
 		}
 		settings.AI.OpenAIAPIKey = encrypted
 	}
@@ -186,7 +213,7 @@ func (c *ConfigService) SaveSettings(settingsJSON string) error {
 	if settings.AI.OpenAIImageAPIKey != "" {
 		encrypted, err := c.encrypt(settings.AI.OpenAIImageAPIKey)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt OpenAI Image API key: %w", err)
+			return nil, fmt.Errorf("failed to encrypt OpenAI Image API key: %w", err)
 		}
 		settings.AI.OpenAIImageAPIKey = encrypted
 	}
@@ -194,27 +221,66 @@ func (c *ConfigService) SaveSettings(settingsJSON string) error {
 	if settings.AI.CloudToken != "" {
 		encrypted, err := c.encrypt(settings.AI.CloudToken)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt Cloud token: %w", err)
+			return nil, fmt.Errorf("failed to encrypt Cloud token: %w", err)
 		}
 		settings.AI.CloudToken = encrypted
 	}
 
+	if settings.AI.StabilityAPIKey != "" {
+		encrypted, err := c.encrypt(settings.AI.StabilityAPIKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt Stability API key: %w", err)
+		}
+		settings.AI.StabilityAPIKey = encrypted
+	}
+
+	if settings.AI.ReplicateAPIToken != "" {
+		encrypted, err := c.encrypt(settings.AI.ReplicateAPIToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt Replicate API token: %w", err)
+		}
+		settings.AI.ReplicateAPIToken = encrypted
+	}
+
 	// 序列化
 	data, err := json.MarshalIndent(settings, "", "  ")
 	if err != nil {
-		return fmt.Errorf("failed to serialize settings: %w", err)
-	}
-
-	// 写入文件
-	if err := os.WriteFile(c.configFile, data, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %w", err)
+		return nil, fmt.Errorf("failed to serialize settings: %w", err)
 	}
 
-	return nil
+	return data, nil
 }
 
 // LoadSettings 加载设置
+// 加载时会校验并修正设置（补全缺失字段、重置非法取值），修正内容记录在日志中
 func (c *ConfigService) LoadSettings() (string, error) {
+	settings, corrections, err := c.LoadSettingsDetailed()
+	for _, correction := range corrections {
+		fmt.Printf("[ConfigService] Warning: %s\n", correction)
+	}
+	return settings, err
+}
+
+// loadAISettings 加载设置并解析出 AI 配置（内部方法）
+func (c *ConfigService) loadAISettings() (types.AISettings, error) {
+	settingsJSON, err := c.LoadSettings()
+	if err != nil {
+		return types.AISettings{}, fmt.Errorf("failed to load settings: %w", err)
+	}
+
+	var settings types.Settings
+	if err := json.Unmarshal([]byte(settingsJSON), &settings); err != nil {
+		return types.AISettings{}, fmt.Errorf("failed to parse settings: %w", err)
+	}
+	return settings.AI, nil
+}
+
+// LoadSettingsDetailed 加载设置并返回所做的修正
+// - 缺失字段（如旧版本配置文件）使用默认值补全
+// - 未知字段被忽略，非法取值重置为默认值
+// - 文件完全无法解析时备份为 config.json.bak-<时间戳> 并重新生成默认配置
+// 存在修正时会将修正后的设置写回配置文件
+func (c *ConfigService) LoadSettingsDetailed() (string, []string, error) {
 	// 检查文件是否存在
 	if _, err := os.Stat(c.configFile); os.IsNotExist(err) {
 		// 首次启动：创建默认配置文件
@@ -223,7 +289,7 @@ func (c *ConfigService) LoadSettings() (string, error) {
 			// 保存失败不阻塞，仍然返回默认设置
 			fmt.Printf("[ConfigService] Warning: failed to create default config file: %v\n", saveErr)
 		}
-		return defaultSettings, nil
+		return defaultSettings, nil, nil
 	}
 
 	// 读取文件
@@ -231,14 +297,54 @@ func (c *ConfigService) LoadSettings() (string, error) {
 	if err != nil {
 		// 读取失败，返回默认设置
 		fmt.Printf("[ConfigService] Warning: failed to read config file: %v\n", err)
-		return c.getDefaultSettings(), nil
+		return c.getDefaultSettings(), nil, nil
 	}
 
+	normalized, corrections, err := normalizeSettingsData(data)
+	if err != nil {
+		// 解析失败：备份原文件后重新生成默认配置，不阻塞启动
+		settings, correction := c.resetCorruptSettings(err)
+		return settings, []string{correction}, nil
+	}
+
+	if len(corrections) > 0 {
+		if err := os.WriteFile(c.configFile, normalized, 0600); err != nil {
+			fmt.Printf("[ConfigService] Warning: failed to write corrected config file: %v\n", err)
+		}
+	}
+
+	result, err := c.decodeSettings(normalized)
+	if err != nil {
+		return "", corrections, err
+	}
+
+	return result, corrections, nil
+}
+
+// resetCorruptSettings 备份无法解析的配置文件并重新生成默认配置
+// 返回默认设置和对应的修正说明
+func (c *ConfigService) resetCorruptSettings(parseErr error) (string, string) {
+	correction := fmt.Sprintf("invalid config file format (%v), reset to defaults", parseErr)
+
+	backupFile := fmt.Sprintf("%s.bak-%d", c.configFile, time.Now().Unix())
+	if err := os.Rename(c.configFile, backupFile); err != nil {
+		fmt.Printf("[ConfigService] Warning: failed to back up config file: %v\n", err)
+	} else {
+		correction = fmt.Sprintf("invalid config file format (%v), backed up to %s and reset to defaults", parseErr, filepath.Base(backupFile))
+	}
+
+	defaultSettings := c.getDefaultSettings()
+	if err := c.SaveSettings(defaultSettings); err != nil {
+		fmt.Printf("[ConfigService] Warning: failed to create default config file: %v\n", err)
+	}
+	return defaultSettings, correction
+}
+
+// decodeSettings 解析设置文件数据并解密敏感信息，返回设置 JSON
+func (c *ConfigService) decodeSettings(data []byte) (string, error) {
 	var settings types.Settings
 	if err := json.Unmarshal(data, &settings); err != nil {
-		// 解析失败，返回默认设置
-		fmt.Printf("[ConfigService] Warning: invalid config file format: %v\n", err)
-		return c.getDefaultSettings(), nil
+		return "", err
 	}
 
 	// 解密敏感信息
@@ -288,6 +394,24 @@ func (c *ConfigService) LoadSettings() (string, error) {
 		}
 	}
 
+	if settings.AI.StabilityAPIKey != "" {
+		decrypted, err := c.decrypt(settings.AI.StabilityAPIKey)
+		if err != nil {
+			settings.AI.StabilityAPIKey = ""
+		} else {
+			settings.AI.StabilityAPIKey = decrypted
+		}
+	}
+
+	if settings.AI.ReplicateAPIToken != "" {
+		decrypted, err := c.decrypt(settings.AI.ReplicateAPIToken)
+		if err != nil {
+			settings.AI.ReplicateAPIToken = ""
+		} else {
+			settings.AI.ReplicateAPIToken = decrypted
+		}
+	}
+
 	// 重新序列化（包含解密后的数据）
 	result, err := json.Marshal(settings)
 	if err != nil {
@@ -299,7 +423,13 @@ func (c *ConfigService) LoadSettings() (string, error) {
 
 // getDefaultSettings 获取默认设置
 func (c *ConfigService) getDefaultSettings() string {
-	defaults := types.Settings{
+	data, _ := json.Marshal(defaultSettings())
+	return string(data)
+}
+
+// defaultSettings 返回默认设置结构
+func defaultSettings() types.Settings {
+	return types.Settings{
 		Version: "1.0.0",
 		AI: types.AISettings{
 			Provider:   "gemini",
@@ -314,13 +444,40 @@ func (c *ConfigService) getDefaultSettings() string {
 			OpenAIBaseURL:    "https://api.openai.com/v1",
 			OpenAITextModel:  "gpt-4o",
 			OpenAIImageModel: "dall-e-3",
+			OpenAIImageMode:  types.OpenAIImageModeAuto,
 
 			// Cloud 云服务默认配置
 			CloudEndpointURL: "",
 			CloudToken:       "",
+
+			// Stability AI 默认配置
+			StabilityBaseURL: "https://api.stability.ai",
+			StabilityModel:   "core",
+
+			// 本地 Stable Diffusion 默认配置
+			LocalEndpointURL: "http://127.0.0.1:7860",
+			LocalSampler:     "Euler a",
+			LocalSteps:       30,
+
+			// Replicate 默认配置
+			ReplicateModel: "black-forest-labs/flux-schnell",
+
+			// 并发控制默认配置
+			MaxConcurrentRequests: defaultMaxConcurrentRequests,
+
+			// 提供商 HTTP 连接默认配置
+			ProviderIdleConnTimeout: provider.DefaultProviderIdleConnTimeout,
+			ProviderMaxIdleConns:    provider.DefaultProviderMaxIdleConns,
+			ProviderDialTimeout:     provider.DefaultProviderDialTimeout,
+
+			// 有损重新编码默认质量
+			StoredImageQuality: defaultSaveQuality,
+
+			// 编辑提示词改写默认关闭，需在设置中显式开启
+			PromptRewriteEnabled: false,
+
+			// 文件日志默认开启，便于排查用户机器上的问题
+			FileLoggingEnabled: true,
 		},
 	}
-
-	data, _ := json.Marshal(defaults)
-	return string(data)
 }
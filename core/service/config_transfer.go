@@ -0,0 +1,200 @@
+package service
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// settingsExportFormat 设置导出文件的格式标识
+const settingsExportFormat = "artifex-settings"
+
+// settingsExportVersion 设置导出文件的格式版本
+const settingsExportVersion = 1
+
+// SettingsExport 设置导出文件结构
+// 包含密钥时，密钥字段保持加密状态，仅能在同一台机器（相同加密密钥）上解密
+type SettingsExport struct {
+	Format          string         `json:"format"`
+	Version         int            `json:"version"`
+	ExportedAt      int64          `json:"exportedAt"`               // 导出时间（毫秒）
+	IncludesSecrets bool           `json:"includesSecrets"`          // 是否包含（加密的）密钥字段
+	OmittedSecrets  []string       `json:"omittedSecrets,omitempty"` // 未包含时，导出前已配置的密钥字段
+	Settings        types.Settings `json:"settings"`
+}
+
+// SettingsImportResult 设置导入结果
+type SettingsImportResult struct {
+	Settings string `json:"settings"` // 导入并生效的设置 JSON（已解密）
+	// 需要重新填写的密钥字段（导出时未包含，或无法在本机解密）
+	MissingSecrets []string `json:"missingSecrets"`
+}
+
+// secretField 设置中加密存储的字段
+type secretField struct {
+	name  string // JSON 字段名
+	value *string
+}
+
+// secretFields 返回设置中所有加密存储的字段
+func secretFields(settings *types.AISettings) []secretField {
+	return []secretField{
+		{name: "apiKey", value: &settings.APIKey},
+		{name: "vertexCredentials", value: &settings.VertexCredentials},
+		{name: "openaiApiKey", value: &settings.OpenAIAPIKey},
+		{name: "openaiImageApiKey", value: &settings.OpenAIImageAPIKey},
+		{name: "cloudToken", value: &settings.CloudToken},
+		{name: "stabilityApiKey", value: &settings.StabilityAPIKey},
+		{name: "replicateApiToken", value: &settings.ReplicateAPIToken},
+	}
+}
+
+// ExportSettings 导出当前设置
+// includeSecrets 为 false 时清空所有密钥字段；为 true 时保留加密后的密钥，
+// 导入时只有相同机器才能解密，其他机器需要重新填写
+func (c *ConfigService) ExportSettings(includeSecrets bool) (string, error) {
+	if c.configFile == "" {
+		return "", fmt.Errorf("service not initialized")
+	}
+
+	var settings types.Settings
+	data, err := os.ReadFile(c.configFile)
+	if os.IsNotExist(err) {
+		data = []byte(c.getDefaultSettings())
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return "", fmt.Errorf("invalid config file format: %w", err)
+	}
+
+	// 配置文件中的密钥本身就是加密存储的，直接导出密文
+	var omitted []string
+	if !includeSecrets {
+		for _, field := range secretFields(&settings.AI) {
+			if *field.value != "" {
+				omitted = append(omitted, field.name)
+			}
+			*field.value = ""
+		}
+	}
+
+	result, err := json.MarshalIndent(SettingsExport{
+		Format:          settingsExportFormat,
+		Version:         settingsExportVersion,
+		ExportedAt:      time.Now().UnixMilli(),
+		IncludesSecrets: includeSecrets,
+		OmittedSecrets:  omitted,
+		Settings:        settings,
+	}, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize settings: %w", err)
+	}
+	return string(result), nil
+}
+
+// ImportSettings 导入设置并写入为当前设置
+// 导入前校验 JSON 结构（不允许未知字段）和提供商名称；
+// 导出时未包含或无法在本机解密的密钥保留本机当前的取值，
+// 本机也未配置时在结果的 missingSecrets 中列出以便重新填写
+func (c *ConfigService) ImportSettings(exportJSON string) (*SettingsImportResult, error) {
+	var export SettingsExport
+	decoder := json.NewDecoder(bytes.NewReader([]byte(exportJSON)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&export); err != nil {
+		return nil, fmt.Errorf("invalid settings file: %w", err)
+	}
+
+	if export.Format != settingsExportFormat {
+		return nil, fmt.Errorf("invalid settings file: unexpected format %q", export.Format)
+	}
+	if export.Version > settingsExportVersion {
+		return nil, fmt.Errorf("settings file version %d is newer than supported version %d", export.Version, settingsExportVersion)
+	}
+	if err := validateImportedSettings(&export.Settings); err != nil {
+		return nil, err
+	}
+
+	// 读取本机当前的密钥（已解密），导入文件缺少的密钥沿用本机取值
+	var local types.AISettings
+	if _, err := os.Stat(c.configFile); err == nil {
+		if local, err = c.loadAISettings(); err != nil {
+			fmt.Printf("[ConfigService] Warning: failed to load current secrets before import: %v\n", err)
+		}
+	}
+	omitted := make(map[string]bool, len(export.OmittedSecrets))
+	if !export.IncludesSecrets {
+		for _, name := range export.OmittedSecrets {
+			omitted[name] = true
+		}
+	}
+
+	result := &SettingsImportResult{MissingSecrets: make([]string, 0)}
+	localFields := secretFields(&local)
+	for i, field := range secretFields(&export.Settings.AI) {
+		localValue := *localFields[i].value
+		if *field.value == "" {
+			*field.value = localValue
+			if localValue == "" && omitted[field.name] {
+				result.MissingSecrets = append(result.MissingSecrets, field.name)
+			}
+			continue
+		}
+		decrypted, err := c.decrypt(*field.value)
+		if err != nil {
+			// 加密密钥与本机不一致，沿用本机取值；本机也未配置时需要重新填写
+			*field.value = localValue
+			if localValue == "" {
+				result.MissingSecrets = append(result.MissingSecrets, field.name)
+			}
+			continue
+		}
+		*field.value = decrypted
+	}
+
+	settingsJSON, err := json.Marshal(export.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize settings: %w", err)
+	}
+	if err := c.SaveSettings(string(settingsJSON)); err != nil {
+		return nil, err
+	}
+
+	result.Settings = string(settingsJSON)
+	return result, nil
+}
+
+// validateImportedSettings 校验导入设置中的基本取值
+func validateImportedSettings(settings *types.Settings) error {
+	switch settings.AI.Provider {
+	case "gemini", "openai", "cloud", "stability", "local", "replicate":
+	default:
+		return fmt.Errorf("invalid settings file: unsupported provider %q", settings.AI.Provider)
+	}
+
+	switch settings.AI.OpenAIImageMode {
+	case "", types.OpenAIImageModeAuto, types.OpenAIImageModeImageAPI, types.OpenAIImageModeChat:
+	default:
+		return fmt.Errorf("invalid settings file: unsupported openaiImageMode %q", settings.AI.OpenAIImageMode)
+	}
+
+	switch settings.AI.StoredImageFormat {
+	case "", types.StoredImageFormatPNG, types.StoredImageFormatJPEG:
+	default:
+		return fmt.Errorf("invalid settings file: unsupported storedImageFormat %q", settings.AI.StoredImageFormat)
+	}
+
+	if settings.AI.StoredImageQuality < 0 || settings.AI.StoredImageQuality > 100 {
+		return fmt.Errorf("invalid settings file: storedImageQuality must be between 0 and 100")
+	}
+
+	if settings.AI.MaxConcurrentRequests < 0 || settings.AI.ResultCacheMaxEntries < 0 || settings.AI.LocalSteps < 0 || settings.AI.MaxInputImageBytes < 0 ||
+		settings.AI.ProviderIdleConnTimeout < 0 || settings.AI.ProviderMaxIdleConns < 0 || settings.AI.ProviderDialTimeout < 0 {
+		return fmt.Errorf("invalid settings file: numeric limits must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"artifex/core/types"
+)
+
+func TestImportSettingsKeepsLocalSecrets(t *testing.T) {
+	c := newTestConfigService(t, func(settings *types.Settings) {
+		settings.AI.APIKey = "local-gemini-key"
+		settings.AI.StabilityAPIKey = "local-stability-key"
+	})
+
+	// 另一台机器的导出：不含密钥，但导出前配置了 apiKey 和 openaiApiKey
+	export := SettingsExport{
+		Format:          settingsExportFormat,
+		Version:         settingsExportVersion,
+		IncludesSecrets: false,
+		OmittedSecrets:  []string{"apiKey", "openaiApiKey"},
+		Settings:        defaultSettings(),
+	}
+	export.Settings.AI.Provider = "openai"
+	exportJSON, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("marshal export: %v", err)
+	}
+
+	result, err := c.ImportSettings(string(exportJSON))
+	if err != nil {
+		t.Fatalf("ImportSettings: %v", err)
+	}
+	if want := []string{"openaiApiKey"}; !reflect.DeepEqual(result.MissingSecrets, want) {
+		t.Fatalf("expected missing secrets %v, got %v", want, result.MissingSecrets)
+	}
+
+	saved, err := c.loadAISettings()
+	if err != nil {
+		t.Fatalf("load settings: %v", err)
+	}
+	if saved.Provider != "openai" {
+		t.Fatalf("expected imported provider openai, got %q", saved.Provider)
+	}
+	if saved.APIKey != "local-gemini-key" || saved.StabilityAPIKey != "local-stability-key" {
+		t.Fatalf("import wiped local secrets: apiKey %q, stabilityApiKey %q", saved.APIKey, saved.StabilityAPIKey)
+	}
+}
+
+func TestImportSettingsUndecryptableSecret(t *testing.T) {
+	c := newTestConfigService(t, func(settings *types.Settings) {
+		settings.AI.APIKey = "local-gemini-key"
+	})
+
+	export := SettingsExport{
+		Format:          settingsExportFormat,
+		Version:         settingsExportVersion,
+		IncludesSecrets: true,
+		Settings:        defaultSettings(),
+	}
+	export.Settings.AI.APIKey = "not-decryptable-here"
+	export.Settings.AI.OpenAIAPIKey = "not-decryptable-either"
+	exportJSON, err := json.Marshal(export)
+	if err != nil {
+		t.Fatalf("marshal export: %v", err)
+	}
+
+	result, err := c.ImportSettings(string(exportJSON))
+	if err != nil {
+		t.Fatalf("ImportSettings: %v", err)
+	}
+	if want := []string{"openaiApiKey"}; !reflect.DeepEqual(result.MissingSecrets, want) {
+		t.Fatalf("expected missing secrets %v, got %v", want, result.MissingSecrets)
+	}
+	saved, err := c.loadAISettings()
+	if err != nil {
+		t.Fatalf("load settings: %v", err)
+	}
+	if saved.APIKey != "local-gemini-key" {
+		t.Fatalf("expected the local apiKey to be kept, got %q", saved.APIKey)
+	}
+	if saved.OpenAIAPIKey != "" {
+		t.Fatalf("expected openaiApiKey to stay empty, got %q", saved.OpenAIAPIKey)
+	}
+}
@@ -2,18 +2,49 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+const (
+	// defaultRequestExpiry 默认的请求过期时间，超过该时长仍未清理的请求会被取消
+	defaultRequestExpiry = 1 * time.Hour
+	// defaultCleanupInterval 默认的过期请求清理间隔
+	defaultCleanupInterval = 5 * time.Minute
+)
+
+// 请求操作类型，用于区分不同功能的请求 context
+// 前端在不同功能间复用同一请求 ID 时，各操作的 context 互不影响（如增强提示词不会取消图像生成）
+const (
+	requestOpGenerate         = "gen"  // 图像生成（单张和批量）
+	requestOpEdit             = "edit" // 图像编辑
+	requestOpRemoveBackground = "rmbg" // 背景移除
+	requestOpEnhance          = "enh"  // 提示词增强
+)
+
+// requestKey 请求 context 的键：同一请求 ID 在不同操作下是不同的请求
+type requestKey struct {
+	operation string
+	requestID string
+}
+
 // ContextManager 管理每个请求的 context，支持主动取消
 type ContextManager struct {
-	// 存储每个请求 ID 对应的 context 和 cancel 函数
-	contexts map[string]contextWithCancel
+	// 存储每个（操作，请求 ID）对应的 context 和 cancel 函数
+	contexts map[requestKey]contextWithCancel
 	mu       sync.RWMutex
 	// 基础 context（应用启动时的 context）
 	baseCtx context.Context
+
+	// ExpiryThreshold 请求过期时间（需在 StartCleanupRoutine 之前设置）
+	ExpiryThreshold time.Duration
+	// CleanupInterval 过期请求清理间隔（需在 StartCleanupRoutine 之前设置）
+	CleanupInterval time.Duration
 }
 
 // contextWithCancel 存储 context 和 cancel 函数
@@ -24,43 +55,77 @@ type contextWithCancel struct {
 	createdAt time.Time
 }
 
+// ActiveRequest 正在跟踪的请求信息
+type ActiveRequest struct {
+	RequestID string `json:"requestId"`
+	Operation string `json:"operation"` // 操作类型（"gen"、"edit"、"rmbg"、"enh"）
+	CreatedAt int64  `json:"createdAt"` // 创建时间（Unix 毫秒）
+	AgeMs     int64  `json:"ageMs"`     // 已存在时长（毫秒）
+}
+
 // NewContextManager 创建 Context 管理器
 func NewContextManager(baseCtx context.Context) *ContextManager {
 	return &ContextManager{
-		contexts: make(map[string]contextWithCancel),
-		baseCtx:  baseCtx,
+		contexts:        make(map[requestKey]contextWithCancel),
+		baseCtx:         baseCtx,
+		ExpiryThreshold: defaultRequestExpiry,
+		CleanupInterval: defaultCleanupInterval,
 	}
 }
 
-// CreateRequestContext 为请求创建新的 context
-// 返回请求 ID 和对应的 context
-func (cm *ContextManager) CreateRequestContext(requestID string) (context.Context, error) {
+// requestIDCounter 随机源不可用时用于生成请求 ID 的计数器
+var requestIDCounter uint64
+
+// NewRequestID 生成服务端请求 ID（格式 req_<16 位十六进制>）
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		// 随机源不可用时退化为时间戳 + 计数器，仍保证进程内唯一
+		return fmt.Sprintf("req_%x_%d", time.Now().UnixNano(), atomic.AddUint64(&requestIDCounter, 1))
+	}
+	return "req_" + hex.EncodeToString(buf)
+}
+
+// ensureRequestID 调用方未提供请求 ID 时生成一个新的 ID
+func ensureRequestID(requestID string) string {
+	if requestID == "" {
+		return NewRequestID()
+	}
+	return requestID
+}
+
+// CreateRequestContext 为 operation 操作的请求创建新的 context
+// 同一操作下请求 ID 已存在时会先取消旧请求的 context：前端复用同一 ID 发起新请求，
+// 相当于放弃（停止）上一次请求；其他操作下相同 ID 的请求不受影响。
+// 需要并行的同类请求必须使用不同的 ID，或者传空字符串由 AIService 通过 NewRequestID 生成
+func (cm *ContextManager) CreateRequestContext(operation, requestID string) (context.Context, error) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	// 如果请求 ID 已存在，先取消旧的 context
-	if existing, ok := cm.contexts[requestID]; ok {
+	key := requestKey{operation: operation, requestID: requestID}
+	if existing, ok := cm.contexts[key]; ok {
 		existing.cancel()
 	}
 
 	// 创建新的 context（基于 baseCtx）
 	ctx, cancel := context.WithCancel(cm.baseCtx)
 
-	cm.contexts[requestID] = contextWithCancel{
-		ctx:        ctx,
-		cancel:     cancel,
-		createdAt:  time.Now(),
+	cm.contexts[key] = contextWithCancel{
+		ctx:       ctx,
+		cancel:    cancel,
+		createdAt: time.Now(),
 	}
 
 	return ctx, nil
 }
 
-// GetRequestContext 获取请求的 context
-func (cm *ContextManager) GetRequestContext(requestID string) (context.Context, bool) {
+// GetRequestContext 获取 operation 操作下请求的 context
+func (cm *ContextManager) GetRequestContext(operation, requestID string) (context.Context, bool) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
-	ctxWithCancel, ok := cm.contexts[requestID]
+	ctxWithCancel, ok := cm.contexts[requestKey{operation: operation, requestID: requestID}]
 	if !ok {
 		return nil, false
 	}
@@ -68,58 +133,118 @@ func (cm *ContextManager) GetRequestContext(requestID string) (context.Context,
 	return ctxWithCancel.ctx, true
 }
 
-// CancelRequest 取消指定请求的 context
+// CancelRequest 取消指定请求 ID 的 context，该 ID 在多个操作下都有请求时全部取消
 func (cm *ContextManager) CancelRequest(requestID string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	ctxWithCancel, ok := cm.contexts[requestID]
-	if !ok {
+	found := false
+	for key, ctxWithCancel := range cm.contexts {
+		if key.requestID != requestID {
+			continue
+		}
+		ctxWithCancel.cancel()
+		delete(cm.contexts, key)
+		found = true
+	}
+	if !found {
 		return fmt.Errorf("request ID %s not found", requestID)
 	}
 
-	ctxWithCancel.cancel()
-	delete(cm.contexts, requestID)
-
 	return nil
 }
 
-// CleanupRequest 清理指定请求的 context（请求完成后调用）
-func (cm *ContextManager) CleanupRequest(requestID string) {
+// CleanupRequest 清理 operation 操作下指定请求的 context（请求完成后调用）
+// ctx 为 CreateRequestContext 返回的 context：请求已被复用同一 ID 的新请求替换时，
+// 登记的是新请求的 context，此时不做任何处理，避免旧请求结束时取消新请求
+func (cm *ContextManager) CleanupRequest(operation, requestID string, ctx context.Context) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
-	if ctxWithCancel, ok := cm.contexts[requestID]; ok {
+	key := requestKey{operation: operation, requestID: requestID}
+	if ctxWithCancel, ok := cm.contexts[key]; ok && ctxWithCancel.ctx == ctx {
 		// 确保 cancel 函数被调用
 		ctxWithCancel.cancel()
-		delete(cm.contexts, requestID)
+		delete(cm.contexts, key)
 	}
 }
 
-// CleanupExpiredRequests 清理过期的请求（超过 1 小时未清理的请求）
+// ListActiveRequests 列出所有正在跟踪的请求及其存在时长（按创建时间从早到晚排序）
+func (cm *ContextManager) ListActiveRequests() []ActiveRequest {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	now := time.Now()
+	requests := make([]ActiveRequest, 0, len(cm.contexts))
+	for key, ctxWithCancel := range cm.contexts {
+		requests = append(requests, ActiveRequest{
+			RequestID: key.requestID,
+			Operation: key.operation,
+			CreatedAt: ctxWithCancel.createdAt.UnixMilli(),
+			AgeMs:     now.Sub(ctxWithCancel.createdAt).Milliseconds(),
+		})
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].CreatedAt < requests[j].CreatedAt
+	})
+
+	return requests
+}
+
+// CancelAll 取消并清除所有正在跟踪的请求 context
+// 返回被取消的请求数量
+func (cm *ContextManager) CancelAll() int {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	count := len(cm.contexts)
+	for key, ctxWithCancel := range cm.contexts {
+		ctxWithCancel.cancel()
+		delete(cm.contexts, key)
+	}
+
+	return count
+}
+
+// CleanupExpiredRequests 清理过期的请求（超过 ExpiryThreshold 未清理的请求）
 func (cm *ContextManager) CleanupExpiredRequests() {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	now := time.Now()
-	expiredThreshold := 1 * time.Hour
+	expiredThreshold := cm.ExpiryThreshold
+	if expiredThreshold <= 0 {
+		expiredThreshold = defaultRequestExpiry
+	}
 
-	for requestID, ctxWithCancel := range cm.contexts {
+	for key, ctxWithCancel := range cm.contexts {
 		if now.Sub(ctxWithCancel.createdAt) > expiredThreshold {
 			ctxWithCancel.cancel()
-			delete(cm.contexts, requestID)
+			delete(cm.contexts, key)
 		}
 	}
 }
 
 // StartCleanupRoutine 启动定期清理协程
-func (cm *ContextManager) StartCleanupRoutine() {
+// ctx 结束时协程退出
+func (cm *ContextManager) StartCleanupRoutine(ctx context.Context) {
+	interval := cm.CleanupInterval
+	if interval <= 0 {
+		interval = defaultCleanupInterval
+	}
+
 	go func() {
-		ticker := time.NewTicker(30 * time.Minute)
+		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			cm.CleanupExpiredRequests()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				cm.CleanupExpiredRequests()
+			}
 		}
 	}()
 }
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnsureRequestIDGeneratesDistinctContexts(t *testing.T) {
+	cm := NewContextManager(context.Background())
+
+	firstID := ensureRequestID("")
+	secondID := ensureRequestID("")
+	if firstID == "" || secondID == "" {
+		t.Fatalf("expected generated request IDs, got %q and %q", firstID, secondID)
+	}
+	if firstID == secondID {
+		t.Fatalf("expected distinct request IDs, both were %q", firstID)
+	}
+
+	first, _ := cm.CreateRequestContext(requestOpGenerate, firstID)
+	second, _ := cm.CreateRequestContext(requestOpGenerate, secondID)
+	if first.Err() != nil {
+		t.Fatalf("first context was cancelled by the second request: %v", first.Err())
+	}
+	if second.Err() != nil {
+		t.Fatalf("second context is already cancelled: %v", second.Err())
+	}
+	if got := len(cm.ListActiveRequests()); got != 2 {
+		t.Fatalf("expected 2 active requests, got %d", got)
+	}
+}
+
+func TestReusedRequestIDCancelsPreviousRequest(t *testing.T) {
+	cm := NewContextManager(context.Background())
+
+	previous, _ := cm.CreateRequestContext(requestOpGenerate, "req")
+	current, _ := cm.CreateRequestContext(requestOpGenerate, "req")
+	if previous.Err() == nil {
+		t.Fatal("expected the superseded request to be cancelled")
+	}
+
+	// 被替换的请求结束时执行的清理不能影响新请求
+	cm.CleanupRequest(requestOpGenerate, "req", previous)
+	if current.Err() != nil {
+		t.Fatalf("cleanup of the superseded request cancelled the new request: %v", current.Err())
+	}
+	if got, ok := cm.GetRequestContext(requestOpGenerate, "req"); !ok || got != current {
+		t.Fatal("expected the new request to remain registered")
+	}
+
+	cm.CleanupRequest(requestOpGenerate, "req", current)
+	if current.Err() == nil {
+		t.Fatal("expected cleanup to cancel the finished request")
+	}
+	if _, ok := cm.GetRequestContext(requestOpGenerate, "req"); ok {
+		t.Fatal("expected the finished request to be removed")
+	}
+}
+
+func TestRequestIDsAreNamespacedByOperation(t *testing.T) {
+	cm := NewContextManager(context.Background())
+
+	generate, _ := cm.CreateRequestContext(requestOpGenerate, "req")
+	enhance, _ := cm.CreateRequestContext(requestOpEnhance, "req")
+	if generate.Err() != nil {
+		t.Fatal("an enhance request reusing the ID cancelled the generate request")
+	}
+
+	if err := cm.CancelRequest("req"); err != nil {
+		t.Fatalf("CancelRequest: %v", err)
+	}
+	if generate.Err() == nil || enhance.Err() == nil {
+		t.Fatal("expected CancelRequest to cancel the request in every operation")
+	}
+	if err := cm.CancelRequest("req"); err == nil {
+		t.Fatal("expected an error when cancelling an unknown request ID")
+	}
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DataDirEnv 覆盖应用数据目录的环境变量
+// 适用于安装目录只读或便携部署，如 ARTIFEX_DATA_DIR=/home/me/.artifex
+const DataDirEnv = "ARTIFEX_DATA_DIR"
+
+// DataDirRelocatedEvent 数据目录被迁移到用户配置目录时发送的事件
+// 事件数据为 DataDirInfo 的 JSON 字符串
+const DataDirRelocatedEvent = "config:data-dir-relocated"
+
+// userDataDirName 回退到用户配置目录时使用的子目录名称
+const userDataDirName = "artifexBot"
+
+// DataDirInfo 应用数据目录信息
+type DataDirInfo struct {
+	Path        string `json:"path"`                 // 实际使用的数据目录
+	DefaultPath string `json:"defaultPath"`          // 默认数据目录（执行文件所在目录下的 config）
+	Relocated   bool   `json:"relocated"`            // 默认目录不可写，已回退到用户配置目录
+	Reason      string `json:"reason,omitempty"`     // 回退原因
+	Overridden  bool   `json:"overridden,omitempty"` // 由 SetDataDir 或 ARTIFEX_DATA_DIR 指定
+}
+
+var (
+	dataDirMu       sync.Mutex
+	dataDirOverride string
+	dataDirCache    *DataDirInfo
+)
+
+// SetDataDir 设置应用数据目录，需在各服务 Startup 之前调用
+// 传入空字符串恢复默认行为
+func SetDataDir(dir string) {
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+	dataDirOverride = dir
+	dataDirCache = nil
+}
+
+// ResolveDataDir 获取应用数据目录
+// 各服务与图片资源处理器共用该目录，保证读写的是同一份数据
+func ResolveDataDir() (string, error) {
+	info, err := ResolveDataDirInfo()
+	if err != nil {
+		return "", err
+	}
+	return info.Path, nil
+}
+
+// ResolveDataDirInfo 获取应用数据目录及其来源
+// 优先级：SetDataDir 设置的目录 > ARTIFEX_DATA_DIR 环境变量 > 执行文件所在目录下的 config
+// 默认目录不可写（只读卷、权限不足）时回退到 os.UserConfigDir()/artifexBot，并只记录一次日志
+// 结果会被缓存，保证整个进程使用同一目录
+func ResolveDataDirInfo() (DataDirInfo, error) {
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+
+	if dataDirCache != nil {
+		return *dataDirCache, nil
+	}
+
+	info, err := resolveDataDirInfo(dataDirOverride)
+	if err != nil {
+		return DataDirInfo{}, err
+	}
+	if info.Relocated {
+		fmt.Printf("[ConfigService] Warning: data dir %s is not writable (%s), using %s instead\n", info.DefaultPath, info.Reason, info.Path)
+	}
+
+	dataDirCache = &info
+	return info, nil
+}
+
+// resolveDataDirInfo 计算应用数据目录（内部函数，不做缓存）
+func resolveDataDirInfo(override string) (DataDirInfo, error) {
+	exeDir, err := getExecutableDir()
+	if err != nil {
+		return DataDirInfo{}, err
+	}
+	info := DataDirInfo{DefaultPath: filepath.Join(exeDir, "config")}
+
+	if override == "" {
+		override = strings.TrimSpace(os.Getenv(DataDirEnv))
+	}
+	if override != "" {
+		abs, err := filepath.Abs(override)
+		if err != nil {
+			return DataDirInfo{}, fmt.Errorf("invalid data dir %q: %w", override, err)
+		}
+		// 显式指定的目录不做回退，不可写时由各服务报告错误
+		info.Path = abs
+		info.Overridden = true
+		return info, nil
+	}
+
+	writeErr := checkDirWritable(info.DefaultPath)
+	if writeErr == nil {
+		info.Path = info.DefaultPath
+		return info, nil
+	}
+
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return DataDirInfo{}, fmt.Errorf("data dir %s is not writable (%v) and no user config dir is available: %w", info.DefaultPath, writeErr, err)
+	}
+	fallback := filepath.Join(userConfigDir, userDataDirName)
+	if err := checkDirWritable(fallback); err != nil {
+		return DataDirInfo{}, fmt.Errorf("data dir %s is not writable (%v), fallback %s is not writable either: %w", info.DefaultPath, writeErr, fallback, err)
+	}
+
+	info.Path = fallback
+	info.Relocated = true
+	info.Reason = writeErr.Error()
+	return info, nil
+}
+
+// checkDirWritable 确认目录存在（必要时创建）且可写入文件
+func checkDirWritable(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(dir, ".write-test-*")
+	if err != nil {
+		return err
+	}
+	name := file.Name()
+	file.Close()
+	return os.Remove(name)
+}
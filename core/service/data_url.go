@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// decodeImageDataURL 解析图像 data URL，返回图像数据和 MIME 类型
+// 支持的格式：
+// - data:image/png;base64,<base64>（可带其他参数，如 ;charset=utf-8;base64）
+// - data:image/svg+xml,<百分号编码数据>（不带 ;base64 时按百分号编码解码）
+// - 不带 data: 前缀的纯 base64 数据（MIME 类型默认为 image/png）
+// 声明了其他编码（如 ;base32）时返回错误
+func decodeImageDataURL(dataURL string) ([]byte, string, error) {
+	if !strings.HasPrefix(dataURL, "data:") {
+		data, err := decodeBase64Image(dataURL)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode base64 image: %w", err)
+		}
+		return data, "image/png", nil
+	}
+
+	header, payload, ok := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	if !ok {
+		return nil, "", fmt.Errorf("invalid image data URL: missing ','")
+	}
+
+	params := strings.Split(header, ";")
+	// MIME 类型不区分大小写，统一转为小写，避免 IMAGE/JPEG 等按未知类型保存
+	mimeType := strings.ToLower(strings.TrimSpace(params[0]))
+	if mimeType == "" {
+		mimeType = "image/png" // 默认类型
+	}
+
+	isBase64 := false
+	for _, param := range params[1:] {
+		param = strings.TrimSpace(param)
+		switch {
+		case strings.EqualFold(param, "base64"):
+			isBase64 = true
+		case param == "" || strings.Contains(param, "="):
+			// 形如 charset=utf-8 的参数不影响图像数据
+		default:
+			return nil, "", fmt.Errorf("unsupported data URL encoding %q", param)
+		}
+	}
+
+	if isBase64 {
+		data, err := decodeBase64Image(payload)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to decode base64 image: %w", err)
+		}
+		return data, mimeType, nil
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode percent-encoded image: %w", err)
+	}
+	if decoded == "" {
+		return nil, "", fmt.Errorf("invalid image data URL: empty data")
+	}
+	return []byte(decoded), mimeType, nil
+}
+
+// decodeBase64Image 解码 base64 图像数据，容忍换行等空白字符和缺失的填充
+func decodeBase64Image(encoded string) ([]byte, error) {
+	encoded = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\n' || r == '\r' || r == '\t' {
+			return -1
+		}
+		return r
+	}, encoded)
+	if encoded == "" {
+		return nil, fmt.Errorf("empty data")
+	}
+	if data, err := base64.StdEncoding.DecodeString(encoded); err == nil {
+		return data, nil
+	}
+	return base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeImageDataURL(t *testing.T) {
+	payload := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, 0x10}
+	encoded := base64.StdEncoding.EncodeToString(payload)
+	raw := base64.RawStdEncoding.EncodeToString(payload)
+
+	cases := []struct {
+		name     string
+		input    string
+		wantData []byte
+		wantMime string
+		wantErr  bool
+	}{
+		{name: "base64", input: "data:image/png;base64," + encoded, wantData: payload, wantMime: "image/png"},
+		{name: "charset before base64", input: "data:image/png;charset=utf-8;base64," + encoded, wantData: payload, wantMime: "image/png"},
+		{name: "uppercase base64 marker", input: "data:image/jpeg;BASE64," + encoded, wantData: payload, wantMime: "image/jpeg"},
+		{name: "uppercase mime type", input: "data:IMAGE/JPEG;base64," + encoded, wantData: payload, wantMime: "image/jpeg"},
+		{name: "unknown mime type kept", input: "data:image/x-custom;base64," + encoded, wantData: payload, wantMime: "image/x-custom"},
+		{name: "missing mime type", input: "data:;base64," + encoded, wantData: payload, wantMime: "image/png"},
+		{name: "missing padding", input: "data:image/png;base64," + raw, wantData: payload, wantMime: "image/png"},
+		{name: "line breaks in payload", input: "data:image/png;base64," + encoded[:4] + "\r\n" + encoded[4:], wantData: payload, wantMime: "image/png"},
+		{name: "plain base64 without prefix", input: encoded, wantData: payload, wantMime: "image/png"},
+		{name: "percent encoded without base64", input: "data:image/svg+xml,%3Csvg%20xmlns%3D%22x%22%2F%3E", wantData: []byte(`<svg xmlns="x"/>`), wantMime: "image/svg+xml"},
+		{name: "percent encoded with charset", input: "data:image/svg+xml;charset=utf-8,%3Csvg%2F%3E", wantData: []byte("<svg/>"), wantMime: "image/svg+xml"},
+
+		{name: "missing comma", input: "data:image/png;base64", wantErr: true},
+		{name: "empty base64 payload", input: "data:image/png;base64,", wantErr: true},
+		{name: "empty percent encoded payload", input: "data:image/svg+xml,", wantErr: true},
+		{name: "empty plain input", input: "", wantErr: true},
+		{name: "invalid base64", input: "data:image/png;base64,not*valid*base64!", wantErr: true},
+		{name: "invalid plain base64", input: "%%%%", wantErr: true},
+		{name: "invalid percent encoding", input: "data:image/svg+xml,%zz", wantErr: true},
+		{name: "unsupported encoding", input: "data:image/png;base32,MFRGG===", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, mimeType, err := decodeImageDataURL(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got data %q with MIME %q", data, mimeType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if mimeType != tc.wantMime {
+				t.Fatalf("expected MIME %q, got %q", tc.wantMime, mimeType)
+			}
+			if !bytes.Equal(data, tc.wantData) {
+				t.Fatalf("expected data %v, got %v", tc.wantData, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientSpace 目标磁盘剩余空间不足
+// 通过 checkFreeSpace 返回的错误包装此错误，调用方使用 errors.Is 判断
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// 磁盘空间检查配置
+const (
+	// diskSpaceMargin 写入后磁盘至少保留的剩余空间，避免把磁盘写满影响系统和其他程序
+	diskSpaceMargin = 64 << 20
+	// largeImageSaveBytes 达到该大小的图像保存前检查剩余空间，较小的图像直接写入
+	largeImageSaveBytes = 1 << 20
+)
+
+// checkFreeSpace 检查 path 所在磁盘能否再写入 required 字节并保留 diskSpaceMargin
+// 空间不足时返回包装了 ErrInsufficientSpace 的错误；无法查询剩余空间时不阻止写入
+func checkFreeSpace(path string, required int64) error {
+	free, err := diskFreeBytes(path)
+	if err != nil {
+		fmt.Printf("[DiskSpace] Warning: failed to query free space for %s: %v\n", path, err)
+		return nil
+	}
+	if required < 0 {
+		required = 0
+	}
+	if free < uint64(required)+diskSpaceMargin {
+		return fmt.Errorf("%w: %s required, %s available", ErrInsufficientSpace, formatByteSize(required+diskSpaceMargin), formatByteSize(int64(free)))
+	}
+	return nil
+}
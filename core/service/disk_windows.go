@@ -0,0 +1,26 @@
+//go:build windows
+
+package service
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// getDiskFreeSpaceEx kernel32 GetDiskFreeSpaceExW
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskFreeBytes 返回 path 所在磁盘对当前用户可用的剩余空间（字节）
+func diskFreeBytes(path string) (uint64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var freeBytesAvailable uint64
+	ret, _, callErr := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(pathPtr)), uintptr(unsafe.Pointer(&freeBytesAvailable)), 0, 0)
+	if ret == 0 {
+		return 0, callErr
+	}
+	return freeBytesAvailable, nil
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// OpenDataDir 在系统文件管理器中打开应用数据目录（图片、历史记录、设置和日志所在位置）
+func OpenDataDir() error {
+	dir, err := ResolveDataDir()
+	if err != nil {
+		return err
+	}
+	return openInFileManager(dir)
+}
+
+// openInFileManager 使用系统文件管理器打开目录（内部函数）
+// windows 使用 rundll32 url.dll,FileProtocolHandler，由 Shell 以正常窗口打开资源管理器，
+// 因此可以像其他子进程一样通过 setSysProcAttr 隐藏启动器自身的窗口；darwin 使用 open，其他系统使用 xdg-open
+func openInFileManager(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("failed to open directory: %s is not a directory", dir)
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32.exe", "url.dll,FileProtocolHandler", dir)
+	case "darwin":
+		cmd = exec.Command("open", dir)
+	default:
+		cmd = exec.Command("xdg-open", dir)
+	}
+	setSysProcAttr(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to launch file manager: %w", err)
+	}
+	// 回收子进程，文件管理器的退出状态不影响结果
+	go cmd.Wait()
+	return nil
+}
@@ -1,46 +1,87 @@
 package service
 
 import (
+	"archive/zip"
+	"artifex/core/types"
+	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// ErrImageStorageUnavailable 图片存储不可用（数据目录无法解析或图片目录无法创建）
+// 通过 imageStore 返回的错误包装此错误并附带具体原因，调用方使用 errors.Is 判断
+var ErrImageStorageUnavailable = errors.New("image storage unavailable")
+
 // FileService 文件管理服务
 // 提供图片导出功能
 type FileService struct {
-	ctx          context.Context
-	imageStorage *ImageStorage
+	ctx           context.Context
+	configService *ConfigService // 读取 allowedImageMimeTypes（可为 nil，不限制导入类型）
+	imageStorage  *ImageStorage
+	storageMu     sync.Mutex // 保护 imageStorage 的按需初始化
 }
 
 // NewFileService 创建文件服务实例
-func NewFileService() *FileService {
-	return &FileService{}
+func NewFileService(configService *ConfigService) *FileService {
+	return &FileService{configService: configService}
 }
 
 // Startup 在应用启动时调用
 func (f *FileService) Startup(ctx context.Context) {
 	f.ctx = ctx
 
-	exeDir, err := getExecutableDir()
-	if err != nil {
-		fmt.Printf("[FileService] Warning: failed to get executable dir: %v\n", err)
-		return
+	if _, err := f.imageStore(); err != nil {
+		fmt.Printf("[FileService] Warning: %v\n", err)
+	}
+
+	// 设置变更后重新应用 allowedImageMimeTypes（见 ConfigChangedEvent）
+	if f.configService != nil {
+		runtime.EventsOn(ctx, ConfigChangedEvent, func(data ...interface{}) {
+			f.storageMu.Lock()
+			storage := f.imageStorage
+			f.storageMu.Unlock()
+			if err := applyAllowedMimeTypes(f.configService, storage); err != nil {
+				fmt.Printf("[FileService] Warning: failed to apply allowed image types: %v\n", err)
+			}
+		})
+	}
+}
+
+// imageStore 返回图片存储，未初始化（启动时初始化失败）时重新尝试初始化（内部方法）
+// 仍然失败时返回包装了 ErrImageStorageUnavailable 的错误，说明失败原因
+func (f *FileService) imageStore() (*ImageStorage, error) {
+	f.storageMu.Lock()
+	defer f.storageMu.Unlock()
+
+	if f.imageStorage != nil {
+		return f.imageStorage, nil
 	}
 
-	dataDir := filepath.Join(exeDir, "config")
-	f.imageStorage = NewImageStorage(dataDir)
-	if err := f.imageStorage.Initialize(); err != nil {
-		fmt.Printf("[FileService] Warning: failed to initialize image storage: %v\n", err)
+	dataDir, err := ResolveDataDir()
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to resolve data dir: %w", ErrImageStorageUnavailable, err)
 	}
+	storage := NewImageStorage(dataDir)
+	if err := storage.Initialize(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrImageStorageUnavailable, err)
+	}
+	if err := applyAllowedMimeTypes(f.configService, storage); err != nil {
+		fmt.Printf("[FileService] Warning: failed to apply allowed image types: %v\n", err)
+	}
+	f.imageStorage = storage
+	return storage, nil
 }
 
 func normalizeImageRef(source string) string {
@@ -58,18 +99,53 @@ func normalizeImageRef(source string) string {
 	return source
 }
 
+// ImageExportOptions 图像导出选项（ExportImage 的 optionsJSON）
+type ImageExportOptions struct {
+	SuggestedName string `json:"suggestedName,omitempty"` // 建议的文件名
+	// Format 导出格式（"png"、"jpeg"、"webp"、"gif"），为空时从文件名推断
+	// 动画图像（多帧 GIF、动画 WebP）只能按原格式导出，转换为其他格式时返回 ErrAnimatedImage 错误，
+	// 需要静态图像时先通过 ExtractFirstFrame 提取第一帧
+	Format    string `json:"format,omitempty"`
+	ExportDir string `json:"exportDir,omitempty"` // 导出目录，为空时显示文件保存对话框
+	Quality   int    `json:"quality,omitempty"`   // 有损格式（jpeg）的压缩质量 1-100，<= 0 时使用默认值 90，png 忽略该参数
+	// Metadata 要嵌入的生成元数据，为空时不嵌入
+	// PNG 写入 iTXt 块，JPEG 写入 EXIF UserComment，可通过 ReadImageMetadata 读回
+	Metadata *types.ImageMetadata `json:"metadata,omitempty"`
+	// OriginalName 图像导入时的原始文件名，未指定 SuggestedName 时据此建议 "<原名>-edited.<扩展名>"
+	OriginalName string `json:"originalName,omitempty"`
+	// BackgroundColor 透明图像导出为 jpeg 时填充透明区域的颜色（"#rgb" 或 "#rrggbb"），为空时使用白色
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+}
+
 // ExportImage 导出图像到文件
 // imageDataURL: data URL 或 image ref (images/...)
-// suggestedName: 建议的文件名
-// format: 导出格式 ("png", "jpeg", "webp")，如果为空则从文件名推断
-// exportDir: 导出目录（可选），如果为空则显示文件保存对话框
-func (f *FileService) ExportImage(imageDataURL string, suggestedName string, format string, exportDir string) (string, error) {
+// optionsJSON: ImageExportOptions 的 JSON（可选），如 {"suggestedName": "a.png", "format": "png"}
+// 返回保存的文件路径，用户取消时返回空字符串
+func (f *FileService) ExportImage(imageDataURL string, optionsJSON string) (string, error) {
 	if f.ctx == nil {
 		return "", fmt.Errorf("service not initialized")
 	}
 
+	var options ImageExportOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &options); err != nil {
+			return "", fmt.Errorf("invalid export options: %w", err)
+		}
+	}
+	format := options.Format
+
+	// 在显示保存对话框之前校验背景色，避免用户选择路径后才报错
+	background := defaultExportBackground
+	if strings.TrimSpace(options.BackgroundColor) != "" {
+		parsed, err := parseHexColor(options.BackgroundColor)
+		if err != nil {
+			return "", err
+		}
+		background = parsed
+	}
+
 	// 确定文件名
-	defaultFilename := suggestedName
+	defaultFilename := options.SuggestedName
 	if defaultFilename == "" {
 		ext := ".png"
 		if format != "" {
@@ -78,21 +154,27 @@ func (f *FileService) ExportImage(imageDataURL string, suggestedName string, for
 				ext = ".jpg"
 			case "webp":
 				ext = ".webp"
+			case "gif":
+				ext = ".gif"
 			}
 		}
-		defaultFilename = fmt.Sprintf("artifexBot-export-%d%s", time.Now().Unix(), ext)
+		if base := originalBaseName(options.OriginalName); base != "" {
+			defaultFilename = base + "-edited" + ext
+		} else {
+			defaultFilename = fmt.Sprintf("artifexBot-export-%d%s", time.Now().Unix(), ext)
+		}
 	}
 
 	var filePath string
 	var err error
 
 	// 如果指定了导出目录，直接保存到该目录
-	if exportDir != "" {
+	if options.ExportDir != "" {
 		// 确保目录存在
-		if err := os.MkdirAll(exportDir, 0755); err != nil {
+		if err := os.MkdirAll(options.ExportDir, 0755); err != nil {
 			return "", fmt.Errorf("failed to create export directory: %w", err)
 		}
-		filePath = filepath.Join(exportDir, defaultFilename)
+		filePath = filepath.Join(options.ExportDir, defaultFilename)
 	} else {
 		// 显示保存对话框
 		// 构建文件过滤器
@@ -109,9 +191,13 @@ func (f *FileService) ExportImage(imageDataURL string, suggestedName string, for
 				DisplayName: "WebP Image (*.webp)",
 				Pattern:     "*.webp",
 			},
+			{
+				DisplayName: "GIF Image (*.gif)",
+				Pattern:     "*.gif",
+			},
 			{
 				DisplayName: "All Images",
-				Pattern:     "*.png;*.jpg;*.jpeg;*.webp",
+				Pattern:     "*.png;*.jpg;*.jpeg;*.webp;*.gif",
 			},
 		}
 
@@ -130,85 +216,298 @@ func (f *FileService) ExportImage(imageDataURL string, suggestedName string, for
 			return "", nil
 		}
 	}
-	
-	normalized := normalizeImageRef(imageDataURL)
-	if strings.HasPrefix(normalized, "images/") {
-		if f.imageStorage == nil {
-			return "", fmt.Errorf("image storage not initialized")
-		}
 
-		imagePath, err := f.imageStorage.GetImagePath(normalized)
+	// image ref 从图片存储读取，data URL 通过 decodeImageDataURL 解析
+	imageData, err := f.loadImageBytes(imageDataURL)
+	if err != nil {
+		return "", err
+	}
+
+	imageData, err = prepareExportData(imageData, resolveExportFormat(format, filePath), options.Quality, options.Metadata, background)
+	if err != nil {
+		return "", err
+	}
+
+	// 写入文件
+	if err := os.WriteFile(filePath, imageData, 0644); err != nil {
+		return "", fmt.Errorf("failed to write image file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// defaultSliceNamePattern 默认的切片文件名格式（slice-1, slice-2, ...）
+const defaultSliceNamePattern = "slice-{n}"
+
+// originalBaseName 提取原始文件名中不含扩展名的部分，无效时返回空字符串
+func originalBaseName(originalName string) string {
+	name := filepath.Base(strings.TrimSpace(originalName))
+	if name == "." || name == string(filepath.Separator) {
+		return ""
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+// maxImportFileSize 单个导入文件的最大大小
+const maxImportFileSize = 100 * 1024 * 1024 // 100MB
+
+// ImportResult 单个文件的导入结果
+type ImportResult struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`            // 原始文件名，导出时可作为 originalName 传回
+	Ref    string `json:"ref,omitempty"`   // 导入成功时的 image ref
+	Status string `json:"status"`          // "imported" 或 "skipped"
+	Error  string `json:"error,omitempty"` // 跳过原因
+}
+
+// ImportImages 从磁盘导入图像到图片存储
+// 打开多选文件对话框，逐个校验并保存图像，不支持的文件会被跳过
+// 返回 JSON 格式：{"refs": []string, "results": [{"path", "ref", "status", "error"}]}，用户取消时返回空字符串
+func (f *FileService) ImportImages() (string, error) {
+	if f.ctx == nil {
+		return "", fmt.Errorf("service not initialized")
+	}
+	if _, err := f.imageStore(); err != nil {
+		return "", err
+	}
+
+	paths, err := runtime.OpenMultipleFilesDialog(f.ctx, runtime.OpenDialogOptions{
+		Title: "Import Images",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "Images (*.png;*.jpg;*.jpeg;*.webp;*.gif)",
+				Pattern:     "*.png;*.jpg;*.jpeg;*.webp;*.gif",
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("open dialog error: %w", err)
+	}
+
+	// 用户取消了选择
+	if len(paths) == 0 {
+		return "", nil
+	}
+
+	refs := make([]string, 0, len(paths))
+	results := make([]ImportResult, 0, len(paths))
+	for _, path := range paths {
+		ref, err := f.importImageFile(path)
 		if err != nil {
-			return "", err
+			results = append(results, ImportResult{Path: path, Name: filepath.Base(path), Status: "skipped", Error: err.Error()})
+			continue
 		}
+		refs = append(refs, ref)
+		results = append(results, ImportResult{Path: path, Name: filepath.Base(path), Ref: ref, Status: "imported"})
+	}
 
-		imageData, err := os.ReadFile(imagePath)
-		if err != nil {
-			return "", fmt.Errorf("failed to read image file: %w", err)
+	result := struct {
+		Refs    []string       `json:"refs"`
+		Results []ImportResult `json:"results"`
+	}{
+		Refs:    refs,
+		Results: results,
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
+
+// importImageFile 校验并导入单个图像文件，返回 image ref（内部方法）
+func (f *FileService) importImageFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat file: %w", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("not a file")
+	}
+	if info.Size() > maxImportFileSize {
+		return "", fmt.Errorf("file too large: %d bytes (limit %d MB)", info.Size(), maxImportFileSize/(1024*1024))
+	}
+
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file: %w", err)
+	}
+
+	// 按文件内容（而非扩展名）识别格式
+	imageFormat := detectImageFormat(imageData)
+	switch imageFormat {
+	case "png", "jpeg", "gif":
+		if _, _, err := image.DecodeConfig(bytes.NewReader(imageData)); err != nil {
+			return "", fmt.Errorf("invalid %s image: %w", imageFormat, err)
 		}
+	case "webp":
+		// 标准库没有 WebP 解码器，仅按文件签名校验
+	default:
+		return "", fmt.Errorf("unsupported image format")
+	}
 
-		if err := os.WriteFile(filePath, imageData, 0644); err != nil {
-			return "", fmt.Errorf("failed to write image file: %w", err)
+	storage, err := f.imageStore()
+	if err != nil {
+		return "", err
+	}
+	return storage.saveImageBytes(imageData, "image/"+imageFormat)
+}
+
+// prepareExportData 按导出格式转换图像并按需嵌入生成元数据（内部函数）
+// 格式一致时不重新编码；透明图像转换为 jpeg 时使用 background 填充透明区域
+func prepareExportData(imageData []byte, exportFormat string, quality int, metadata *types.ImageMetadata, background color.NRGBA) ([]byte, error) {
+	imageData, err := convertImageFormatWithBackground(imageData, exportFormat, quality, background)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata == nil {
+		return imageData, nil
+	}
+	return embedImageMetadata(imageData, exportFormat, *metadata)
+}
+
+// sliceExportItem 切片导出数据
+type sliceExportItem struct {
+	DataURL string `json:"dataUrl"`
+	ID      int    `json:"id"`
+	Row     int    `json:"row,omitempty"`    // 切片所在行（从 0 开始，可选，用于 {row} 占位符）
+	Col     int    `json:"col,omitempty"`    // 切片所在列（从 0 开始，可选，用于 {col} 占位符）
+	Format  string `json:"format,omitempty"` // 导出格式（png/jpeg），默认 png
+}
+
+// sliceExportRequest 切片导出请求
+// 除切片数组外，也可以传入对象形式以指定文件名格式：
+//
+//	{"slices": [...], "namePattern": "tile_r{row}_c{col}", "padWidth": 2}
+type sliceExportRequest struct {
+	Slices      []sliceExportItem `json:"slices"`
+	NamePattern string            `json:"namePattern,omitempty"` // 文件名格式，支持 {n}、{id}、{row}、{col}；不含占位符时作为前缀
+	PadWidth    int               `json:"padWidth,omitempty"`    // 数字补零宽度（如 3 表示 001）
+}
+
+// parseSlices 解析切片 JSON 数据（内部方法）
+// 兼容切片数组和带文件名选项的对象两种格式
+func parseSlices(slicesJSON string) (*sliceExportRequest, error) {
+	request := &sliceExportRequest{}
+	trimmed := strings.TrimSpace(slicesJSON)
+	if strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal([]byte(trimmed), request); err != nil {
+			return nil, fmt.Errorf("invalid slices data: %w", err)
 		}
+	} else if err := json.Unmarshal([]byte(trimmed), &request.Slices); err != nil {
+		return nil, fmt.Errorf("invalid slices data: %w", err)
+	}
 
-		return filePath, nil
+	if len(request.Slices) == 0 {
+		return nil, fmt.Errorf("no slices to export")
 	}
+	return request, nil
+}
 
+// sliceFileName 根据文件名格式生成切片文件名（不含扩展名）
+// 占位符：{n} 序号（ID+1）、{id} 原始 ID、{row}/{col} 行列号（从 1 开始）
+func (r *sliceExportRequest) sliceFileName(slice sliceExportItem) string {
+	pattern := strings.TrimSpace(r.NamePattern)
+	if pattern == "" {
+		pattern = defaultSliceNamePattern
+	} else if !strings.Contains(pattern, "{") {
+		// 仅提供前缀时追加序号
+		pattern += "{n}"
+	}
 
-	// 解析 base64 数据
-	// 格式: data:image/png;base64,iVBORw0KGgo...
-	const base64Prefix = "data:image/"
-	if len(imageDataURL) < len(base64Prefix) {
-		return "", fmt.Errorf("invalid image data URL")
+	padWidth := r.PadWidth
+	if padWidth < 0 {
+		padWidth = 0
+	}
+	pad := func(value int) string {
+		return fmt.Sprintf("%0*d", padWidth, value)
 	}
 
-	// 找到 base64 数据的起始位置
-	base64Start := 0
-	for i, c := range imageDataURL {
-		if c == ',' {
-			base64Start = i + 1
-			break
+	name := strings.NewReplacer(
+		"{n}", pad(slice.ID+1),
+		"{id}", pad(slice.ID),
+		"{row}", pad(slice.Row+1),
+		"{col}", pad(slice.Col+1),
+	).Replace(pattern)
+
+	// 避免文件名中出现路径分隔符
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	return name
+}
+
+// loadImageBytes 读取图像原始数据（内部方法）
+// 支持 image ref (images/...) 和 data URL 两种来源
+func (f *FileService) loadImageBytes(source string) ([]byte, error) {
+	normalized := normalizeImageRef(source)
+	if strings.HasPrefix(normalized, "images/") {
+		storage, err := f.imageStore()
+		if err != nil {
+			return nil, err
+		}
+
+		imagePath, err := storage.GetImagePath(normalized)
+		if err != nil {
+			return nil, err
 		}
+
+		imageData, err := os.ReadFile(imagePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image file: %w", err)
+		}
+		return imageData, nil
+	}
+
+	imageData, _, err := decodeImageDataURL(source)
+	if err != nil {
+		return nil, err
 	}
+	return imageData, nil
+}
 
-	if base64Start == 0 {
-		return "", fmt.Errorf("invalid image data URL format")
+// readSliceImage 读取切片图像并转换为目标格式，返回图像数据和文件名（内部方法）
+func (f *FileService) readSliceImage(request *sliceExportRequest, slice sliceExportItem, quality int) ([]byte, string, error) {
+	sliceFormat := resolveExportFormat(slice.Format, "")
+	sliceExt := ".png"
+	if sliceFormat == "jpeg" {
+		sliceExt = ".jpg"
+	} else if sliceFormat == "webp" {
+		sliceExt = ".webp"
 	}
 
-	// 解码 base64
-	imageData, err := base64.StdEncoding.DecodeString(imageDataURL[base64Start:])
+	imageData, err := f.loadImageBytes(slice.DataURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
+		return nil, "", err
 	}
 
-	// 写入文件
-	if err := os.WriteFile(filePath, imageData, 0644); err != nil {
-		return "", fmt.Errorf("failed to write image file: %w", err)
+	imageData, err = convertImageFormat(imageData, sliceFormat, quality)
+	if err != nil {
+		return nil, "", err
 	}
 
-	return filePath, nil
+	// 生成文件名
+	fileName := request.sliceFileName(slice) + sliceExt
+	return imageData, fileName, nil
 }
 
 // ExportSliceImages 批量导出切片图像到指定目录
-// slicesJSON: 包含切片数据的 JSON 字符串，格式为 [{"dataUrl": "...", "id": 0}, ...]
+// slicesJSON: 包含切片数据的 JSON 字符串，格式为 [{"dataUrl": "...", "id": 0, "format"?: "png"}, ...]
+// 或 {"slices": [...], "namePattern"?: "tile_r{row}_c{col}", "padWidth"?: 2}，默认文件名为 slice-1、slice-2...
+// quality: 有损格式（jpeg）的压缩质量 1-100，<= 0 时使用默认值 90，png 忽略该参数
 // 返回保存的文件路径列表的 JSON 字符串
-func (f *FileService) ExportSliceImages(slicesJSON string) (string, error) {
+func (f *FileService) ExportSliceImages(slicesJSON string, quality int) (string, error) {
 	if f.ctx == nil {
 		return "", fmt.Errorf("service not initialized")
 	}
 
 	// 解析切片数据
-	var slices []struct {
-		DataURL string `json:"dataUrl"`
-		ID      int    `json:"id"`
-	}
-	if err := json.Unmarshal([]byte(slicesJSON), &slices); err != nil {
-		return "", fmt.Errorf("invalid slices data: %w", err)
-	}
-
-	if len(slices) == 0 {
-		return "", fmt.Errorf("no slices to export")
+	request, err := parseSlices(slicesJSON)
+	if err != nil {
+		return "", err
 	}
+	slices := request.Slices
 
 	// 让用户选择保存目录
 	dirPath, err := runtime.OpenDirectoryDialog(f.ctx, runtime.OpenDialogOptions{
@@ -228,62 +527,14 @@ func (f *FileService) ExportSliceImages(slicesJSON string) (string, error) {
 
 	// 保存每个切片
 	for _, slice := range slices {
-		normalized := normalizeImageRef(slice.DataURL)
-		if strings.HasPrefix(normalized, "images/") {
-			if f.imageStorage == nil {
-				continue
-			}
-
-			imagePath, err := f.imageStorage.GetImagePath(normalized)
-			if err != nil {
-				continue
-			}
-
-			imageData, err := os.ReadFile(imagePath)
-			if err != nil {
-				continue
-			}
-
-			// 生成文件名
-			fileName := fmt.Sprintf("slice-%d.png", slice.ID+1)
-			filePath := filepath.Join(dirPath, fileName)
-
-			// 写入文件
-			if err := os.WriteFile(filePath, imageData, 0644); err != nil {
-				continue
-			}
-
-			savedPaths = append(savedPaths, filePath)
-			continue
+		imageData, fileName, err := f.readSliceImage(request, slice, quality)
+		if errors.Is(err, ErrImageStorageUnavailable) {
+			return "", err // 存储不可用时所有 image ref 都无法读取，直接报告原因
 		}
-
-		// 解析 base64 数据
-		const base64Prefix = "data:image/"
-		if len(slice.DataURL) < len(base64Prefix) {
-			continue // 跳过无效的数据
-		}
-
-		// 找到 base64 数据的起始位置
-		base64Start := 0
-		for i, c := range slice.DataURL {
-			if c == ',' {
-				base64Start = i + 1
-				break
-			}
-		}
-
-		if base64Start == 0 {
-			continue // 跳过无效格式
-		}
-
-		// 解码 base64
-		imageData, err := base64.StdEncoding.DecodeString(slice.DataURL[base64Start:])
 		if err != nil {
-			continue // 跳过解码失败的数据
+			continue // 跳过无效的数据
 		}
 
-		// 生成文件名
-		fileName := fmt.Sprintf("slice-%d.png", slice.ID+1)
 		filePath := filepath.Join(dirPath, fileName)
 
 		// 写入文件
@@ -312,3 +563,125 @@ func (f *FileService) ExportSliceImages(slicesJSON string) (string, error) {
 
 	return string(resultJSON), nil
 }
+
+// ExportSlicesAsZip 将所有切片打包导出为单个 ZIP 文件
+// slicesJSON: 与 ExportSliceImages 相同的切片数据
+// quality: 有损格式（jpeg）的压缩质量 1-100，<= 0 时使用默认值 90
+// includeManifest: 是否在压缩包中附带 manifest.json（记录每个文件对应的切片 ID）
+// 返回 JSON 格式：{"path": string, "files": []string, "count": int}，用户取消时返回空字符串
+func (f *FileService) ExportSlicesAsZip(slicesJSON string, quality int, includeManifest bool) (string, error) {
+	if f.ctx == nil {
+		return "", fmt.Errorf("service not initialized")
+	}
+
+	request, err := parseSlices(slicesJSON)
+	if err != nil {
+		return "", err
+	}
+	slices := request.Slices
+
+	zipPath, err := runtime.SaveFileDialog(f.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("artifexBot-slices-%d.zip", time.Now().Unix()),
+		Title:           "Export Slices",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "ZIP Archive (*.zip)",
+				Pattern:     "*.zip",
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("save dialog error: %w", err)
+	}
+
+	// 用户取消了保存
+	if zipPath == "" {
+		return "", nil
+	}
+
+	type manifestEntry struct {
+		File string `json:"file"`
+		ID   int    `json:"id"`
+	}
+
+	// 先写入临时文件，完成后再重命名，避免留下不完整的压缩包
+	tempPath := zipPath + ".tmp"
+	zipFile, err := os.Create(tempPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create zip file: %w", err)
+	}
+
+	zipWriter := zip.NewWriter(zipFile)
+	files := make([]string, 0, len(slices))
+	manifest := make([]manifestEntry, 0, len(slices))
+
+	writeErr := func() error {
+		for _, slice := range slices {
+			imageData, fileName, err := f.readSliceImage(request, slice, quality)
+			if errors.Is(err, ErrImageStorageUnavailable) {
+				return err
+			}
+			if err != nil {
+				fmt.Printf("[FileService] Warning: skipping slice %d: %v\n", slice.ID, err)
+				continue
+			}
+
+			entry, err := zipWriter.Create(fileName)
+			if err != nil {
+				return fmt.Errorf("failed to add %s to zip: %w", fileName, err)
+			}
+			if _, err := entry.Write(imageData); err != nil {
+				return fmt.Errorf("failed to write %s to zip: %w", fileName, err)
+			}
+
+			files = append(files, fileName)
+			manifest = append(manifest, manifestEntry{File: fileName, ID: slice.ID})
+		}
+
+		if includeManifest {
+			manifestData, err := json.MarshalIndent(manifest, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to serialize manifest: %w", err)
+			}
+			entry, err := zipWriter.Create("manifest.json")
+			if err != nil {
+				return fmt.Errorf("failed to add manifest to zip: %w", err)
+			}
+			if _, err := entry.Write(manifestData); err != nil {
+				return fmt.Errorf("failed to write manifest to zip: %w", err)
+			}
+		}
+
+		return zipWriter.Close()
+	}()
+
+	if closeErr := zipFile.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	if writeErr != nil {
+		os.Remove(tempPath)
+		return "", writeErr
+	}
+
+	if err := os.Rename(tempPath, zipPath); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("failed to rename zip file: %w", err)
+	}
+
+	result := struct {
+		Path  string   `json:"path"`
+		Files []string `json:"files"`
+		Count int      `json:"count"`
+	}{
+		Path:  zipPath,
+		Files: files,
+		Count: len(files),
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+
+	return string(resultJSON), nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportImageDecodesDataURLVariants(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	pngData := buf.Bytes()
+
+	cases := []struct {
+		name    string
+		dataURL string
+	}{
+		{name: "base64", dataURL: "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngData)},
+		{name: "charset parameter", dataURL: "data:image/png;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(pngData)},
+		{name: "percent encoded", dataURL: "data:image/png," + url.PathEscape(string(pngData))},
+	}
+
+	f := NewFileService(nil)
+	f.ctx = context.Background()
+	exportDir := t.TempDir()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, _ := json.Marshal(ImageExportOptions{SuggestedName: tc.name + ".png", Format: "png", ExportDir: exportDir})
+			path, err := f.ExportImage(tc.dataURL, string(options))
+			if err != nil {
+				t.Fatalf("ExportImage: %v", err)
+			}
+			if path != filepath.Join(exportDir, tc.name+".png") {
+				t.Fatalf("unexpected export path %q", path)
+			}
+			written, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read exported file: %v", err)
+			}
+			if _, err := png.Decode(bytes.NewReader(written)); err != nil {
+				t.Fatalf("exported file is not a valid png: %v", err)
+			}
+		})
+	}
+}
+
+func TestExportImageRejectsInvalidOptions(t *testing.T) {
+	f := NewFileService(nil)
+	f.ctx = context.Background()
+	if _, err := f.ExportImage("data:image/png;base64,", `{"quality": "high"}`); err == nil {
+		t.Fatal("expected an error for malformed export options")
+	}
+}
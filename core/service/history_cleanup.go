@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// cleanupMinImageAge 清理时保留的最近图片的时间范围
+// 刚生成的图片可能尚未随历史记录保存（前端防抖、保存队列合并），不参与清理
+const cleanupMinImageAge = time.Hour
+
+// CollectImageRefs 收集聊天历史和画布历史中引用的所有图片 ref
+// 任一历史文件无法解析时返回错误，避免误删仍在使用的图片
+func (h *HistoryService) CollectImageRefs() (map[string]bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chatFile == "" || h.canvasFile == "" {
+		return nil, fmt.Errorf("history service not initialized")
+	}
+
+	var chat ChatHistory
+	if err := verifyJSONFile(h.chatFile, &chat); err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+	var canvas CanvasHistory
+	if err := verifyJSONFile(h.canvasFile, &canvas); err != nil {
+		return nil, fmt.Errorf("canvas history: %w", err)
+	}
+
+	refs := make(map[string]bool)
+	addChatImageRefs(refs, chat)
+	addCanvasImageRefs(refs, canvas)
+	return refs, nil
+}
+
+// addImageRef 将图片地址中的 image ref 加入集合，忽略 data URL 等其他来源
+func addImageRef(refs map[string]bool, src string) {
+	src = strings.TrimPrefix(src, "/")
+	if strings.HasPrefix(src, "images/") {
+		refs[src] = true
+	}
+}
+
+// addChatImageRefs 将聊天历史中引用的图片 ref 加入集合
+func addChatImageRefs(refs map[string]bool, chat ChatHistory) {
+	for _, message := range chat.Messages {
+		for _, img := range message.Images {
+			addImageRef(refs, img)
+		}
+	}
+}
+
+// addCanvasImageRefs 将画布历史中引用的图片 ref 加入集合
+func addCanvasImageRefs(refs map[string]bool, canvas CanvasHistory) {
+	for _, img := range canvas.Images {
+		addImageRef(refs, img.Src)
+	}
+}
+
+// clearedImageRefs 返回即将清除的一侧历史引用、而另一侧历史未引用的图片 ref（调用方需持有 mu）
+// clearChat 为 true 时清除聊天历史、保留画布历史，否则相反；
+// 任一文件无法解析时返回错误，调用方应跳过图片清理，避免误删仍在使用的图片
+func (h *HistoryService) clearedImageRefs(clearChat bool) ([]string, error) {
+	var chat ChatHistory
+	if err := verifyJSONFile(h.chatFile, &chat); err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+	var canvas CanvasHistory
+	if err := verifyJSONFile(h.canvasFile, &canvas); err != nil {
+		return nil, fmt.Errorf("canvas history: %w", err)
+	}
+
+	cleared := make(map[string]bool)
+	surviving := make(map[string]bool)
+	if clearChat {
+		addChatImageRefs(cleared, chat)
+		addCanvasImageRefs(surviving, canvas)
+	} else {
+		addCanvasImageRefs(cleared, canvas)
+		addChatImageRefs(surviving, chat)
+	}
+
+	var orphans []string
+	for ref := range cleared {
+		if !surviving[ref] {
+			orphans = append(orphans, ref)
+		}
+	}
+	return orphans, nil
+}
+
+// GetImageStorageSize 获取图片存储占用的磁盘空间（字节，含缩略图缓存）
+func (h *HistoryService) GetImageStorageSize() (int64, error) {
+	if h.imageStorage == nil {
+		return 0, fmt.Errorf("image storage not initialized")
+	}
+	return h.imageStorage.GetStorageSize()
+}
+
+// CleanupUnusedImages 删除聊天历史和画布历史都不再引用的图片
+// 先写入待保存的历史记录，保证引用是最新的；最近一小时内的图片不会被删除
+// workers 为并发删除的协程数（<= 0 时使用默认值），返回删除的文件数和释放的字节数
+func (h *HistoryService) CleanupUnusedImages(workers int) (CleanupResult, error) {
+	if h.imageStorage == nil {
+		return CleanupResult{}, fmt.Errorf("image storage not initialized")
+	}
+
+	h.flushPendingSaves()
+
+	refs, err := h.CollectImageRefs()
+	if err != nil {
+		return CleanupResult{}, fmt.Errorf("failed to collect image references: %w", err)
+	}
+	return h.imageStorage.CleanupUnusedImagesOlderThan(refs, cleanupMinImageAge, workers)
+}
+
+// deleteOrphanImages 删除清除历史后不再被引用的图片（调用方需持有 mu）
+// 图片存储未初始化时不删除任何图片
+func (h *HistoryService) deleteOrphanImages(orphans []string) CleanupResult {
+	if len(orphans) == 0 {
+		return CleanupResult{}
+	}
+	if h.imageStorage == nil {
+		fmt.Printf("[HistoryService] Warning: image storage not initialized, skipping image pruning\n")
+		return CleanupResult{}
+	}
+	return h.imageStorage.DeleteImages(orphans)
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+)
+
+// gzipMagic gzip 数据的起始字节，读取历史文件时据此判断是否压缩
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// applyCompressionSetting 按 compressHistoryFiles 设置决定后续保存是否压缩（内部方法）
+// 未配置 ConfigService 或读取设置失败时保持当前状态
+func (h *HistoryService) applyCompressionSetting() {
+	if h.configService == nil {
+		return
+	}
+	aiSettings, err := h.configService.loadAISettings()
+	if err != nil {
+		fmt.Printf("[HistoryService] Warning: %v\n", err)
+		return
+	}
+	h.compressFiles.Store(aiSettings.CompressHistoryFiles)
+}
+
+// readHistoryFile 读取历史文件，gzip 压缩的文件自动解压
+// 按起始字节判断格式，压缩与未压缩的文件都可以读取，与当前设置无关
+func readHistoryFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open compressed %s: %w", path, err)
+	}
+	defer reader.Close()
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s: %w", path, err)
+	}
+	return decompressed, nil
+}
+
+// writeHistoryFile 原子性写入历史文件，启用 compressHistoryFiles 时以 gzip 压缩
+// 设置变更后已有文件保持原格式，直到下一次保存时按新设置重写
+func (h *HistoryService) writeHistoryFile(path string, data []byte) error {
+	if h.compressFiles.Load() {
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		if err := writer.Close(); err != nil {
+			return fmt.Errorf("failed to compress %s: %w", path, err)
+		}
+		data = buf.Bytes()
+	}
+	return writeFileAtomic(path, data)
+}
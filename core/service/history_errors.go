@@ -0,0 +1,104 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// saveErrorSnippetRadius 错误片段在出错位置前后各截取的字节数
+const saveErrorSnippetRadius = 40
+
+// DuplicateIDError 保存的历史记录中存在重复的 ID
+// 重复的 ID 会让后续的编辑、删除作用到错误的记录上，整次保存被拒绝
+type DuplicateIDError struct {
+	Kind string   // 记录类型，"canvas image" 或 "chat message"
+	IDs  []string // 重复出现的 ID（按首次重复的顺序）
+}
+
+// Error 实现 error 接口
+func (e *DuplicateIDError) Error() string {
+	return fmt.Sprintf("duplicate %s ids: %s", e.Kind, strings.Join(e.IDs, ", "))
+}
+
+// checkDuplicateIDs 检查 ID 是否唯一，有重复时返回 *DuplicateIDError（空 ID 不参与检查）
+func checkDuplicateIDs(kind string, ids []string) error {
+	seen := make(map[string]int, len(ids))
+	var duplicates []string
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		seen[id]++
+		if seen[id] == 2 {
+			duplicates = append(duplicates, id)
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return &DuplicateIDError{Kind: kind, IDs: duplicates}
+}
+
+// saveErrorPayload 构建 history:*-save-error 事件的载荷
+// 基本格式：{"error": string}；JSON 解析失败时附加
+// "offset"（出错的字节偏移）、"snippet"（出错位置附近的内容）以及类型错误时的 "field"；
+// 存在重复 ID 时附加 "duplicateIds"
+func saveErrorPayload(err error, payload string) map[string]interface{} {
+	result := map[string]interface{}{
+		"error": err.Error(),
+	}
+
+	var duplicateErr *DuplicateIDError
+	if errors.As(err, &duplicateErr) {
+		result["duplicateIds"] = duplicateErr.IDs
+		return result
+	}
+
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+		if typeErr.Field != "" {
+			result["field"] = typeErr.Field
+		}
+	}
+	if offset < 0 {
+		return result
+	}
+
+	result["offset"] = offset
+	result["snippet"] = jsonErrorSnippet(payload, int(offset))
+	return result
+}
+
+// jsonErrorSnippet 截取 offset 前后的内容，截断处补 "…"，保证结果为合法 UTF-8
+func jsonErrorSnippet(payload string, offset int) string {
+	if offset > len(payload) {
+		offset = len(payload)
+	}
+	start := max(0, offset-saveErrorSnippetRadius)
+	end := min(len(payload), offset+saveErrorSnippetRadius)
+	// 对齐到字符边界，避免切断多字节字符
+	for start > 0 && !utf8.RuneStart(payload[start]) {
+		start--
+	}
+	for end < len(payload) && !utf8.RuneStart(payload[end]) {
+		end++
+	}
+
+	snippet := payload[start:end]
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(payload) {
+		snippet += "…"
+	}
+	return strings.ToValidUTF8(snippet, "")
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GetChatMessage 按 ID 获取单条聊天记录，用于从搜索结果等位置跳转到指定消息
+// 返回 JSON 格式的 ChatRecord（图片为 image refs，与 LoadChatHistory 相同）；不存在时返回错误
+func (h *HistoryService) GetChatMessage(id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("message id is required")
+	}
+
+	messagesJSON, err := h.LoadChatHistory()
+	if err != nil {
+		return "", err
+	}
+
+	var messages []ChatRecord
+	if err := json.Unmarshal([]byte(messagesJSON), &messages); err != nil {
+		return "", fmt.Errorf("failed to parse chat history: %w", err)
+	}
+
+	for _, message := range messages {
+		if message.ID != id {
+			continue
+		}
+		data, err := json.Marshal(message)
+		if err != nil {
+			return "", fmt.Errorf("failed to serialize chat message: %w", err)
+		}
+		return string(data), nil
+	}
+	return "", fmt.Errorf("chat message not found: %s", id)
+}
+
+// chatAppend 一条追加的聊天记录及其追加时间（Unix 纳秒）
+type chatAppend struct {
+	record     ChatRecord
+	appendedAt int64
+}
+
+// chatHistoryPrefix 聊天历史文件的固定开头（json.Marshal(ChatHistory) 的字段顺序）
+const chatHistoryPrefix = `{"version":"2.0","updatedAt":`
+
+// AppendChatMessage 追加一条聊天记录，不重新解析和序列化之前的消息
+// messageJSON 为单条 ChatRecord，图片可以是 data URL、http(s) URL 或 image ref，非 ref 的图片先存入图片存储；
+// 已存在相同 ID 的消息时替换该消息。
+// 与异步整体保存的合并：追加之前产生、但在追加之后才写入的整体保存不会丢失这条消息
+func (h *HistoryService) AppendChatMessage(messageJSON string) error {
+	var message ChatRecord
+	if err := json.Unmarshal([]byte(messageJSON), &message); err != nil {
+		return fmt.Errorf("invalid chat message format: %w", err)
+	}
+	if message.ID == "" {
+		return fmt.Errorf("message id is required")
+	}
+
+	// 远程图像在加锁前下载，慢速下载不阻塞其他历史记录读写
+	remote := h.fetchRemoteImages(message.Images)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chatFile == "" {
+		return fmt.Errorf("history service not initialized")
+	}
+	if err := h.storeMessageImages(&message, remote); err != nil {
+		return err
+	}
+
+	recordJSON, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to serialize chat message: %w", err)
+	}
+
+	data, err := readHistoryFile(h.chatFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read chat history file: %w", err)
+	}
+
+	updated, ok := appendChatRecordBytes(data, message.ID, recordJSON)
+	if !ok {
+		// 文件格式不是可直接追加的紧凑格式，或已存在相同 ID 的消息：完整解析后追加或替换
+		updated, err = upsertChatRecord(data, message)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := h.writeHistoryFile(h.chatFile, updated); err != nil {
+		return fmt.Errorf("failed to write chat history file: %w", err)
+	}
+
+	// 同一 ID 只保留最新的追加
+	entry := chatAppend{record: message, appendedAt: time.Now().UnixNano()}
+	for i := range h.chatAppends {
+		if h.chatAppends[i].record.ID == message.ID {
+			h.chatAppends = append(h.chatAppends[:i], h.chatAppends[i+1:]...)
+			break
+		}
+	}
+	h.chatAppends = append(h.chatAppends, entry)
+	return nil
+}
+
+// storeMessageImages 将消息中非 image ref 的图片存入图片存储并替换为 ref（调用方需持有 mu）
+// remote 为 fetchRemoteImages 预先下载的远程图像
+func (h *HistoryService) storeMessageImages(message *ChatRecord, remote map[string]string) error {
+	if len(message.Images) == 0 {
+		return nil
+	}
+
+	refs := make([]string, 0, len(message.Images))
+	for _, img := range message.Images {
+		if img == "" {
+			refs = append(refs, "")
+			continue
+		}
+		if strings.HasPrefix(img, "/images/") {
+			refs = append(refs, strings.TrimPrefix(img, "/"))
+			continue
+		}
+		if strings.HasPrefix(img, "images/") {
+			refs = append(refs, img)
+			continue
+		}
+		ref, err := h.saveImageSource(img, remote)
+		if err != nil {
+			return fmt.Errorf("failed to save image for message %s: %w", message.ID, err)
+		}
+		refs = append(refs, ref)
+	}
+	message.Images = refs
+	return nil
+}
+
+// mergeChatAppendsLocked 为整体保存补上在 requestedAt 之后追加、但保存数据中缺少的消息（调用方需持有 mu）
+// 不晚于 requestedAt 的追加已反映在保存数据中（包括前端有意删除的消息），不再保留
+func (h *HistoryService) mergeChatAppendsLocked(messages []ChatRecord, requestedAt int64) []ChatRecord {
+	if len(h.chatAppends) == 0 {
+		return messages
+	}
+
+	existing := make(map[string]bool, len(messages))
+	for _, message := range messages {
+		existing[message.ID] = true
+	}
+
+	kept := h.chatAppends[:0]
+	for _, entry := range h.chatAppends {
+		if entry.appendedAt <= requestedAt {
+			continue
+		}
+		kept = append(kept, entry)
+		if !existing[entry.record.ID] {
+			messages = append(messages, entry.record)
+			existing[entry.record.ID] = true
+		}
+	}
+	h.chatAppends = kept
+	return messages
+}
+
+// appendChatRecordBytes 在紧凑格式的聊天历史文件末尾直接追加一条记录并更新 updatedAt
+// 文件为空或不存在时生成新文件；格式不符或可能已存在相同 ID 的消息时返回 false
+func appendChatRecordBytes(data []byte, id string, recordJSON []byte) ([]byte, bool) {
+	updatedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	if len(data) == 0 {
+		return []byte(chatHistoryPrefix + updatedAt + `,"messages":[` + string(recordJSON) + `]}`), true
+	}
+
+	if !bytes.HasPrefix(data, []byte(chatHistoryPrefix)) || !bytes.HasSuffix(data, []byte("]}")) {
+		return nil, false
+	}
+	idJSON, err := json.Marshal(id)
+	if err != nil || bytes.Contains(data, append([]byte(`"id":`), idJSON...)) {
+		return nil, false
+	}
+
+	rest := data[len(chatHistoryPrefix):]
+	comma := bytes.IndexByte(rest, ',')
+	if comma <= 0 {
+		return nil, false
+	}
+	if _, err := strconv.ParseInt(string(rest[:comma]), 10, 64); err != nil {
+		return nil, false
+	}
+	body := rest[comma+1:]
+	if !bytes.HasPrefix(body, []byte(`"messages":[`)) {
+		return nil, false
+	}
+
+	// body 形如 "messages":[...]}，去掉结尾的 ]} 后追加
+	messagesPart := body[:len(body)-2]
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(recordJSON) + 1)
+	buf.WriteString(chatHistoryPrefix)
+	buf.WriteString(updatedAt)
+	buf.WriteByte(',')
+	buf.Write(messagesPart)
+	if !bytes.HasSuffix(messagesPart, []byte("[")) {
+		buf.WriteByte(',')
+	}
+	buf.Write(recordJSON)
+	buf.WriteString("]}")
+	return buf.Bytes(), true
+}
+
+// upsertChatRecord 完整解析聊天历史后追加记录，已存在相同 ID 的记录时替换
+func upsertChatRecord(data []byte, message ChatRecord) ([]byte, error) {
+	var history ChatHistory
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &history); err != nil {
+			return nil, fmt.Errorf("failed to parse chat history file: %w", err)
+		}
+	}
+
+	replaced := false
+	for i := range history.Messages {
+		if history.Messages[i].ID == message.ID {
+			history.Messages[i] = message
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		history.Messages = append(history.Messages, message)
+	}
+	history.Version = "2.0"
+	history.UpdatedAt = time.Now().Unix()
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize chat history: %w", err)
+	}
+	return data, nil
+}
+
+// writeFileAtomic 使用临时文件 + 原子性重命名写入文件
+func writeFileAtomic(filePath string, data []byte) error {
+	tempFile := filePath + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tempFile, filePath); err != nil {
+		os.Remove(tempFile)
+		return err
+	}
+	return nil
+}
@@ -6,13 +6,18 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// shutdownFlushTimeout 关闭时等待最后一次保存完成的最长时间
+const shutdownFlushTimeout = 5 * time.Second
+
 // saveRequest 保存请求结构
 type saveRequest struct {
 	saveType   string     // "chat" 或 "canvas"
@@ -26,11 +31,12 @@ type saveRequest struct {
 // 使用异步队列机制处理保存操作，避免阻塞主进程
 // ✅ 性能优化：图片分离存储 + JSON 压缩
 type HistoryService struct {
-	ctx        context.Context
-	dataDir    string
-	chatFile   string
-	canvasFile string
-	mu         sync.Mutex // 用于保护共享状态
+	ctx          context.Context
+	dataDir      string
+	chatFile     string
+	canvasFile   string
+	viewportFile string     // 画布视口单独保存的文件
+	mu           sync.Mutex // 用于保护共享状态
 
 	// ✅ 性能优化：图片存储管理器（图片分离存储）
 	imageStorage *ImageStorage
@@ -38,39 +44,65 @@ type HistoryService struct {
 	// ✅ 性能优化：保存队列处理器启动控制
 	saveQueueOnce sync.Once
 	shutdownChan  chan struct{}
+	shutdownOnce  sync.Once
+	queueDone     chan struct{} // 队列处理器完成最后一次保存并退出后关闭
 
 	// ✅ 性能优化：最新待保存数据的缓存，用于合并短时间内的多次保存
-	pendingSaveMu     sync.Mutex
-	pendingChatSave   *saveRequest  // 待保存的聊天历史（用于合并策略）
-	pendingCanvasSave *saveRequest  // 待保存的画布历史（用于合并策略）
-	saveNotifyChan    chan struct{} // 通知有新的保存请求
+	pendingSaveMu       sync.Mutex
+	pendingChatSave     *saveRequest // 待保存的聊天历史（用于合并策略）
+	pendingCanvasSave   *saveRequest // 待保存的画布历史（用于合并策略）
+	pendingViewportSave *saveRequest // 待保存的画布视口（仅视口变化时）
+
+	// 通过 AppendChatMessage 追加、尚未被更新的整体保存覆盖的消息（受 mu 保护）
+	chatAppends []chatAppend
+
+	// 有新的待保存数据的标记（每种保存类型一个），队列处理器每个周期检查并清除
+	// 多次保存只会把标记置为 true，不需要缓冲通知，也不会丢失最新数据
+	chatDirty     atomic.Bool
+	canvasDirty   atomic.Bool
+	viewportDirty atomic.Bool
 
 	// 事件监听器管理 - 使用 sync.Once 确保只注册一次
 	eventHandlersOnce sync.Once
+
+	// 启动状态：Startup 可重复调用，成功启动后再次调用直接返回
+	// 不复用 mu，因为启动过程中的迁移和图片归一化会调用加锁的保存方法
+	startupMu sync.Mutex
+	started   bool
+
+	// 历史文件压缩：configService 提供 compressHistoryFiles 设置（为 nil 时不压缩）
+	configService *ConfigService
+	compressFiles atomic.Bool
 }
 
 // NewHistoryService 创建历史记录服务实例
-func NewHistoryService() *HistoryService {
+// configService 用于读取 compressHistoryFiles 设置，需在 Startup 之前完成 ConfigService.Startup
+func NewHistoryService(configService *ConfigService) *HistoryService {
 	return &HistoryService{
+		configService: configService,
 		shutdownChan: make(chan struct{}),
-		// ✅ 性能优化：增加 channel 缓冲长度到 20，减少快速操作时的卡顿
-		// 缓冲足够多的通知，避免事件处理被阻塞
-		saveNotifyChan: make(chan struct{}, 20),
+		queueDone:    make(chan struct{}),
 	}
 }
 
 // Startup 在应用启动时调用
+// 可安全地重复或并发调用：已成功启动时直接返回，不会重新初始化状态或启动第二个队列处理器；
+// 启动失败时允许再次调用重试
 func (h *HistoryService) Startup(ctx context.Context) error {
+	h.startupMu.Lock()
+	defer h.startupMu.Unlock()
+	if h.started {
+		return nil
+	}
+
 	h.ctx = ctx
 
-	// 获取执行文件所在目录
-	exeDir, err := getExecutableDir()
+	// 创建应用数据目录（默认在执行文件所在目录下，可通过 ARTIFEX_DATA_DIR 覆盖）
+	dataDir, err := ResolveDataDir()
 	if err != nil {
-		return fmt.Errorf("failed to get executable dir: %w", err)
+		return fmt.Errorf("failed to resolve data dir: %w", err)
 	}
-
-	// 创建应用数据目录（在执行文件所在目录下）
-	h.dataDir = filepath.Join(exeDir, "config")
+	h.dataDir = dataDir
 	if err := os.MkdirAll(h.dataDir, 0755); err != nil {
 		return fmt.Errorf("failed to create app data dir: %w", err)
 	}
@@ -80,10 +112,17 @@ func (h *HistoryService) Startup(ctx context.Context) error {
 	if err := h.imageStorage.Initialize(); err != nil {
 		return fmt.Errorf("failed to initialize image storage: %w", err)
 	}
+	if err := applyAllowedMimeTypes(h.configService, h.imageStorage); err != nil {
+		fmt.Printf("[HistoryService] Warning: failed to apply allowed image types: %v\n", err)
+	}
 
 	// 设置文件路径
 	h.chatFile = filepath.Join(h.dataDir, "chat_history.json")
 	h.canvasFile = filepath.Join(h.dataDir, "canvas_history.json")
+	h.viewportFile = filepath.Join(h.dataDir, canvasViewportFileName)
+
+	// 迁移和归一化可能重写历史文件，需先应用压缩设置
+	h.applyCompressionSetting()
 
 	// ✅ 数据迁移：检查并迁移旧格式文件
 	if err := h.migrateOldFormat(); err != nil {
@@ -94,6 +133,7 @@ func (h *HistoryService) Startup(ctx context.Context) error {
 	if err := h.normalizeHistoryImages(); err != nil {
 		fmt.Printf("[HistoryService] Warning: failed to normalize history images: %v\n", err)
 	}
+	h.purgeExpiredTrash()
 
 
 	// ✅ 启动保存队列处理器（只启动一次）
@@ -106,12 +146,23 @@ func (h *HistoryService) Startup(ctx context.Context) error {
 		h.registerEventHandlers(ctx)
 	})
 
+	h.started = true
 	return nil
 }
 
 // registerEventHandlers 注册事件处理器
 // 监听前端通过 EventsEmit 发送的保存请求事件
 func (h *HistoryService) registerEventHandlers(ctx context.Context) {
+	// 设置变更后重新应用 compressHistoryFiles 和 allowedImageMimeTypes（见 ConfigChangedEvent）
+	if h.configService != nil {
+		runtime.EventsOn(ctx, ConfigChangedEvent, func(data ...interface{}) {
+			h.applyCompressionSetting()
+			if err := applyAllowedMimeTypes(h.configService, h.imageStorage); err != nil {
+				fmt.Printf("[HistoryService] Warning: failed to apply allowed image types: %v\n", err)
+			}
+		})
+	}
+
 	// 监听聊天历史保存请求事件
 	runtime.EventsOn(ctx, "history:save-chat", func(data ...interface{}) {
 		eventTime := time.Now()
@@ -153,8 +204,8 @@ func (h *HistoryService) registerEventHandlers(ctx context.Context) {
 		}
 		h.pendingSaveMu.Unlock()
 
-		// 通知队列处理器
-		h.notifySaveQueue()
+		// 标记有新数据，由队列处理器在下一个周期保存
+		h.chatDirty.Store(true)
 	})
 
 	// 监听画布历史保存请求事件
@@ -189,6 +240,8 @@ func (h *HistoryService) registerEventHandlers(ctx context.Context) {
 		if h.pendingCanvasSave != nil {
 			h.pendingCanvasSave.data = ""
 		}
+		// 整体保存包含最新的视口，之前的视口保存请求不再需要
+		h.pendingViewportSave = nil
 		// 设置新的待保存请求（覆盖旧的请求，实现合并策略）
 		h.pendingCanvasSave = &saveRequest{
 			saveType:   "canvas",
@@ -198,74 +251,56 @@ func (h *HistoryService) registerEventHandlers(ctx context.Context) {
 		}
 		h.pendingSaveMu.Unlock()
 
-		// 通知队列处理器
-		h.notifySaveQueue()
+		// 标记有新数据，由队列处理器在下一个周期保存
+		h.canvasDirty.Store(true)
 	})
+
+	h.registerViewportEventHandler(ctx)
 }
 
 // Shutdown 在应用关闭时调用，优雅地停止后台 goroutine
+// 阻塞直到队列处理器写完所有待保存的数据，最多等待 shutdownFlushTimeout，避免关闭过程卡死
 func (h *HistoryService) Shutdown() error {
-	close(h.shutdownChan)
-	// 等待队列处理器完成（如果有的话）
-	// 注意：由于使用 sync.Once，队列处理器可能没有启动，所以不需要 WaitGroup
-	return nil
-}
+	h.shutdownOnce.Do(func() {
+		close(h.shutdownChan)
+	})
+
+	// 队列处理器未启动时占用 saveQueueOnce 并直接关闭 queueDone，之后也不会再启动
+	h.saveQueueOnce.Do(func() {
+		close(h.queueDone)
+	})
 
-// notifySaveQueue 通知保存队列有新请求
-// ✅ 性能优化：使用非阻塞方式发送通知，避免阻塞事件处理
-// 由于 channel 有足够大的缓冲（20），正常情况下不会阻塞
-// 如果 channel 已满，说明后台处理较慢，此时丢弃通知是安全的（因为合并策略会确保最新数据被保存）
-func (h *HistoryService) notifySaveQueue() {
 	select {
-	case h.saveNotifyChan <- struct{}{}:
-		// 成功发送通知
-	default:
-		// 通道已满（说明后台处理较慢），丢弃通知
-		// 这是安全的，因为合并策略会确保最新的数据被保存
-		// 定时器（200ms）也会定期处理待保存的数据
+	case <-h.queueDone:
+		return nil
+	case <-time.After(shutdownFlushTimeout):
+		return fmt.Errorf("timed out after %v waiting for pending history saves", shutdownFlushTimeout)
 	}
 }
 
+// saveQueueInterval 保存队列处理器检查待保存数据的周期
+// 同一周期内的多次保存请求合并为一次写入；前端已有防抖（300ms/500ms），周期不宜过长以免增加最后一次保存的延迟
+const saveQueueInterval = 100 * time.Millisecond
+
 // ✅ 性能优化：保存队列处理器
 // 使用合并策略处理保存请求，短时间内的多次保存只执行最后一次
 func (h *HistoryService) processSaveQueue() {
+	defer close(h.queueDone)
 	fmt.Printf("[HistoryService] [GOROUTINE] 历史记录保存队列处理 goroutine 启动\n")
 
-	// ✅ 性能优化：增加批处理间隔到 200ms，减少文件写入频率
-	// 前端已经有防抖机制（500ms），这里可以适当增加间隔
-	ticker := time.NewTicker(200 * time.Millisecond)
+	ticker := time.NewTicker(saveQueueInterval)
 	defer ticker.Stop()
 
-	tickerCount := 0
-
 	for {
 		select {
 		case <-ticker.C:
-			// 定时处理待保存的请求
-			tickerCount++
-			if tickerCount%20 == 0 { // 每 1 秒打印一次
-				fmt.Printf("[HistoryService] [TICKER] 保存队列 ticker 触发 (第 %d 次)\n", tickerCount)
-			}
-			h.flushPendingSaves()
-		case <-h.saveNotifyChan:
-			// ✅ 性能优化：批量处理通知，减少锁竞争
-			// 快速消费 channel 中的所有通知（最多 10 个），然后统一处理
-			// 这样可以减少频繁的锁获取和释放，提高性能
-			notifyCount := 1
-			done := false
-			for !done && notifyCount < 10 {
-				select {
-				case <-h.saveNotifyChan:
-					notifyCount++
-				default:
-					// 没有更多通知，跳出循环
-					done = true
-				}
+			// 分别清除两个标记，避免短路求值漏掉画布标记
+			chatDirty := h.chatDirty.Swap(false)
+			canvasDirty := h.canvasDirty.Swap(false)
+			viewportDirty := h.viewportDirty.Swap(false)
+			if chatDirty || canvasDirty || viewportDirty {
+				h.flushPendingSaves()
 			}
-			// ✅ 性能优化：增加等待时间到 150ms，让更多请求合并
-			// 前端已经有防抖机制（300ms/500ms），这里适当增加等待时间可以合并更多请求
-			time.Sleep(150 * time.Millisecond)
-			h.flushPendingSaves()
 		case <-h.shutdownChan:
 			// 关闭前处理所有待保存的请求
 			fmt.Printf("[HistoryService] [GOROUTINE] 历史记录保存队列处理 goroutine 停止\n")
@@ -288,13 +323,17 @@ func (h *HistoryService) flushPendingSaves() {
 	canvasSaveReq := h.pendingCanvasSave
 	h.pendingCanvasSave = nil
 
+	// 获取并清除待保存的画布视口请求
+	viewportSaveReq := h.pendingViewportSave
+	h.pendingViewportSave = nil
+
 	h.pendingSaveMu.Unlock()
 
 	// 执行聊天历史保存
 	if chatSaveReq != nil {
 		startTime := time.Now()
 		dataSize := len(chatSaveReq.data)
-		err := h.saveChatHistorySync(chatSaveReq.data)
+		err := h.saveChatHistoryAt(chatSaveReq.data, chatSaveReq.timestamp)
 		saveDuration := time.Since(startTime)
 
 		// ✅ 性能监控：记录保存耗时和数据大小
@@ -309,9 +348,7 @@ func (h *HistoryService) flushPendingSaves() {
 		} else {
 			// ✅ 事件驱动：通过事件通知前端
 			if err != nil && h.ctx != nil {
-				runtime.EventsEmit(h.ctx, "history:chat-save-error", map[string]interface{}{
-					"error": err.Error(),
-				})
+				runtime.EventsEmit(h.ctx, "history:chat-save-error", saveErrorPayload(err, chatSaveReq.data))
 			}
 		}
 		// 清空数据，帮助 GC
@@ -337,56 +374,61 @@ func (h *HistoryService) flushPendingSaves() {
 		} else {
 			// ✅ 事件驱动：通过事件通知前端
 			if err != nil && h.ctx != nil {
-				runtime.EventsEmit(h.ctx, "history:canvas-save-error", map[string]interface{}{
-					"error": err.Error(),
-				})
+				runtime.EventsEmit(h.ctx, "history:canvas-save-error", saveErrorPayload(err, canvasSaveReq.data))
 			}
 		}
 		// 清空数据，帮助 GC
 		canvasSaveReq.data = ""
 	}
+
+	// 执行画布视口保存（在整体保存之后，保证较新的视口不被覆盖）
+	if viewportSaveReq != nil {
+		if err := h.saveViewportSync(viewportSaveReq.data); err != nil && h.ctx != nil {
+			runtime.EventsEmit(h.ctx, "history:viewport-save-error", saveErrorPayload(err, viewportSaveReq.data))
+		}
+	}
 }
 
 // saveChatHistorySync 同步保存聊天历史（内部方法，在后台 goroutine 中调用）
 // ✅ 性能优化：图片分离存储 + JSON 压缩
 func (h *HistoryService) saveChatHistorySync(chatHistoryJSON string) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+	return h.saveChatHistoryAt(chatHistoryJSON, time.Now().UnixNano())
+}
 
+// saveChatHistoryAt 同步保存聊天历史，requestedAt 为保存请求产生的时间（Unix 纳秒）
+// 数据不包含在该时间之后通过 AppendChatMessage 追加的消息时补上这些消息，避免较早的整体保存覆盖追加
+// 消息 ID 重复时拒绝整次保存，返回 *DuplicateIDError
+func (h *HistoryService) saveChatHistoryAt(chatHistoryJSON string, requestedAt int64) error {
 	// 验证 JSON 格式
 	var messages []ChatRecord
 	if err := json.Unmarshal([]byte(chatHistoryJSON), &messages); err != nil {
 		return fmt.Errorf("invalid chat history format: %w", err)
 	}
+	ids := make([]string, len(messages))
+	for i := range messages {
+		ids[i] = messages[i].ID
+	}
+	if err := checkDuplicateIDs("chat message", ids); err != nil {
+		return err
+	}
 
-	// ✅ 性能优化：提取图片数据并分离存储
+	// 远程图像在加锁前下载，慢速下载不阻塞其他历史记录读写
+	var sources []string
 	for i := range messages {
-		if len(messages[i].Images) == 0 {
-			continue
-		}
+		sources = append(sources, messages[i].Images...)
+	}
+	remote := h.fetchRemoteImages(sources)
 
-		refs := make([]string, 0, len(messages[i].Images))
-		for _, img := range messages[i].Images {
-			if img == "" {
-				refs = append(refs, "")
-				continue
-			}
-			if strings.HasPrefix(img, "/images/") {
-				refs = append(refs, strings.TrimPrefix(img, "/"))
-				continue
-			}
-			if strings.HasPrefix(img, "images/") {
-				refs = append(refs, img)
-				continue
-			}
-			ref, err := h.imageStorage.SaveImage(img)
-			if err != nil {
-				return fmt.Errorf("failed to save image for message %s: %w", messages[i].ID, err)
-			}
-			refs = append(refs, ref)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// ✅ 性能优化：提取图片数据并分离存储
+	for i := range messages {
+		if err := h.storeMessageImages(&messages[i], remote); err != nil {
+			return err
 		}
-		messages[i].Images = refs
 	}
+	messages = h.mergeChatAppendsLocked(messages, requestedAt)
 	history := ChatHistory{
 		Version:   "2.0", // 版本号升级，表示使用新格式
 		UpdatedAt: time.Now().Unix(),
@@ -399,16 +441,9 @@ func (h *HistoryService) saveChatHistorySync(chatHistoryJSON string) error {
 		return fmt.Errorf("failed to serialize chat history: %w", err)
 	}
 
-	// ✅ 性能优化：使用临时文件 + 原子性重命名，避免写入过程中的数据损坏
-	tempFile := h.chatFile + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp chat history file: %w", err)
-	}
-
-	// 原子性重命名，确保文件完整性
-	if err := os.Rename(tempFile, h.chatFile); err != nil {
-		os.Remove(tempFile) // 清理临时文件
-		return fmt.Errorf("failed to rename chat history file: %w", err)
+	// ✅ 性能优化：使用临时文件 + 原子性重命名，避免写入过程中的数据损坏（启用时以 gzip 压缩）
+	if err := h.writeHistoryFile(h.chatFile, data); err != nil {
+		return fmt.Errorf("failed to write chat history file: %w", err)
 	}
 
 	return nil
@@ -416,10 +451,8 @@ func (h *HistoryService) saveChatHistorySync(chatHistoryJSON string) error {
 
 // saveCanvasHistorySync 同步保存画布历史（内部方法，在后台 goroutine 中调用）
 // ✅ 性能优化：图片分离存储 + JSON 压缩
+// 图像记录 ID 重复时拒绝整次保存，返回 *DuplicateIDError
 func (h *HistoryService) saveCanvasHistorySync(canvasHistoryJSON string) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
 	// 解析画布数据
 	var canvasData struct {
 		Viewport ViewportRecord `json:"viewport"`
@@ -428,6 +461,23 @@ func (h *HistoryService) saveCanvasHistorySync(canvasHistoryJSON string) error {
 	if err := json.Unmarshal([]byte(canvasHistoryJSON), &canvasData); err != nil {
 		return fmt.Errorf("invalid canvas history format: %w", err)
 	}
+	ids := make([]string, len(canvasData.Images))
+	for i := range canvasData.Images {
+		ids[i] = canvasData.Images[i].ID
+	}
+	if err := checkDuplicateIDs("canvas image", ids); err != nil {
+		return err
+	}
+
+	// 远程图像在加锁前下载，慢速下载不阻塞其他历史记录读写
+	sources := make([]string, len(canvasData.Images))
+	for i := range canvasData.Images {
+		sources[i] = canvasData.Images[i].Src
+	}
+	remote := h.fetchRemoteImages(sources)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
 	// ✅ 性能优化：提取图片数据并分离存储
 	for i := range canvasData.Images {
@@ -441,7 +491,7 @@ func (h *HistoryService) saveCanvasHistorySync(canvasHistoryJSON string) error {
 		if strings.HasPrefix(canvasData.Images[i].Src, "images/") {
 			continue
 		}
-		imageRef, err := h.imageStorage.SaveImage(canvasData.Images[i].Src)
+		imageRef, err := h.saveImageSource(canvasData.Images[i].Src, remote)
 		if err != nil {
 			return fmt.Errorf("failed to save image %s: %w", canvasData.Images[i].ID, err)
 		}
@@ -460,22 +510,21 @@ func (h *HistoryService) saveCanvasHistorySync(canvasHistoryJSON string) error {
 		return fmt.Errorf("failed to serialize canvas history: %w", err)
 	}
 
-	// ✅ 性能优化：使用临时文件 + 原子性重命名，避免写入过程中的数据损坏
-	tempFile := h.canvasFile + ".tmp"
-	if err := os.WriteFile(tempFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write temp canvas history file: %w", err)
+	// ✅ 性能优化：使用临时文件 + 原子性重命名，避免写入过程中的数据损坏（启用时以 gzip 压缩）
+	if err := h.writeHistoryFile(h.canvasFile, data); err != nil {
+		return fmt.Errorf("failed to write canvas history file: %w", err)
 	}
 
-	// 原子性重命名，确保文件完整性
-	if err := os.Rename(tempFile, h.canvasFile); err != nil {
-		os.Remove(tempFile) // 清理临时文件
-		return fmt.Errorf("failed to rename canvas history file: %w", err)
+	// 视口文件优先于画布文件中的视口，整体保存时同步更新，保持两者一致
+	if err := h.writeViewportLocked(canvasData.Viewport); err != nil {
+		fmt.Printf("[HistoryService] Warning: %v\n", err)
 	}
 
 	return nil
 }
 
-// StoreImage persists a data URL and returns an image ref.
+// StoreImage persists a data URL or http(s) URL and returns a local image ref.
+// 已是本地 image ref 时原样返回
 func (h *HistoryService) StoreImage(dataURL string) (string, error) {
 	if dataURL == "" {
 		return "", nil
@@ -489,9 +538,113 @@ func (h *HistoryService) StoreImage(dataURL string) (string, error) {
 	if h.imageStorage == nil {
 		return "", fmt.Errorf("image storage not initialized")
 	}
+	if isRemoteImageSource(dataURL) {
+		// 远程图像下载到本地，保证图像离线可用
+		ctx, cancel := h.remoteImageContext()
+		defer cancel()
+		return h.imageStorage.SaveImageFromURL(ctx, dataURL)
+	}
 	return h.imageStorage.SaveImage(dataURL)
 }
 
+// historyImageFetchTimeout 保存历史记录时下载单张远程图像的超时时间
+const historyImageFetchTimeout = 30 * time.Second
+
+// isRemoteImageSource 判断图像来源是否为 http(s) URL
+func isRemoteImageSource(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://")
+}
+
+// remoteImageContext 返回下载单张远程图像使用的上下文（应用退出时取消，并带有超时）
+func (h *HistoryService) remoteImageContext() (context.Context, context.CancelFunc) {
+	parent := h.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	return context.WithTimeout(parent, historyImageFetchTimeout)
+}
+
+// fetchRemoteImages 下载 sources 中的 http(s) 图像，返回 URL 到本地 image ref 的映射（内部方法，调用方不能持有 mu）
+// 保存历史记录时在加锁前调用；下载失败只记录警告，该 URL 不在映射中，保存时保留原始 URL
+func (h *HistoryService) fetchRemoteImages(sources []string) map[string]string {
+	if h.imageStorage == nil {
+		return nil
+	}
+
+	var refs map[string]string
+	attempted := make(map[string]bool)
+	for _, src := range sources {
+		if !isRemoteImageSource(src) || attempted[src] {
+			continue
+		}
+		attempted[src] = true
+
+		ctx, cancel := h.remoteImageContext()
+		ref, err := h.imageStorage.SaveImageFromURL(ctx, src)
+		cancel()
+		if err != nil {
+			fmt.Printf("[HistoryService] Warning: failed to download remote image, keeping the URL: %v\n", err)
+			continue
+		}
+		if refs == nil {
+			refs = make(map[string]string)
+		}
+		refs[src] = ref
+	}
+	return refs
+}
+
+// saveImageSource 保存 data URL 指向的图像，返回本地 image ref（内部方法，调用方需持有 mu）
+// http(s) URL 使用 fetchRemoteImages 预先下载的结果，未能下载时原样保留 URL
+func (h *HistoryService) saveImageSource(src string, remote map[string]string) (string, error) {
+	if isRemoteImageSource(src) {
+		if ref, ok := remote[src]; ok {
+			return ref, nil
+		}
+		return src, nil
+	}
+	return h.imageStorage.SaveImage(src)
+}
+
+// GetImageDedupeReport 获取图像存储的去重统计
+// 返回 JSON 格式：{"newWrites", "dedupeHits", "bytesSaved", "bytesNew", "since"}
+func (h *HistoryService) GetImageDedupeReport() (string, error) {
+	if h.imageStorage == nil {
+		return "", fmt.Errorf("image storage not initialized")
+	}
+	data, err := json.Marshal(h.imageStorage.DedupeReport())
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize dedupe report: %w", err)
+	}
+	return string(data), nil
+}
+
+// GetImageDimensions 获取图像宽高，只读取文件头
+// 返回 JSON 格式：{"width": number, "height": number}
+func (h *HistoryService) GetImageDimensions(imageRef string) (string, error) {
+	if h.imageStorage == nil {
+		return "", fmt.Errorf("image storage not initialized")
+	}
+	width, height, err := h.imageStorage.GetImageDimensions(imageRef)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]int{"width": width, "height": height})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize image dimensions: %w", err)
+	}
+	return string(data), nil
+}
+
+// ExtractFirstFrame 提取动画图像的第一帧并保存为新的 PNG 图像，返回新的 image ref
+// 静态图像直接返回原 ref
+func (h *HistoryService) ExtractFirstFrame(imageRef string) (string, error) {
+	if h.imageStorage == nil {
+		return "", fmt.Errorf("image storage not initialized")
+	}
+	return h.imageStorage.ExtractFirstFrame(imageRef)
+}
+
 // ==================== 同步保存 API（用于应用关闭时）====================
 
 // SaveChatHistorySync 同步保存聊天历史记录（公共方法，直接保存，不走事件队列）
@@ -542,7 +695,7 @@ func (h *HistoryService) LoadChatHistory() (string, error) {
 
 	if _, err := os.Stat(h.chatFile); err == nil {
 		// 读取文件
-		data, err = os.ReadFile(h.chatFile)
+		data, err = readHistoryFile(h.chatFile)
 		if err != nil {
 			return "", fmt.Errorf("failed to read chat history file: %w", err)
 		}
@@ -570,7 +723,8 @@ func (h *HistoryService) LoadChatHistory() (string, error) {
 				filtered = append(filtered, strings.TrimPrefix(ref, "/"))
 				continue
 			}
-			if strings.HasPrefix(ref, "images/") {
+			// 保存时未能下载的远程图像保留 URL，下次保存时重新尝试下载
+			if strings.HasPrefix(ref, "images/") || isRemoteImageSource(ref) {
 				filtered = append(filtered, ref)
 				continue
 			}
@@ -589,16 +743,31 @@ func (h *HistoryService) LoadChatHistory() (string, error) {
 }
 
 // ClearChatHistory 清除聊天历史记录
-func (h *HistoryService) ClearChatHistory() error {
+// pruneImages 为 true 时同时删除只被聊天历史引用的图片（画布历史仍引用的保留），返回删除结果；
+// 会先写入待保存的历史记录，前端应在清除前保存画布，避免尚未保存的画布图片被删除
+func (h *HistoryService) ClearChatHistory(pruneImages bool) (CleanupResult, error) {
+	if pruneImages {
+		h.flushPendingSaves()
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	var orphans []string
+	if pruneImages {
+		var err error
+		if orphans, err = h.clearedImageRefs(true); err != nil {
+			fmt.Printf("[HistoryService] Warning: skipping image pruning: %v\n", err)
+		}
+	}
+
 	// 删除文件（如果存在）
 	if err := os.Remove(h.chatFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove chat history file: %w", err)
+		return CleanupResult{}, fmt.Errorf("failed to remove chat history file: %w", err)
 	}
+	h.chatAppends = nil
 
-	return nil
+	return h.deleteOrphanImages(orphans), nil
 }
 
 // ==================== 画布记录 API ====================
@@ -620,19 +789,20 @@ type ViewportRecord struct {
 
 // ImageRecord 图像记录
 type ImageRecord struct {
-	ID       string  `json:"id"`
-	Src      string  `json:"src"` // image refs (images/{hash}.{ext})
-	X        float64 `json:"x"`
-	Y        float64 `json:"y"`
-	Width    float64 `json:"width"`
-	Height   float64 `json:"height"`
-	ZIndex   int     `json:"zIndex"`
-	Prompt   string  `json:"prompt"`
-	Rotation float64 `json:"rotation,omitempty"` // 旋转角度（度），默认 0
+	ID              string  `json:"id"`
+	Src             string  `json:"src"` // image refs (images/{hash}.{ext})
+	X               float64 `json:"x"`
+	Y               float64 `json:"y"`
+	Width           float64 `json:"width"`
+	Height          float64 `json:"height"`
+	ZIndex          int     `json:"zIndex"`
+	Prompt          string  `json:"prompt"`
+	Rotation        float64 `json:"rotation,omitempty"`        // 旋转角度（度），默认 0
+	SourceMessageID string  `json:"sourceMessageId,omitempty"` // 生成该图像的聊天消息 ID（可选），可通过 GetChatMessage 跳转到原始提示词
 }
 
 // LoadCanvasHistory 加载画布历史记录
-// 返回 JSON 格式的画布记录，包含 viewport 和 images
+// 返回 JSON 格式的画布记录，包含 viewport 和 images（按 zIndex 升序，即绘制顺序）
 // ✅ 性能优化：支持压缩格式和图片引用加载
 func (h *HistoryService) LoadCanvasHistory() (string, error) {
 	h.mu.Lock()
@@ -644,7 +814,7 @@ func (h *HistoryService) LoadCanvasHistory() (string, error) {
 
 	if _, err := os.Stat(h.canvasFile); err == nil {
 		// 读取文件
-		data, err = os.ReadFile(h.canvasFile)
+		data, err = readHistoryFile(h.canvasFile)
 		if err != nil {
 			return "", fmt.Errorf("failed to read canvas history file: %w", err)
 		}
@@ -657,6 +827,9 @@ func (h *HistoryService) LoadCanvasHistory() (string, error) {
 			Viewport: ViewportRecord{X: 0, Y: 0, Zoom: 1.0},
 			Images:   []ImageRecord{},
 		}
+		if viewport, ok := h.loadViewportLocked(); ok {
+			defaultData.Viewport = viewport
+		}
 		data, _ := json.Marshal(defaultData)
 		return string(data), nil
 	}
@@ -677,11 +850,23 @@ func (h *HistoryService) LoadCanvasHistory() (string, error) {
 			history.Images[i].Src = strings.TrimPrefix(history.Images[i].Src, "/")
 			continue
 		}
-		if !strings.HasPrefix(history.Images[i].Src, "images/") {
+		// 保存时未能下载的远程图像保留 URL，下次保存时重新尝试下载
+		if !strings.HasPrefix(history.Images[i].Src, "images/") && !isRemoteImageSource(history.Images[i].Src) {
 			fmt.Printf("[HistoryService] Warning: drop non-image reference for image %s\n", history.Images[i].ID)
 			history.Images[i].Src = ""
 		}
 	}
+	// 视口单独保存在 canvas_viewport.json，存在时优先使用（旧版只有合并格式）
+	if viewport, ok := h.loadViewportLocked(); ok {
+		history.Viewport = viewport
+	}
+	// 按绘制顺序返回：ZIndex 升序，相同时按 ID 排序，保证顺序确定
+	sort.SliceStable(history.Images, func(i, j int) bool {
+		if history.Images[i].ZIndex != history.Images[j].ZIndex {
+			return history.Images[i].ZIndex < history.Images[j].ZIndex
+		}
+		return history.Images[i].ID < history.Images[j].ID
+	})
 	result := struct {
 		Viewport ViewportRecord `json:"viewport"`
 		Images   []ImageRecord  `json:"images"`
@@ -699,20 +884,37 @@ func (h *HistoryService) LoadCanvasHistory() (string, error) {
 }
 
 // ClearCanvasHistory 清除画布历史记录
-func (h *HistoryService) ClearCanvasHistory() error {
+// pruneImages 为 true 时同时删除只被画布历史引用的图片（聊天历史仍引用的保留），返回删除结果；
+// 会先写入待保存的历史记录，前端应在清除前保存聊天记录，避免尚未保存的聊天图片被删除
+func (h *HistoryService) ClearCanvasHistory(pruneImages bool) (CleanupResult, error) {
+	if pruneImages {
+		h.flushPendingSaves()
+	}
+
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	var orphans []string
+	if pruneImages {
+		var err error
+		if orphans, err = h.clearedImageRefs(false); err != nil {
+			fmt.Printf("[HistoryService] Warning: skipping image pruning: %v\n", err)
+		}
+	}
+
 	// 删除文件（如果存在）
 	if err := os.Remove(h.canvasFile); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove canvas history file: %w", err)
+		return CleanupResult{}, fmt.Errorf("failed to remove canvas history file: %w", err)
+	}
+	if h.viewportFile != "" {
+		os.Remove(h.viewportFile) // 忽略错误
 	}
 
 	// 同时删除旧格式文件（如果存在）
 	oldFile := filepath.Join(h.dataDir, "canvas_history.json")
 	os.Remove(oldFile) // 忽略错误
 
-	return nil
+	return h.deleteOrphanImages(orphans), nil
 }
 
 // ==================== 数据迁移 ====================
@@ -832,7 +1034,7 @@ func (h *HistoryService) normalizeChatHistoryImages() error {
 		return nil
 	}
 
-	data, err := os.ReadFile(h.chatFile)
+	data, err := readHistoryFile(h.chatFile)
 	if err != nil {
 		return fmt.Errorf("failed to read chat history file: %w", err)
 	}
@@ -877,7 +1079,7 @@ func (h *HistoryService) normalizeCanvasHistoryImages() error {
 		return nil
 	}
 
-	data, err := os.ReadFile(h.canvasFile)
+	data, err := readHistoryFile(h.canvasFile)
 	if err != nil {
 		return fmt.Errorf("failed to read canvas history file: %w", err)
 	}
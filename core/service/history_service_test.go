@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistoryService 创建使用临时数据目录的历史记录服务（不启动保存队列）
+func newTestHistoryService(t *testing.T) *HistoryService {
+	t.Helper()
+
+	dir := t.TempDir()
+	h := NewHistoryService(nil)
+	h.dataDir = dir
+	h.chatFile = filepath.Join(dir, "chat_history.json")
+	h.canvasFile = filepath.Join(dir, "canvas_history.json")
+	h.viewportFile = filepath.Join(dir, canvasViewportFileName)
+	h.imageStorage = NewImageStorage(dir)
+	if err := h.imageStorage.Initialize(); err != nil {
+		t.Fatalf("initialize image storage: %v", err)
+	}
+	return h
+}
+
+func TestLoadCanvasHistorySortsByZIndex(t *testing.T) {
+	h := newTestHistoryService(t)
+
+	stored := CanvasHistory{
+		Version: "2.0",
+		Images: []ImageRecord{
+			{ID: "e", ZIndex: 3},
+			{ID: "b", ZIndex: 1},
+			{ID: "d", ZIndex: 2},
+			{ID: "a", ZIndex: 1},
+			{ID: "f", ZIndex: -1},
+			{ID: "c", ZIndex: 2},
+		},
+	}
+	data, err := json.Marshal(stored)
+	if err != nil {
+		t.Fatalf("marshal canvas history: %v", err)
+	}
+	if err := os.WriteFile(h.canvasFile, data, 0644); err != nil {
+		t.Fatalf("write canvas history: %v", err)
+	}
+
+	want := []string{"f", "a", "b", "c", "d", "e"}
+	for attempt := 0; attempt < 3; attempt++ {
+		loadedJSON, err := h.LoadCanvasHistory()
+		if err != nil {
+			t.Fatalf("LoadCanvasHistory: %v", err)
+		}
+		var loaded struct {
+			Images []ImageRecord `json:"images"`
+		}
+		if err := json.Unmarshal([]byte(loadedJSON), &loaded); err != nil {
+			t.Fatalf("parse loaded canvas history: %v", err)
+		}
+
+		if len(loaded.Images) != len(want) {
+			t.Fatalf("expected %d images, got %d", len(want), len(loaded.Images))
+		}
+		for i, id := range want {
+			if loaded.Images[i].ID != id {
+				got := make([]string, len(loaded.Images))
+				for j, img := range loaded.Images {
+					got[j] = img.ID
+				}
+				t.Fatalf("attempt %d: expected order %v, got %v", attempt, want, got)
+			}
+		}
+	}
+}
+
+func TestSaveCanvasHistoryKeepsFailedRemoteImages(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngData.Bytes())
+	}))
+	defer server.Close()
+
+	h := newTestHistoryService(t)
+	canvasJSON, _ := json.Marshal(map[string]interface{}{
+		"images": []ImageRecord{
+			{ID: "ok", Src: server.URL + "/ok.png"},
+			{ID: "missing", Src: server.URL + "/missing.png"},
+		},
+	})
+	if err := h.SaveCanvasHistorySync(string(canvasJSON)); err != nil {
+		t.Fatalf("a failed download should not fail the save: %v", err)
+	}
+
+	loadedJSON, err := h.LoadCanvasHistory()
+	if err != nil {
+		t.Fatalf("LoadCanvasHistory: %v", err)
+	}
+	var loaded CanvasHistory
+	if err := json.Unmarshal([]byte(loadedJSON), &loaded); err != nil {
+		t.Fatalf("parse canvas history: %v", err)
+	}
+	srcs := make(map[string]string)
+	for _, img := range loaded.Images {
+		srcs[img.ID] = img.Src
+	}
+	if !strings.HasPrefix(srcs["ok"], "images/") && !strings.HasPrefix(srcs["ok"], "/images/") {
+		t.Fatalf("expected the downloaded image to become a local ref, got %q", srcs["ok"])
+	}
+	if srcs["missing"] != server.URL+"/missing.png" {
+		t.Fatalf("expected the failed image to keep its URL, got %q", srcs["missing"])
+	}
+}
+
+func TestSlowRemoteImageDoesNotBlockHistoryReads(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	defer close(release)
+
+	h := newTestHistoryService(t)
+	chatJSON, _ := json.Marshal([]ChatRecord{{ID: "m1", Images: []string{server.URL + "/slow.png"}}})
+	saved := make(chan error, 1)
+	go func() { saved <- h.SaveChatHistorySync(string(chatJSON)) }()
+
+	// 等待下载开始后读取历史记录，不应等待下载完成
+	time.Sleep(100 * time.Millisecond)
+	loaded := make(chan error, 1)
+	go func() {
+		_, err := h.LoadChatHistory()
+		loaded <- err
+	}()
+	select {
+	case err := <-loaded:
+		if err != nil {
+			t.Fatalf("LoadChatHistory: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("LoadChatHistory was blocked by a remote image download")
+	}
+
+	release <- struct{}{}
+	if err := <-saved; err != nil {
+		t.Fatalf("save with a failed download: %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TrashImage 将删除的画布图片移入回收站，可通过 RestoreTrashedImage 撤销
+// 聊天历史仍引用该图片时保留文件不移动（直接返回 nil），避免聊天记录中的图片失效；
+// 回收站中的图片保留 30 天，之后在启动时永久删除
+func (h *HistoryService) TrashImage(imageRef string) error {
+	if h.imageStorage == nil {
+		return fmt.Errorf("image storage not initialized")
+	}
+
+	refs, err := h.collectChatImageRefs()
+	if err != nil {
+		return fmt.Errorf("failed to collect image references: %w", err)
+	}
+	if refs[h.imageStorage.getImageRef(h.imageStorage.parseImageRef(imageRef))] {
+		return nil
+	}
+	return h.imageStorage.TrashImage(imageRef)
+}
+
+// RestoreTrashedImage 从回收站恢复图片，恢复后原 image ref 可以继续使用
+func (h *HistoryService) RestoreTrashedImage(imageRef string) error {
+	if h.imageStorage == nil {
+		return fmt.Errorf("image storage not initialized")
+	}
+	return h.imageStorage.RestoreTrashedImage(imageRef)
+}
+
+// ListTrashedImages 列出回收站中的图片
+// 返回 JSON 数组：[{"ref", "path", "trashName", "trashedAt"}...]，按删除时间倒序
+func (h *HistoryService) ListTrashedImages() (string, error) {
+	if h.imageStorage == nil {
+		return "", fmt.Errorf("image storage not initialized")
+	}
+	tombstones, err := h.imageStorage.ListTrashedImages()
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize trashed images: %w", err)
+	}
+	return string(data), nil
+}
+
+// purgeExpiredTrash 永久删除回收站中超过保留时间的图片（内部方法，启动时调用）
+func (h *HistoryService) purgeExpiredTrash() {
+	purged, err := h.imageStorage.PurgeTrash(trashRetention)
+	if err != nil {
+		fmt.Printf("[HistoryService] Warning: failed to purge image trash: %v\n", err)
+		return
+	}
+	if purged > 0 {
+		fmt.Printf("[HistoryService] Purged %d expired images from trash\n", purged)
+	}
+}
+
+// collectChatImageRefs 收集聊天历史中引用的图片 ref（内部方法）
+func (h *HistoryService) collectChatImageRefs() (map[string]bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chatFile == "" {
+		return nil, fmt.Errorf("history service not initialized")
+	}
+	var chat ChatHistory
+	if err := verifyJSONFile(h.chatFile, &chat); err != nil {
+		return nil, fmt.Errorf("chat history: %w", err)
+	}
+
+	refs := make(map[string]bool)
+	for _, message := range chat.Messages {
+		for _, img := range message.Images {
+			refs[h.imageStorage.getImageRef(h.imageStorage.parseImageRef(img))] = true
+		}
+	}
+	return refs, nil
+}
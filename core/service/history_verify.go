@@ -0,0 +1,43 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// VerifyHistory 检查聊天和画布历史文件能否正常解析
+// 文件不存在视为正常（尚未保存过历史）；返回第一个无法读取或解析的文件的错误
+func (h *HistoryService) VerifyHistory() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.chatFile == "" || h.canvasFile == "" {
+		return fmt.Errorf("history service not initialized")
+	}
+
+	var chat ChatHistory
+	if err := verifyJSONFile(h.chatFile, &chat); err != nil {
+		return fmt.Errorf("chat history: %w", err)
+	}
+	var canvas CanvasHistory
+	if err := verifyJSONFile(h.canvasFile, &canvas); err != nil {
+		return fmt.Errorf("canvas history: %w", err)
+	}
+	return nil
+}
+
+// verifyJSONFile 读取并解析 JSON 文件（支持 gzip 压缩的历史文件），文件不存在时返回 nil
+func verifyJSONFile(path string, target interface{}) error {
+	data, err := readHistoryFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// canvasViewportFileName 画布视口文件（与 canvas_history.json 位于同一目录）
+// 平移和缩放只改变视口，单独保存到这个小文件，不必重新序列化全部图像记录
+const canvasViewportFileName = "canvas_viewport.json"
+
+// canvasViewportFile 视口文件结构
+type canvasViewportFile struct {
+	UpdatedAt int64          `json:"updatedAt"`
+	Viewport  ViewportRecord `json:"viewport"`
+}
+
+// registerViewportEventHandler 监听前端发送的视口保存请求（history:save-viewport，载荷为 {"x", "y", "zoom"}）
+// 与聊天、画布保存相同，只保留最新一次请求，由保存队列处理器统一写入
+func (h *HistoryService) registerViewportEventHandler(ctx context.Context) {
+	runtime.EventsOn(ctx, "history:save-viewport", func(data ...interface{}) {
+		if len(data) == 0 {
+			return
+		}
+		viewportJSON, ok := data[0].(string)
+		if !ok || len(viewportJSON) == 0 {
+			return
+		}
+
+		h.pendingSaveMu.Lock()
+		h.pendingViewportSave = &saveRequest{
+			saveType:  "viewport",
+			data:      viewportJSON,
+			timestamp: time.Now().UnixNano(),
+		}
+		h.pendingSaveMu.Unlock()
+
+		h.viewportDirty.Store(true)
+	})
+}
+
+// saveViewportSync 同步保存画布视口
+func (h *HistoryService) saveViewportSync(viewportJSON string) error {
+	var viewport ViewportRecord
+	if err := json.Unmarshal([]byte(viewportJSON), &viewport); err != nil {
+		return fmt.Errorf("invalid viewport format: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.writeViewportLocked(viewport)
+}
+
+// writeViewportLocked 写入视口文件（调用方需持有 mu）
+func (h *HistoryService) writeViewportLocked(viewport ViewportRecord) error {
+	if h.viewportFile == "" {
+		return fmt.Errorf("history service not initialized")
+	}
+	data, err := json.Marshal(canvasViewportFile{
+		UpdatedAt: time.Now().Unix(),
+		Viewport:  viewport,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize viewport: %w", err)
+	}
+	if err := writeFileAtomic(h.viewportFile, data); err != nil {
+		return fmt.Errorf("failed to write viewport file: %w", err)
+	}
+	return nil
+}
+
+// loadViewportLocked 读取视口文件（调用方需持有 mu）
+// 文件不存在或无法解析时返回 false，此时使用 canvas_history.json 中的视口（兼容旧版合并格式）
+func (h *HistoryService) loadViewportLocked() (ViewportRecord, bool) {
+	if h.viewportFile == "" {
+		return ViewportRecord{}, false
+	}
+	data, err := os.ReadFile(h.viewportFile)
+	if err != nil {
+		return ViewportRecord{}, false
+	}
+	var file canvasViewportFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Viewport.Zoom <= 0 {
+		return ViewportRecord{}, false
+	}
+	return file.Viewport, true
+}
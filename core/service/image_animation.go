@@ -0,0 +1,110 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"os"
+)
+
+// ErrAnimatedImage 动画图像（多帧 GIF 或动画 WebP）无法在不丢失帧的情况下转换格式
+// 通过 convertImageFormat 等返回的错误包装此错误，调用方使用 errors.Is 判断
+var ErrAnimatedImage = errors.New("animated image cannot be converted without losing frames")
+
+// imageFrameCount 返回图像的帧数，静态图像和无法识别的数据返回 1
+// GIF 通过解码器读取全部帧；WebP 没有解码器，按 RIFF 块统计 ANMF（动画帧）块
+func imageFrameCount(data []byte) int {
+	switch detectImageFormat(data) {
+	case "gif":
+		decoded, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil || len(decoded.Image) == 0 {
+			return 1
+		}
+		return len(decoded.Image)
+	case "webp":
+		return webpFrameCount(data)
+	default:
+		return 1
+	}
+}
+
+// webpFrameCount 统计 WebP 文件中的动画帧块数量（内部函数）
+// 文件结构：'RIFF' <size> 'WEBP'，之后为 <fourcc><size><payload> 块序列，payload 按偶数字节对齐
+func webpFrameCount(data []byte) int {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 1
+	}
+
+	frames := 0
+	for offset := 12; offset+8 <= len(data); {
+		fourCC := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		if fourCC == "ANMF" {
+			frames++
+		}
+		next := offset + 8 + size + size%2
+		if size < 0 || next <= offset {
+			break
+		}
+		offset = next
+	}
+	if frames == 0 {
+		return 1
+	}
+	return frames
+}
+
+// animatedImageError 返回说明动画图像无法转换的错误（内部函数）
+func animatedImageError(sourceFormat string, frames int) error {
+	return fmt.Errorf("%w: %s image has %d frames, export it as %s to keep the animation or extract the first frame first", ErrAnimatedImage, sourceFormat, frames, sourceFormat)
+}
+
+// ExtractFirstFrame 提取动画图像的第一帧并保存为新图像，返回新图像的 image ref
+// 用于显式地把动画转换为静态图像（导出为 PNG/JPEG 或发送给提供商前）；原图不受影响
+// 支持 GIF；动画 WebP 因缺少解码器无法提取，返回错误。静态图像直接返回原 ref
+func (s *ImageStorage) ExtractFirstFrame(imageRef string) (string, error) {
+	filePath, err := s.GetImagePath(imageRef)
+	if err != nil {
+		return "", err
+	}
+	if filePath == "" {
+		return "", fmt.Errorf("empty image reference")
+	}
+
+	s.mu.RLock()
+	data, err := os.ReadFile(filePath)
+	s.mu.RUnlock()
+	if err != nil {
+		return "", fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	frames := imageFrameCount(data)
+	if frames <= 1 {
+		return imageRef, nil
+	}
+
+	format := detectImageFormat(data)
+	if format != "gif" {
+		return "", fmt.Errorf("extracting frames from animated %s images is not supported", format)
+	}
+	decoded, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode gif image: %w", err)
+	}
+
+	// 第一帧可能只覆盖画布的一部分，绘制到完整的逻辑画布上
+	canvas := image.NewNRGBA(image.Rect(0, 0, decoded.Config.Width, decoded.Config.Height))
+	first := decoded.Image[0]
+	draw.Draw(canvas, first.Bounds(), first, first.Bounds().Min, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return "", fmt.Errorf("failed to encode png: %w", err)
+	}
+	return s.saveImageBytes(buf.Bytes(), "image/png")
+}
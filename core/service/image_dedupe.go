@@ -0,0 +1,50 @@
+package service
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DedupeReport 图像存储去重统计（自应用启动以来）
+// 图像按内容哈希存储，内容相同的图像只写入一次
+type DedupeReport struct {
+	NewWrites  int64 `json:"newWrites"`  // 写入了新文件的保存次数
+	DedupeHits int64 `json:"dedupeHits"` // 命中已有文件、未重复写入的保存次数
+	BytesSaved int64 `json:"bytesSaved"` // 去重节省的字节数（命中时未写入的图像数据总大小）
+	BytesNew   int64 `json:"bytesNew"`   // 新写入的字节数
+	Since      int64 `json:"since"`      // 统计开始时间（Unix 秒）
+}
+
+// imageDedupeStats 去重计数器
+// 各服务持有各自的 ImageStorage 实例但共享同一个图像目录，计数器为进程级共享
+var imageDedupeStats struct {
+	newWrites  atomic.Int64
+	dedupeHits atomic.Int64
+	bytesSaved atomic.Int64
+	bytesNew   atomic.Int64
+}
+
+// imageDedupeSince 统计开始时间
+var imageDedupeSince = time.Now().Unix()
+
+// recordImageSave 记录一次图像保存的去重结果
+func recordImageSave(size int, deduped bool) {
+	if deduped {
+		imageDedupeStats.dedupeHits.Add(1)
+		imageDedupeStats.bytesSaved.Add(int64(size))
+		return
+	}
+	imageDedupeStats.newWrites.Add(1)
+	imageDedupeStats.bytesNew.Add(int64(size))
+}
+
+// DedupeReport 返回自应用启动以来的去重统计
+func (s *ImageStorage) DedupeReport() DedupeReport {
+	return DedupeReport{
+		NewWrites:  imageDedupeStats.newWrites.Load(),
+		DedupeHits: imageDedupeStats.dedupeHits.Load(),
+		BytesSaved: imageDedupeStats.bytesSaved.Load(),
+		BytesNew:   imageDedupeStats.bytesNew.Load(),
+		Since:      imageDedupeSince,
+	}
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"fmt"
+	"image"
+	"os"
+)
+
+// GetImageDimensions 返回图像的宽高（像素）
+// 只读取文件头（image.DecodeConfig），不解码像素数据，用于画布布局等只需要尺寸的场景
+// 支持 PNG、JPEG、GIF；WebP 等没有注册解码器的格式返回错误
+func (s *ImageStorage) GetImageDimensions(imageRef string) (int, int, error) {
+	filePath, err := s.GetImagePath(imageRef)
+	if err != nil {
+		return 0, 0, err
+	}
+	if filePath == "" {
+		return 0, 0, fmt.Errorf("empty image reference")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open image file: %w", err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read image header: %w", err)
+	}
+	return config.Width, config.Height, nil
+}
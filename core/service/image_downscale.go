@@ -0,0 +1,96 @@
+package service
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strings"
+)
+
+const (
+	// defaultMaxInputImageBytes 发送给提供商的单张输入图像（解码后）的默认大小上限
+	defaultMaxInputImageBytes = 8 << 20
+	// downscaleJPEGQuality 缩小后重新编码为 JPEG 时使用的质量
+	downscaleJPEGQuality = 90
+	// maxDownscaleAttempts 缩小后仍超过上限时的最大重试次数（每次再缩小 25%）
+	maxDownscaleAttempts = 4
+)
+
+// shrinkImageInput 输入图像超过大小上限时缩小并重新编码（内部方法）
+// 只处理发送给提供商的副本，存储中的原图保持不变；
+// http URL、无法解码的格式（如 WebP）或处理失败时原样返回，由提供商决定是否接受
+func (a *AIService) shrinkImageInput(imageData string, label string) string {
+	if imageData == "" || strings.HasPrefix(imageData, "http://") || strings.HasPrefix(imageData, "https://") {
+		return imageData
+	}
+
+	limit := a.maxInputImageBytes.Load()
+	if limit <= 0 {
+		limit = defaultMaxInputImageBytes
+	}
+
+	data, _, err := decodeImageDataURL(imageData)
+	if err != nil || int64(len(data)) <= limit {
+		return imageData
+	}
+
+	shrunk, err := downscaleImageBytes(data, limit)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: %s is %d bytes (limit %d) and could not be downscaled: %v\n", label, len(data), limit, err)
+		return imageData
+	}
+	return shrunk
+}
+
+// shrinkImageInputs 对一组输入图像执行 shrinkImageInput（内部方法）
+func (a *AIService) shrinkImageInputs(images []string, label string) []string {
+	for i, img := range images {
+		images[i] = a.shrinkImageInput(img, fmt.Sprintf("%s %d", label, i))
+	}
+	return images
+}
+
+// downscaleImageBytes 按比例缩小图像直到编码后不超过 limit 字节，返回 data URL
+// 含透明像素的图像编码为 PNG，其余编码为 JPEG
+func downscaleImageBytes(data []byte, limit int64) (string, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	transparent := hasTransparency(img)
+	// 编码后大小近似与像素数成正比，先按面积比例估算缩放系数
+	scale := math.Sqrt(float64(limit) / float64(len(data)))
+
+	for attempt := 0; attempt < maxDownscaleAttempts; attempt++ {
+		width := max(1, int(float64(bounds.Dx())*scale))
+		height := max(1, int(float64(bounds.Dy())*scale))
+		resized := resizeImage(img, width, height)
+
+		var buf bytes.Buffer
+		mimeType := "image/jpeg"
+		if transparent {
+			mimeType = "image/png"
+			err = png.Encode(&buf, resized)
+		} else {
+			err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: downscaleJPEGQuality})
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to encode image: %w", err)
+		}
+
+		if int64(buf.Len()) <= limit {
+			fmt.Printf("[AIService] Downscaled input image from %dx%d %s (%d bytes) to %dx%d %s (%d bytes)\n",
+				bounds.Dx(), bounds.Dy(), format, len(data), width, height, strings.TrimPrefix(mimeType, "image/"), buf.Len())
+			return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+		}
+		scale *= 0.75
+	}
+
+	return "", fmt.Errorf("image still exceeds %d bytes after %d attempts", limit, maxDownscaleAttempts)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// defaultExportQuality 有损格式的默认导出质量（1-100）
+const defaultExportQuality = 90
+
+// defaultExportBackground 透明图像导出为不支持透明通道的格式时使用的默认背景色（白色）
+var defaultExportBackground = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+// resolveExportFormat 确定导出格式
+// 优先使用显式指定的 format，否则根据文件扩展名推断，默认 png
+// 返回值为 "png"、"jpeg"、"webp" 或 "gif"
+func resolveExportFormat(format string, filePath string) string {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "png":
+		return "png"
+	case "jpeg", "jpg":
+		return "jpeg"
+	case "webp":
+		return "webp"
+	case "gif":
+		return "gif"
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".webp":
+		return "webp"
+	case ".gif":
+		return "gif"
+	default:
+		return "png"
+	}
+}
+
+// detectImageFormat 根据文件内容识别图像格式
+// 返回值为 "png"、"jpeg"、"webp"、"gif"，无法识别时返回空字符串
+func detectImageFormat(data []byte) string {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return "png"
+	case "image/jpeg":
+		return "jpeg"
+	case "image/webp":
+		return "webp"
+	case "image/gif":
+		return "gif"
+	default:
+		return ""
+	}
+}
+
+// normalizeExportQuality 规范化导出质量
+// quality <= 0 时使用默认质量 90，超过 100 时按 100 处理
+func normalizeExportQuality(quality int) int {
+	if quality <= 0 {
+		return defaultExportQuality
+	}
+	if quality > 100 {
+		return 100
+	}
+	return quality
+}
+
+// convertImageFormat 将图像数据转换为目标格式，透明区域使用默认白色背景填充
+// 源格式与目标格式一致时直接返回原始数据（不重新编码）
+// quality 仅对有损格式（jpeg）生效，png 忽略该参数
+func convertImageFormat(data []byte, targetFormat string, quality int) ([]byte, error) {
+	return convertImageFormatWithBackground(data, targetFormat, quality, defaultExportBackground)
+}
+
+// convertImageFormatWithBackground 将图像数据转换为目标格式
+// 含透明像素的图像转换为不支持透明通道的格式（jpeg）时合成到 background 上，避免透明区域变成黑色
+// 动画图像不会被压平为单帧，转换为其他格式时返回包装了 ErrAnimatedImage 的错误
+func convertImageFormatWithBackground(data []byte, targetFormat string, quality int, background color.NRGBA) ([]byte, error) {
+	sourceFormat := detectImageFormat(data)
+	if sourceFormat == targetFormat {
+		return data, nil
+	}
+	if frames := imageFrameCount(data); frames > 1 {
+		return nil, animatedImageError(sourceFormat, frames)
+	}
+	if targetFormat == "gif" {
+		return nil, fmt.Errorf("converting %s to gif is not supported, please export as png or jpeg", sourceFormatName(sourceFormat))
+	}
+
+	if targetFormat == "webp" {
+		return nil, fmt.Errorf("converting %s to webp is not supported, please export as png or jpeg", sourceFormatName(sourceFormat))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s image: %w", sourceFormatName(sourceFormat), err)
+	}
+
+	var buf bytes.Buffer
+	switch targetFormat {
+	case "jpeg":
+		if hasTransparency(img) {
+			img = flattenOnto(img, background)
+		}
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: normalizeExportQuality(quality)}); err != nil {
+			return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+		}
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode png: %w", err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// sourceFormatName 返回用于错误信息的格式名称
+func sourceFormatName(format string) string {
+	if format == "" {
+		return "unknown format"
+	}
+	return format
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 远程图像下载配置
+const (
+	// maxConcurrentImageFetches 同时进行的图像下载上限，超出的下载排队等待
+	maxConcurrentImageFetches = 4
+	// imageFetchHostInterval 同一主机相邻两次下载的最小间隔
+	imageFetchHostInterval = 200 * time.Millisecond
+	// imageFetchTimeout 单次下载超时（包含读取响应体）
+	imageFetchTimeout = 2 * time.Minute
+	// maxFetchedImageSize 单张远程图像的大小上限，超出时放弃下载
+	maxFetchedImageSize = 100 << 20
+)
+
+// sharedImageFetcher 进程内唯一的远程图像下载器，所有 ImageStorage 共用，
+// 使并发数和按主机的频率限制对全部下载生效
+var sharedImageFetcher = newImageFetcher()
+
+// imageFetcher 远程图像下载器
+// 所有下载共享同一个 http.Client（复用连接池），并通过信号量限制并发数、
+// 按主机限制请求频率，避免批量结果返回大量 URL 时压垮远端服务或耗尽本地连接
+type imageFetcher struct {
+	client   *http.Client
+	slots    chan struct{}
+	maxBytes int64 // 单张图像的大小上限
+
+	mu       sync.Mutex
+	nextSlot map[string]time.Time // 每个主机下一次允许开始下载的时间
+}
+
+// newImageFetcher 创建远程图像下载器
+func newImageFetcher() *imageFetcher {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          32,
+		MaxIdleConnsPerHost:   maxConcurrentImageFetches,
+		MaxConnsPerHost:       maxConcurrentImageFetches,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	return &imageFetcher{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   imageFetchTimeout,
+		},
+		slots:    make(chan struct{}, maxConcurrentImageFetches),
+		maxBytes: maxFetchedImageSize,
+		nextSlot: make(map[string]time.Time),
+	}
+}
+
+// fetch 下载图像，返回图像数据和响应声明的 Content-Type
+// ctx 被取消时停止排队或中断下载；响应超过大小上限时返回错误
+func (f *imageFetcher) fetch(ctx context.Context, imageURL string) ([]byte, string, error) {
+	parsed, err := url.Parse(imageURL)
+	if err != nil || parsed.Host == "" {
+		return nil, "", fmt.Errorf("invalid image url: %s", imageURL)
+	}
+
+	select {
+	case f.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, "", fmt.Errorf("image download cancelled: %w", ctx.Err())
+	}
+	defer func() { <-f.slots }()
+
+	if err := f.waitForHost(ctx, strings.ToLower(parsed.Host)); err != nil {
+		return nil, "", fmt.Errorf("image download cancelled: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create image request: %w", err)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch image url: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("failed to fetch image url: status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > f.maxBytes {
+		return nil, "", fmt.Errorf("remote image too large: %d bytes (limit %d bytes)", resp.ContentLength, f.maxBytes)
+	}
+
+	// 多读取一个字节用于判断是否超出上限
+	imageData, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image body: %w", err)
+	}
+	if int64(len(imageData)) > f.maxBytes {
+		return nil, "", fmt.Errorf("remote image too large (limit %d bytes)", f.maxBytes)
+	}
+
+	return imageData, resp.Header.Get("Content-Type"), nil
+}
+
+// waitForHost 按主机限流：预约该主机的下一个下载时间并等待到该时间
+// ctx 被取消时提前返回 ctx 的错误
+func (f *imageFetcher) waitForHost(ctx context.Context, host string) error {
+	f.mu.Lock()
+	now := time.Now()
+	slot := f.nextSlot[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	f.nextSlot[host] = slot.Add(imageFetchHostInterval)
+
+	// 清理已过期的主机记录，避免长时间运行后无限增长
+	if len(f.nextSlot) > 64 {
+		for h, next := range f.nextSlot {
+			if next.Before(now) {
+				delete(f.nextSlot, h)
+			}
+		}
+	}
+	f.mu.Unlock()
+
+	wait := time.Until(slot)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImageFetcherLimitsSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 分块传输，不声明 Content-Length，只能在读取时截断
+		w.Header().Set("Content-Type", "image/png")
+		flusher := w.(http.Flusher)
+		for i := 0; i < 4; i++ {
+			w.Write([]byte(strings.Repeat("x", 16)))
+			flusher.Flush()
+		}
+	}))
+	defer server.Close()
+
+	fetcher := newImageFetcher()
+	fetcher.maxBytes = 32
+	if _, _, err := fetcher.fetch(context.Background(), server.URL+"/large.png"); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Fatalf("expected a size limit error, got %v", err)
+	}
+
+	fetcher.maxBytes = 64
+	data, mimeType, err := fetcher.fetch(context.Background(), server.URL+"/exact.png")
+	if err != nil {
+		t.Fatalf("fetch within the limit: %v", err)
+	}
+	if len(data) != 64 || mimeType != "image/png" {
+		t.Fatalf("unexpected result: %d bytes, %q", len(data), mimeType)
+	}
+}
+
+func TestImageFetcherHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, _, err := newImageFetcher().fetch(ctx, server.URL+"/slow.png")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the download to stop with the context, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("cancelled download took %v", elapsed)
+	}
+}
@@ -0,0 +1,365 @@
+package service
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"unicode/utf16"
+)
+
+const (
+	// pngMetadataKeyword 存放完整元数据 JSON 的 PNG iTXt 关键字
+	pngMetadataKeyword = "artifex"
+	// pngDescriptionKeyword 存放提示词的 PNG iTXt 关键字，便于其他看图软件展示
+	pngDescriptionKeyword = "Description"
+
+	// exifUserCommentTag EXIF UserComment 标签
+	exifUserCommentTag = 0x9286
+	// exifIFDPointerTag IFD0 中指向 Exif IFD 的标签
+	exifIFDPointerTag = 0x8769
+)
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// embedImageMetadata 将生成元数据写入图像
+// PNG 写入 iTXt 块，JPEG 写入 EXIF UserComment，其他格式原样返回
+func embedImageMetadata(data []byte, format string, metadata types.ImageMetadata) ([]byte, error) {
+	payload, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize image metadata: %w", err)
+	}
+
+	switch format {
+	case "png":
+		return embedPNGMetadata(data, payload, metadata.Prompt)
+	case "jpeg":
+		return embedJPEGMetadata(data, payload)
+	default:
+		fmt.Printf("[FileService] Warning: metadata embedding is not supported for %s, exporting without metadata\n", format)
+		return data, nil
+	}
+}
+
+// ReadImageMetadata 读取导出图像中嵌入的生成元数据
+// 返回 JSON 格式的 ImageMetadata；图像中没有元数据时返回空字符串
+func (f *FileService) ReadImageMetadata(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	var payload []byte
+	switch detectImageFormat(data) {
+	case "png":
+		payload, err = readPNGMetadata(data)
+	case "jpeg":
+		payload, err = readJPEGMetadata(data)
+	default:
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if payload == nil {
+		return "", nil
+	}
+
+	var metadata types.ImageMetadata
+	if err := json.Unmarshal(payload, &metadata); err != nil {
+		return "", fmt.Errorf("invalid image metadata: %w", err)
+	}
+
+	result, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize image metadata: %w", err)
+	}
+	return string(result), nil
+}
+
+// ==================== PNG ====================
+
+// embedPNGMetadata 在 IHDR 之后插入 iTXt 块
+func embedPNGMetadata(data []byte, payload []byte, prompt string) ([]byte, error) {
+	// 签名(8) + IHDR 块(4 长度 + 4 类型 + 13 数据 + 4 CRC)
+	const ihdrEnd = 8 + 25
+	if len(data) < ihdrEnd || !bytes.Equal(data[:8], pngSignature) || string(data[12:16]) != "IHDR" {
+		return nil, fmt.Errorf("invalid png data")
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:ihdrEnd])
+	writePNGTextChunk(&buf, pngMetadataKeyword, payload)
+	if prompt != "" {
+		writePNGTextChunk(&buf, pngDescriptionKeyword, []byte(prompt))
+	}
+	buf.Write(data[ihdrEnd:])
+	return buf.Bytes(), nil
+}
+
+// writePNGTextChunk 写入不压缩的 iTXt 块（UTF-8 文本）
+func writePNGTextChunk(buf *bytes.Buffer, keyword string, text []byte) {
+	var chunk bytes.Buffer
+	chunk.WriteString(keyword)
+	chunk.WriteByte(0) // 关键字结束
+	chunk.WriteByte(0) // 压缩标志：不压缩
+	chunk.WriteByte(0) // 压缩方法
+	chunk.WriteByte(0) // 语言标签（空）
+	chunk.WriteByte(0) // 翻译后的关键字（空）
+	chunk.Write(text)
+
+	body := append([]byte("iTXt"), chunk.Bytes()...)
+	binary.Write(buf, binary.BigEndian, uint32(chunk.Len()))
+	buf.Write(body)
+	binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(body))
+}
+
+// readPNGMetadata 从 PNG 的 iTXt/tEXt 块中读取元数据 JSON
+func readPNGMetadata(data []byte) ([]byte, error) {
+	offset := len(pngSignature)
+	for offset+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[offset : offset+4]))
+		chunkType := string(data[offset+4 : offset+8])
+		start := offset + 8
+		end := start + length
+		if length < 0 || end+4 > len(data) {
+			return nil, fmt.Errorf("truncated png chunk %s", chunkType)
+		}
+		chunk := data[start:end]
+
+		switch chunkType {
+		case "iTXt":
+			if text, ok, err := parsePNGITXt(chunk); err != nil {
+				return nil, err
+			} else if ok {
+				return text, nil
+			}
+		case "tEXt":
+			if keyword, text, found := bytes.Cut(chunk, []byte{0}); found && string(keyword) == pngMetadataKeyword {
+				return text, nil
+			}
+		case "IDAT", "IEND":
+			// 元数据写在图像数据之前，遇到图像数据即可停止
+			return nil, nil
+		}
+
+		offset = end + 4
+	}
+	return nil, nil
+}
+
+// parsePNGITXt 解析 iTXt 块，仅返回 artifex 关键字的内容
+func parsePNGITXt(chunk []byte) ([]byte, bool, error) {
+	keyword, rest, found := bytes.Cut(chunk, []byte{0})
+	if !found || string(keyword) != pngMetadataKeyword || len(rest) < 2 {
+		return nil, false, nil
+	}
+
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	// 跳过语言标签和翻译后的关键字
+	for i := 0; i < 2; i++ {
+		_, rest, found = bytes.Cut(rest, []byte{0})
+		if !found {
+			return nil, false, fmt.Errorf("invalid png iTXt chunk")
+		}
+	}
+
+	if !compressed {
+		return rest, true, nil
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decompress png iTXt chunk: %w", err)
+	}
+	defer reader.Close()
+	text, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to decompress png iTXt chunk: %w", err)
+	}
+	return text, true, nil
+}
+
+// ==================== JPEG ====================
+
+// embedJPEGMetadata 在 SOI（及可选的 JFIF APP0）之后插入包含 UserComment 的 EXIF APP1 段
+func embedJPEGMetadata(data []byte, payload []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, fmt.Errorf("invalid jpeg data")
+	}
+
+	segment, err := buildExifUserCommentSegment(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	insertAt := 2
+	if data[2] == 0xFF && data[3] == 0xE0 && len(data) >= 6 {
+		insertAt = 4 + int(binary.BigEndian.Uint16(data[4:6]))
+		if insertAt > len(data) {
+			return nil, fmt.Errorf("invalid jpeg APP0 segment")
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data[:insertAt])
+	buf.Write(segment)
+	buf.Write(data[insertAt:])
+	return buf.Bytes(), nil
+}
+
+// buildExifUserCommentSegment 构建只包含 UserComment 的最小 EXIF APP1 段（大端字节序）
+func buildExifUserCommentSegment(payload []byte) ([]byte, error) {
+	// UserComment = 8 字节编码标识 + UTF-16 文本
+	comment := []byte("UNICODE\x00")
+	for _, unit := range utf16.Encode([]rune(string(payload))) {
+		comment = binary.BigEndian.AppendUint16(comment, unit)
+	}
+
+	const (
+		ifd0Offset    = 8
+		exifIFDOffset = ifd0Offset + 2 + 12 + 4
+		commentOffset = exifIFDOffset + 2 + 12 + 4
+	)
+
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(ifd0Offset))
+
+	// IFD0：仅包含 Exif IFD 指针
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	writeIFDEntry(&tiff, exifIFDPointerTag, 4, 1, exifIFDOffset)
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	// Exif IFD：仅包含 UserComment
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	writeIFDEntry(&tiff, exifUserCommentTag, 7, uint32(len(comment)), commentOffset)
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	tiff.Write(comment)
+
+	segmentLength := 2 + 6 + tiff.Len()
+	if segmentLength > 0xFFFF {
+		return nil, fmt.Errorf("image metadata too large to embed in jpeg")
+	}
+
+	var segment bytes.Buffer
+	segment.Write([]byte{0xFF, 0xE1})
+	binary.Write(&segment, binary.BigEndian, uint16(segmentLength))
+	segment.WriteString("Exif\x00\x00")
+	segment.Write(tiff.Bytes())
+	return segment.Bytes(), nil
+}
+
+// writeIFDEntry 写入一个 12 字节的 IFD 条目
+func writeIFDEntry(buf *bytes.Buffer, tag uint16, fieldType uint16, count uint32, value uint32) {
+	binary.Write(buf, binary.BigEndian, tag)
+	binary.Write(buf, binary.BigEndian, fieldType)
+	binary.Write(buf, binary.BigEndian, count)
+	binary.Write(buf, binary.BigEndian, value)
+}
+
+// readJPEGMetadata 从 JPEG 的 EXIF UserComment 中读取元数据 JSON
+func readJPEGMetadata(data []byte) ([]byte, error) {
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return nil, nil
+		}
+		marker := data[offset+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// 图像数据开始，元数据段只会出现在此之前
+			return nil, nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, fmt.Errorf("truncated jpeg segment")
+		}
+
+		segment := data[offset+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			if comment := readExifUserComment(segment[6:]); comment != nil {
+				return comment, nil
+			}
+		}
+		offset = end
+	}
+	return nil, nil
+}
+
+// readExifUserComment 在 TIFF 结构中查找 UserComment 并解码为 UTF-8
+func readExifUserComment(tiff []byte) []byte {
+	if len(tiff) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return nil
+	}
+
+	exifOffset, ok := findIFDEntry(tiff, order, order.Uint32(tiff[4:8]), exifIFDPointerTag)
+	if !ok {
+		return nil
+	}
+	exifIFD := order.Uint32(tiff[exifOffset+8 : exifOffset+12])
+
+	commentEntry, ok := findIFDEntry(tiff, order, exifIFD, exifUserCommentTag)
+	if !ok {
+		return nil
+	}
+	count := order.Uint32(tiff[commentEntry+4 : commentEntry+8])
+	start := order.Uint32(tiff[commentEntry+8 : commentEntry+12])
+	if count <= 4 {
+		start = commentEntry + 8
+	}
+	if count < 8 || uint64(start)+uint64(count) > uint64(len(tiff)) {
+		return nil
+	}
+
+	comment := tiff[start : start+count]
+	encoding, text := string(comment[:8]), comment[8:]
+	switch encoding {
+	case "UNICODE\x00":
+		units := make([]uint16, 0, len(text)/2)
+		for i := 0; i+1 < len(text); i += 2 {
+			units = append(units, order.Uint16(text[i:i+2]))
+		}
+		return []byte(string(utf16.Decode(units)))
+	case "ASCII\x00\x00\x00":
+		return bytes.TrimRight(text, "\x00")
+	default:
+		return nil
+	}
+}
+
+// findIFDEntry 在指定偏移的 IFD 中查找标签，返回条目的偏移
+func findIFDEntry(tiff []byte, order binary.ByteOrder, ifdOffset uint32, tag uint16) (uint32, bool) {
+	if uint64(ifdOffset)+2 > uint64(len(tiff)) {
+		return 0, false
+	}
+	count := uint32(order.Uint16(tiff[ifdOffset : ifdOffset+2]))
+	for i := uint32(0); i < count; i++ {
+		entry := ifdOffset + 2 + i*12
+		if uint64(entry)+12 > uint64(len(tiff)) {
+			return 0, false
+		}
+		if order.Uint16(tiff[entry:entry+2]) == tag {
+			return entry, true
+		}
+	}
+	return 0, false
+}
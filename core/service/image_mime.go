@@ -0,0 +1,65 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SetAllowedMimeTypes 设置允许保存的图像 MIME 类型（如 "image/png"、"image/jpeg"、"image/webp"）
+// 传入 nil 或空列表时允许所有类型（默认行为）；"image/jpg" 视为 "image/jpeg"
+func (s *ImageStorage) SetAllowedMimeTypes(mimeTypes []string) {
+	if len(mimeTypes) == 0 {
+		s.allowedMimes.Store(map[string]bool(nil))
+		return
+	}
+	allowed := make(map[string]bool, len(mimeTypes))
+	for _, mimeType := range mimeTypes {
+		if normalized := normalizeImageMime(mimeType); normalized != "" {
+			allowed[normalized] = true
+		}
+	}
+	s.allowedMimes.Store(allowed)
+}
+
+// applyAllowedMimeTypes 从设置中读取 allowedImageMimeTypes 并应用到图片存储（内部函数）
+// 各服务持有独立的 ImageStorage，HistoryService、FileService 在启动和设置变更时调用，
+// 使存储历史记录图像和导入图像时同样受允许列表限制；读取设置失败时保持当前限制
+func applyAllowedMimeTypes(configService *ConfigService, storage *ImageStorage) error {
+	if configService == nil || storage == nil {
+		return nil
+	}
+	aiSettings, err := configService.loadAISettings()
+	if err != nil {
+		return err
+	}
+	storage.SetAllowedMimeTypes(aiSettings.AllowedImageMimeTypes)
+	return nil
+}
+
+// checkMimeAllowed 检查图像类型是否在允许列表中（内部方法）
+// 优先使用按内容识别的类型，无法识别时使用声明的类型，避免通过伪造 MIME 绕过限制
+func (s *ImageStorage) checkMimeAllowed(imageData []byte, mimeType string) error {
+	allowed, _ := s.allowedMimes.Load().(map[string]bool)
+	if allowed == nil {
+		return nil
+	}
+
+	actual := normalizeImageMime(http.DetectContentType(imageData))
+	if !strings.HasPrefix(actual, "image/") {
+		actual = normalizeImageMime(mimeType)
+	}
+	if !allowed[actual] {
+		return fmt.Errorf("image type %q is not allowed", actual)
+	}
+	return nil
+}
+
+// normalizeImageMime 去除 MIME 参数并转为小写，"image/jpg" 统一为 "image/jpeg"
+func normalizeImageMime(mimeType string) string {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.Split(mimeType, ";")[0]))
+	if mimeType == "image/jpg" {
+		return "image/jpeg"
+	}
+	return mimeType
+}
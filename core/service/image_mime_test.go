@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"artifex/core/types"
+)
+
+// newTestConfigService 在临时数据目录中创建配置服务并保存 configure 修改后的默认设置
+// 不绑定 Wails 上下文，保存设置时不发送变更事件
+func newTestConfigService(t *testing.T, configure func(*types.Settings)) *ConfigService {
+	t.Helper()
+
+	t.Setenv(DataDirEnv, t.TempDir())
+	c := NewConfigService()
+	if err := c.Startup(context.Background()); err != nil {
+		t.Fatalf("start config service: %v", err)
+	}
+	c.ctx = nil
+
+	settings := defaultSettings()
+	if configure != nil {
+		configure(&settings)
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		t.Fatalf("marshal settings: %v", err)
+	}
+	if err := c.SaveSettings(string(data)); err != nil {
+		t.Fatalf("save settings: %v", err)
+	}
+	return c
+}
+
+// testJPEG 返回一张小尺寸 JPEG 图像
+func testJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func onlyPNGAllowed(settings *types.Settings) {
+	settings.AI.AllowedImageMimeTypes = []string{"image/png"}
+}
+
+func TestHistoryStoreImageRespectsAllowedMimeTypes(t *testing.T) {
+	configService := newTestConfigService(t, onlyPNGAllowed)
+	h := newTestHistoryService(t)
+	h.configService = configService
+	if err := applyAllowedMimeTypes(h.configService, h.imageStorage); err != nil {
+		t.Fatalf("applyAllowedMimeTypes: %v", err)
+	}
+
+	dataURL := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(testJPEG(t))
+	if _, err := h.StoreImage(dataURL); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected StoreImage to reject a JPEG, got %v", err)
+	}
+}
+
+func TestFileImportRespectsAllowedMimeTypes(t *testing.T) {
+	configService := newTestConfigService(t, onlyPNGAllowed)
+	f := NewFileService(configService)
+
+	path := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(path, testJPEG(t), 0644); err != nil {
+		t.Fatalf("write jpeg: %v", err)
+	}
+	if _, err := f.importImageFile(path); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Fatalf("expected import to reject a JPEG, got %v", err)
+	}
+
+	// 修改设置后重新应用，允许 JPEG 导入
+	configService = newTestConfigService(t, nil)
+	f.configService = configService
+	if err := applyAllowedMimeTypes(f.configService, f.imageStorage); err != nil {
+		t.Fatalf("applyAllowedMimeTypes: %v", err)
+	}
+	if _, err := f.importImageFile(path); err != nil {
+		t.Fatalf("expected import to succeed without an allow-list, got %v", err)
+	}
+}
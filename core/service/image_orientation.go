@@ -0,0 +1,131 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+)
+
+// exifOrientationTag IFD0 中的 Orientation 标签
+const exifOrientationTag = 0x0112
+
+// normalizeJPEGOrientation 按 EXIF Orientation 旋转/翻转 JPEG 像素并重新编码，返回正向的图像数据
+// 手机拍摄的照片通常只写入方向标记而不旋转像素，重新编码后不再包含 EXIF，各处无需再处理方向；
+// 非 JPEG、没有方向标记或方向为正常（1）时原样返回。重新编码使用 StoredImageQuality 质量
+func (s *ImageStorage) normalizeJPEGOrientation(imageData []byte) []byte {
+	if detectImageFormat(imageData) != "jpeg" {
+		return imageData
+	}
+	orientation := jpegExifOrientation(imageData)
+	if orientation <= 1 || orientation > 8 {
+		return imageData
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		fmt.Printf("[ImageStorage] Warning: cannot apply jpeg orientation %d: %v\n", orientation, err)
+		return imageData
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, applyOrientation(img, orientation), &jpeg.Options{Quality: s.jpegSaveQuality()}); err != nil {
+		fmt.Printf("[ImageStorage] Warning: failed to encode oriented jpeg: %v\n", err)
+		return imageData
+	}
+	return buf.Bytes()
+}
+
+// jpegExifOrientation 读取 JPEG EXIF 中的 Orientation 值，不存在或无法解析时返回 1（正常）
+func jpegExifOrientation(data []byte) int {
+	offset := 2
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+		marker := data[offset+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// 图像数据开始，EXIF 段只会出现在此之前
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(data[offset+2 : offset+4]))
+		end := offset + 2 + length
+		if length < 2 || end > len(data) {
+			return 1
+		}
+
+		segment := data[offset+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return readExifOrientation(segment[6:])
+		}
+		offset = end
+	}
+	return 1
+}
+
+// readExifOrientation 在 TIFF 结构的 IFD0 中查找 Orientation（SHORT 类型，值内联在条目中）
+func readExifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "MM":
+		order = binary.BigEndian
+	case "II":
+		order = binary.LittleEndian
+	default:
+		return 1
+	}
+
+	entry, ok := findIFDEntry(tiff, order, order.Uint32(tiff[4:8]), exifOrientationTag)
+	if !ok {
+		return 1
+	}
+	return int(order.Uint16(tiff[entry+8 : entry+10]))
+}
+
+// applyOrientation 按 EXIF Orientation（2-8）变换图像，返回正向显示的新图像
+// 5-8 会交换宽高
+func applyOrientation(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(src, src.Bounds(), img, bounds.Min, draw.Src)
+
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dstW, dstH := w, h
+	if orientation >= 5 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90°
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90°
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			copy(dst.Pix[dst.PixOffset(dx, dy):dst.PixOffset(dx, dy)+4], src.Pix[src.PixOffset(x, y):src.PixOffset(x, y)+4])
+		}
+	}
+	return dst
+}
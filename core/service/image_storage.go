@@ -1,21 +1,28 @@
 package service
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type ImageStorage struct {
 	imagesDir string
 	mu        sync.RWMutex // 保护文件操作
+
+	storedFormat atomic.Value // 保存时统一转换的格式（string，为空时按原始格式保存）
+	allowedMimes atomic.Value // 允许保存的 MIME 类型（map[string]bool，nil 表示不限制）
+	saveQuality  atomic.Int32 // 有损重新编码的质量（<= 0 时使用 defaultSaveQuality）
 }
 
 func NewImageStorage(dataDir string) *ImageStorage {
@@ -24,6 +31,15 @@ func NewImageStorage(dataDir string) *ImageStorage {
 	}
 }
 
+// ResolveImagesDir 获取图片存储目录，与 ImageStorage 使用的路径一致
+func ResolveImagesDir() (string, error) {
+	dataDir, err := ResolveDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dataDir, "images"), nil
+}
+
 func (s *ImageStorage) Initialize() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -36,9 +52,10 @@ func (s *ImageStorage) Initialize() error {
 }
 
 func extractBase64Data(dataURL string) string {
-	parts := strings.Split(dataURL, ",")
-	if len(parts) == 2 {
-		return parts[1]
+	if strings.HasPrefix(dataURL, "data:") {
+		if _, payload, ok := strings.Cut(dataURL, ","); ok {
+			return payload
+		}
 	}
 	return dataURL
 }
@@ -48,12 +65,10 @@ func extractMimeType(dataURL string) string {
 		return "image/png" // 默认类型
 	}
 
-	parts := strings.Split(dataURL, ";")
-	if len(parts) > 0 {
-		mimeType := strings.TrimPrefix(parts[0], "data:")
-		if mimeType != "" {
-			return mimeType
-		}
+	header, _, _ := strings.Cut(strings.TrimPrefix(dataURL, "data:"), ",")
+	mimeType, _, _ := strings.Cut(header, ";")
+	if mimeType = strings.TrimSpace(mimeType); mimeType != "" {
+		return mimeType
 	}
 
 	return "image/png" // 默认类型
@@ -76,6 +91,8 @@ func getFileExtension(mimeType string) string {
 }
 
 // saveImageBytes stores raw bytes and returns an image ref.
+// 带 EXIF 方向标记的 JPEG 先旋转为正向（见 normalizeJPEGOrientation）；
+// 设置了存储格式（SetStoredFormat）时先转换格式，再按转换后的内容计算哈希和扩展名
 func (s *ImageStorage) saveImageBytes(imageData []byte, mimeType string) (string, error) {
 	if len(imageData) == 0 {
 		return "", fmt.Errorf("empty image data")
@@ -84,49 +101,81 @@ func (s *ImageStorage) saveImageBytes(imageData []byte, mimeType string) (string
 	if mimeType == "" {
 		mimeType = http.DetectContentType(imageData)
 	}
+	imageData = s.normalizeJPEGOrientation(imageData)
+	imageData, mimeType = s.transcodeForStorage(imageData, mimeType)
+	return s.writeImageBytes(imageData, mimeType)
+}
+
+// writeImageBytes 按原始格式存储图像数据并返回 image ref（不做格式转换）
+// 设置了允许的 MIME 类型（SetAllowedMimeTypes）时，不在列表中的类型返回错误
+// 较大的图像写入前检查剩余空间，不足时返回 ErrInsufficientSpace
+func (s *ImageStorage) writeImageBytes(imageData []byte, mimeType string) (string, error) {
+	if len(imageData) == 0 {
+		return "", fmt.Errorf("empty image data")
+	}
+
+	if mimeType == "" {
+		mimeType = http.DetectContentType(imageData)
+	}
+	if err := s.checkMimeAllowed(imageData, mimeType); err != nil {
+		return "", err
+	}
 
 	hash := sha256.Sum256(imageData)
 	hashHex := hex.EncodeToString(hash[:])
 
 	ext := getFileExtension(mimeType)
 
+	// 按哈希前两位分片存储（images/ab/abcd….png），避免单个目录文件过多
 	fileName := hashHex + ext
-	filePath := filepath.Join(s.imagesDir, fileName)
+	shardedName := path.Join(hashHex[:2], fileName)
+	filePath := filepath.Join(s.imagesDir, filepath.FromSlash(shardedName))
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// 已存在相同内容的文件时刷新修改时间，避免进行中的清理把这次重新引用的文件当作旧文件删除
+	now := time.Now()
 	if _, err := os.Stat(filePath); err == nil {
+		os.Chtimes(filePath, now, now)
+		recordImageSave(len(imageData), true)
+		return s.getImageRef(shardedName), nil
+	}
+	// 兼容旧版平铺存储的文件
+	flatPath := filepath.Join(s.imagesDir, fileName)
+	if _, err := os.Stat(flatPath); err == nil {
+		os.Chtimes(flatPath, now, now)
+		recordImageSave(len(imageData), true)
 		return s.getImageRef(fileName), nil
 	}
 
+	if len(imageData) >= largeImageSaveBytes {
+		if err := checkFreeSpace(s.imagesDir, int64(len(imageData))); err != nil {
+			return "", err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create image shard directory: %w", err)
+	}
 	if err := os.WriteFile(filePath, imageData, 0644); err != nil {
 		return "", fmt.Errorf("failed to write image file: %w", err)
 	}
+	recordImageSave(len(imageData), false)
 
-	return s.getImageRef(fileName), nil
+	return s.getImageRef(shardedName), nil
 }
 
 // SaveImage stores a data URL and returns an image ref.
+// data URL 的解析规则见 decodeImageDataURL
 func (s *ImageStorage) SaveImage(dataURL string) (string, error) {
 	if dataURL == "" {
 		return "", nil
 	}
 
-	// Extract base64 payload
-	base64Data := extractBase64Data(dataURL)
-	if base64Data == "" {
-		return "", fmt.Errorf("invalid image data URL")
-	}
-
-	// Decode base64
-	imageData, err := base64.StdEncoding.DecodeString(base64Data)
+	imageData, mimeType, err := decodeImageDataURL(dataURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to decode base64 image: %w", err)
+		return "", err
 	}
-
-	// Determine MIME type
-	mimeType := extractMimeType(dataURL)
 	return s.saveImageBytes(imageData, mimeType)
 }
 
@@ -141,7 +190,10 @@ func (s *ImageStorage) LoadImage(imageRef string) (string, error) {
 		return "", fmt.Errorf("invalid image reference: %s", imageRef)
 	}
 
-	filePath := filepath.Join(s.imagesDir, fileName)
+	filePath, err := s.GetImagePath(imageRef)
+	if err != nil {
+		return "", err
+	}
 
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -166,27 +218,18 @@ func (s *ImageStorage) LoadImage(imageRef string) (string, error) {
 }
 
 // SaveImageFromURL fetches an image by URL and stores it locally.
-func (s *ImageStorage) SaveImageFromURL(imageURL string) (string, error) {
+// 下载通过进程内共享的 imageFetcher 进行，并发数和同一主机的请求频率受限，超出时排队等待；
+// ctx 被取消时停止下载
+func (s *ImageStorage) SaveImageFromURL(ctx context.Context, imageURL string) (string, error) {
 	if imageURL == "" {
 		return "", nil
 	}
 
-	resp, err := http.Get(imageURL)
+	imageData, mimeType, err := sharedImageFetcher.fetch(ctx, imageURL)
 	if err != nil {
-		return "", fmt.Errorf("failed to fetch image url: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", fmt.Errorf("failed to fetch image url: status %d", resp.StatusCode)
+		return "", err
 	}
 
-	imageData, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read image body: %w", err)
-	}
-
-	mimeType := resp.Header.Get("Content-Type")
 	return s.saveImageBytes(imageData, mimeType)
 }
 
@@ -236,7 +279,7 @@ func (s *ImageStorage) LoadImages(imageRefs []string) ([]string, error) {
 }
 
 func (s *ImageStorage) getImageRef(fileName string) string {
-	return fmt.Sprintf("images/%s", fileName)
+	return fmt.Sprintf("images/%s", filepath.ToSlash(fileName))
 }
 
 // GetImagePath returns the absolute path for an image ref.
@@ -250,12 +293,24 @@ func (s *ImageStorage) GetImagePath(imageRef string) (string, error) {
 		return "", fmt.Errorf("invalid image reference: %s", imageRef)
 	}
 
-	cleaned := filepath.Clean(fileName)
-	if cleaned == "." || cleaned == ".." || cleaned != filepath.Base(cleaned) {
+	// 支持分片子目录（ab/abcd.png），但不允许越出 images 目录
+	cleaned := path.Clean(fileName)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") || path.IsAbs(cleaned) || strings.Contains(cleaned, "\\") {
 		return "", fmt.Errorf("invalid image reference: %s", imageRef)
 	}
 
-	return filepath.Join(s.imagesDir, cleaned), nil
+	filePath := filepath.Join(s.imagesDir, filepath.FromSlash(cleaned))
+	if cleaned != path.Base(cleaned) {
+		// 分片文件不存在时回退到旧版平铺路径
+		if _, err := os.Stat(filePath); err != nil {
+			flatPath := filepath.Join(s.imagesDir, path.Base(cleaned))
+			if _, flatErr := os.Stat(flatPath); flatErr == nil {
+				return flatPath, nil
+			}
+		}
+	}
+
+	return filePath, nil
 }
 
 
@@ -269,42 +324,186 @@ func (s *ImageStorage) parseImageRef(imageRef string) string {
 	return imageRef
 }
 
-func (s *ImageStorage) CleanupUnusedImages(usedRefs map[string]bool) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// defaultCleanupWorkers 清理未引用图片时并发删除文件的默认协程数
+const defaultCleanupWorkers = 4
+
+// CleanupResult 清理未引用图片的结果
+type CleanupResult struct {
+	DeletedCount   int   `json:"deletedCount"`   // 删除的图片文件数
+	ReclaimedBytes int64 `json:"reclaimedBytes"` // 释放的磁盘空间（字节）
+}
+
+// cleanupCandidate 待删除的未引用图片
+type cleanupCandidate struct {
+	filePath string
+	fileName string
+}
 
-	entries, err := os.ReadDir(s.imagesDir)
+func (s *ImageStorage) CleanupUnusedImages(usedRefs map[string]bool) (CleanupResult, error) {
+	return s.CleanupUnusedImagesOlderThan(usedRefs, 0, 0)
+}
+
+// CleanupUnusedImagesOlderThan 删除未被引用且修改时间早于 minAge 之前的图片
+// minAge 用于保护刚生成、尚未写入历史记录的图片
+// 先在读锁下收集待删除的文件，再由 workers 个协程（<= 0 时使用默认值 4）并发删除；
+// 每个文件删除前短暂获取写锁并重新检查修改时间，清理期间保存图片不会被长时间阻塞，
+// 也不会删除清理期间刚写入或被重新引用的文件
+func (s *ImageStorage) CleanupUnusedImagesOlderThan(usedRefs map[string]bool, minAge time.Duration, workers int) (CleanupResult, error) {
+	cutoff := time.Now().Add(-minAge)
+	if workers <= 0 {
+		workers = defaultCleanupWorkers
+	}
+
+	candidates, shardDirs, err := s.collectCleanupCandidates(usedRefs, minAge, cutoff)
 	if err != nil {
+		return CleanupResult{}, err
+	}
+
+	var result CleanupResult
+	var resultMu sync.Mutex
+	jobs := make(chan cleanupCandidate)
+	var wg sync.WaitGroup
+	for i := 0; i < workers && i < len(candidates); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for candidate := range jobs {
+				size, ok := s.removeCleanupCandidate(candidate, minAge, cutoff)
+				if !ok {
+					continue
+				}
+				resultMu.Lock()
+				result.DeletedCount++
+				result.ReclaimedBytes += size
+				resultMu.Unlock()
+			}
+		}()
+	}
+	for _, candidate := range candidates {
+		jobs <- candidate
+	}
+	close(jobs)
+	wg.Wait()
+
+	// 删除清理后变空的分片目录（非空目录删除会失败，直接忽略）
+	s.mu.Lock()
+	for _, dir := range shardDirs {
+		os.Remove(dir)
+	}
+	s.mu.Unlock()
+
+	if result.DeletedCount > 0 {
+		fmt.Printf("[ImageStorage] Cleaned up %d unused image files (%s)\n", result.DeletedCount, formatByteSize(result.ReclaimedBytes))
+	}
+
+	return result, nil
+}
+
+// collectCleanupCandidates 在读锁下遍历图片目录，收集未被引用且足够旧的图片（内部方法）
+func (s *ImageStorage) collectCleanupCandidates(usedRefs map[string]bool, minAge time.Duration, cutoff time.Time) ([]cleanupCandidate, []string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, err := os.Stat(s.imagesDir); err != nil {
 		if os.IsNotExist(err) {
-			return nil // 目录不存在，无需清理
+			return nil, nil, nil // 目录不存在，无需清理
 		}
-		return fmt.Errorf("failed to read images directory: %w", err)
+		return nil, nil, fmt.Errorf("failed to read images directory: %w", err)
 	}
 
-	deletedCount := 0
-	for _, entry := range entries {
+	var candidates []cleanupCandidate
+	var shardDirs []string
+	// 同时遍历平铺文件和分片子目录，缩略图缓存目录和回收站不参与清理
+	err := filepath.WalkDir(s.imagesDir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
 		if entry.IsDir() {
-			continue
+			if filePath == s.imagesDir {
+				return nil
+			}
+			if entry.Name() == thumbnailDirName || entry.Name() == trashDirName {
+				return filepath.SkipDir
+			}
+			shardDirs = append(shardDirs, filePath)
+			return nil
 		}
 
-		fileName := entry.Name()
+		fileName, err := filepath.Rel(s.imagesDir, filePath)
+		if err != nil {
+			return nil
+		}
 		ref := s.getImageRef(fileName)
+		used := usedRefs[ref]
+		// 平铺文件也可能通过分片 ref 回退访问
+		if !used && fileName == entry.Name() && len(fileName) > 2 {
+			used = usedRefs[s.getImageRef(path.Join(fileName[:2], fileName))]
+		}
 
-		if !usedRefs[ref] {
-			filePath := filepath.Join(s.imagesDir, fileName)
-			if err := os.Remove(filePath); err != nil {
-				fmt.Printf("[ImageStorage] Warning: failed to delete unused image %s: %v\n", fileName, err)
-				continue
+		if !used && minAge > 0 {
+			if info, err := entry.Info(); err != nil || info.ModTime().After(cutoff) {
+				used = true
 			}
-			deletedCount++
 		}
+
+		if !used {
+			candidates = append(candidates, cleanupCandidate{filePath: filePath, fileName: fileName})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read images directory: %w", err)
+	}
+	return candidates, shardDirs, nil
+}
+
+// removeCleanupCandidate 删除一个待清理的图片，返回释放的字节数（内部方法）
+// 在写锁下重新检查修改时间：收集之后被重新保存（writeImageBytes 会刷新已存在文件的修改时间）的文件不删除
+func (s *ImageStorage) removeCleanupCandidate(candidate cleanupCandidate, minAge time.Duration, cutoff time.Time) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(candidate.filePath)
+	if err != nil {
+		return 0, false
+	}
+	if minAge > 0 && info.ModTime().After(cutoff) {
+		return 0, false
+	}
+	if err := os.Remove(candidate.filePath); err != nil {
+		fmt.Printf("[ImageStorage] Warning: failed to delete unused image %s: %v\n", candidate.fileName, err)
+		return 0, false
 	}
+	return info.Size(), true
+}
 
-	if deletedCount > 0 {
-		fmt.Printf("[ImageStorage] Cleaned up %d unused image files\n", deletedCount)
+// DeleteImages 立即删除指定的图片（不经过回收站），返回删除的文件数和释放的字节数
+// 用于清除历史记录时删除只被该历史引用的图片；不存在的图片直接跳过，删除失败只打印警告
+func (s *ImageStorage) DeleteImages(imageRefs []string) CleanupResult {
+	var result CleanupResult
+	for _, imageRef := range imageRefs {
+		filePath, err := s.GetImagePath(imageRef)
+		if err != nil || filePath == "" {
+			continue
+		}
+
+		s.mu.Lock()
+		info, err := os.Stat(filePath)
+		if err == nil {
+			if err = os.Remove(filePath); err == nil {
+				result.DeletedCount++
+				result.ReclaimedBytes += info.Size()
+			} else {
+				fmt.Printf("[ImageStorage] Warning: failed to delete image %s: %v\n", imageRef, err)
+			}
+		}
+		s.mu.Unlock()
 	}
 
-	return nil
+	if result.DeletedCount > 0 {
+		fmt.Printf("[ImageStorage] Deleted %d images (%s)\n", result.DeletedCount, formatByteSize(result.ReclaimedBytes))
+	}
+	return result
 }
 
 func (s *ImageStorage) GetStorageSize() (int64, error) {
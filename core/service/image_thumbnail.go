@@ -0,0 +1,133 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultThumbnailSize 缩略图最长边（像素）
+const defaultThumbnailSize = 256
+
+// thumbnailDirName 缩略图缓存目录（位于 images 目录下）
+const thumbnailDirName = "thumbnails"
+
+// GetThumbnailPath 返回图像缩略图的绝对路径，首次请求时生成并缓存
+// 缩略图按比例缩放至最长边不超过 defaultThumbnailSize，统一保存为 PNG 以保留透明通道
+// 原图本身不超过缩略图尺寸时直接返回原图路径
+func (s *ImageStorage) GetThumbnailPath(imageRef string) (string, error) {
+	sourcePath, err := s.GetImagePath(imageRef)
+	if err != nil {
+		return "", err
+	}
+	if sourcePath == "" {
+		return "", fmt.Errorf("empty image reference")
+	}
+
+	fileName := filepath.Base(sourcePath)
+	thumbName := fmt.Sprintf("%s_%d.png", strings.TrimSuffix(fileName, filepath.Ext(fileName)), defaultThumbnailSize)
+	thumbPath := filepath.Join(s.imagesDir, thumbnailDirName, thumbName)
+
+	s.mu.RLock()
+	_, statErr := os.Stat(thumbPath)
+	s.mu.RUnlock()
+	if statErr == nil {
+		return thumbPath, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 等待锁期间可能已被其他请求生成
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, nil
+	}
+
+	data, err := os.ReadFile(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	width, height := thumbnailDimensions(bounds.Dx(), bounds.Dy(), defaultThumbnailSize)
+	if width == bounds.Dx() && height == bounds.Dy() {
+		return sourcePath, nil
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, resizeImage(img, width, height)); err != nil {
+		return "", fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create thumbnails directory: %w", err)
+	}
+
+	// 先写临时文件再原子替换，避免并发读取到不完整的缩略图
+	tmpPath := thumbPath + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := os.Rename(tmpPath, thumbPath); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to save thumbnail: %w", err)
+	}
+
+	return thumbPath, nil
+}
+
+// thumbnailDimensions 计算按比例缩放后的尺寸，最长边不超过 maxSize，不放大
+func thumbnailDimensions(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if width >= height {
+		return maxSize, max(1, height*maxSize/width)
+	}
+	return max(1, width*maxSize/height), maxSize
+}
+
+// resizeImage 使用区域平均（box filter）将图像缩小到指定尺寸
+func resizeImage(src image.Image, width, height int) *image.NRGBA {
+	bounds := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		sy0 := bounds.Min.Y + y*bounds.Dy()/height
+		sy1 := max(sy0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			sx0 := bounds.Min.X + x*bounds.Dx()/width
+			sx1 := max(sx0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			// 在预乘空间中求平均，避免透明像素的颜色渗入
+			var r, g, b, a, count uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			if a == 0 {
+				continue
+			}
+			dst.Pix[i] = uint8(r * 0xff / a)
+			dst.Pix[i+1] = uint8(g * 0xff / a)
+			dst.Pix[i+2] = uint8(b * 0xff / a)
+			dst.Pix[i+3] = uint8(a / count >> 8)
+		}
+	}
+
+	return dst
+}
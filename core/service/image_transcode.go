@@ -0,0 +1,93 @@
+package service
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"strings"
+)
+
+// defaultSaveQuality 保存图像时有损重新编码的默认质量
+const defaultSaveQuality = 90
+
+// SetStoredFormat 设置保存图像时统一转换的格式（types.StoredImageFormat*）
+// 为空时按原始格式保存；仅影响设置了该格式的 ImageStorage 实例
+func (s *ImageStorage) SetStoredFormat(format string) {
+	s.storedFormat.Store(format)
+}
+
+// SetSaveQuality 设置保存图像时有损重新编码（目前为 JPEG）使用的质量，<= 0 时使用默认值 90
+// PNG 为无损格式，不受影响
+func (s *ImageStorage) SetSaveQuality(quality int) {
+	if quality > 100 {
+		quality = 100
+	}
+	s.saveQuality.Store(int32(quality))
+}
+
+// jpegSaveQuality 返回当前的有损重新编码质量（内部方法）
+func (s *ImageStorage) jpegSaveQuality() int {
+	if quality := int(s.saveQuality.Load()); quality > 0 {
+		return quality
+	}
+	return defaultSaveQuality
+}
+
+// transcodeForStorage 按 storedFormat 转换图像格式，返回转换后的数据和 MIME 类型
+// 以下情况保持原样：未设置格式、已是目标格式、动画图像（多帧 GIF、动画 WebP，避免丢失动画）、
+// 无法解码的格式（如 WebP）、以及含透明像素的图像转 JPEG（避免丢失透明背景）
+func (s *ImageStorage) transcodeForStorage(imageData []byte, mimeType string) ([]byte, string) {
+	format, _ := s.storedFormat.Load().(string)
+	if format == "" {
+		return imageData, mimeType
+	}
+
+	targetMime := ""
+	switch format {
+	case types.StoredImageFormatPNG:
+		targetMime = "image/png"
+	case types.StoredImageFormatJPEG:
+		targetMime = "image/jpeg"
+	default:
+		fmt.Printf("[ImageStorage] Warning: unsupported stored image format %q, keeping original format\n", format)
+		return imageData, mimeType
+	}
+
+	sourceMime := strings.TrimSpace(strings.Split(mimeType, ";")[0])
+	if sourceMime == "" || sourceMime == "application/octet-stream" {
+		sourceMime = http.DetectContentType(imageData)
+	}
+	if sourceMime == "image/jpg" {
+		sourceMime = "image/jpeg"
+	}
+	if sourceMime == targetMime || imageFrameCount(imageData) > 1 {
+		return imageData, mimeType
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(imageData))
+	if err != nil {
+		fmt.Printf("[ImageStorage] Warning: cannot transcode %s image to %s: %v\n", sourceMime, format, err)
+		return imageData, mimeType
+	}
+
+	var buf bytes.Buffer
+	switch targetMime {
+	case "image/png":
+		err = png.Encode(&buf, img)
+	case "image/jpeg":
+		if hasTransparency(img) {
+			return imageData, mimeType
+		}
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: s.jpegSaveQuality()})
+	}
+	if err != nil {
+		fmt.Printf("[ImageStorage] Warning: failed to transcode image to %s: %v\n", format, err)
+		return imageData, mimeType
+	}
+
+	return buf.Bytes(), targetMime
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// storeTransparentResult 对背景移除结果做后处理并保存为 PNG（内部方法）
+// - 始终重新编码为 PNG，避免 JPEG 等格式丢失透明通道
+// - 结果不含透明像素时返回警告
+// - 指定了 fallback 且提供商无法输出透明图像（或结果不透明）时，合成到纯色背景上
+func (a *AIService) storeTransparentResult(ctx context.Context, imageData string, fallback *color.NRGBA, providerTransparent bool) (*types.RemoveBackgroundResult, error) {
+	data, err := a.readImagePayload(ctx, imageData)
+	if err != nil {
+		return nil, err
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		// 无法解码（如 WebP），按原样保存已读取的数据并提示，避免再次下载远程结果
+		ref := imageData
+		if a.imageStorage != nil {
+			var storeErr error
+			if ref, storeErr = a.imageStorage.writeImageBytes(data, ""); storeErr != nil {
+				return nil, storeErr
+			}
+		}
+		warning := fmt.Sprintf("unable to decode background removal result, saved without post-processing: %v", err)
+		fmt.Printf("[AIService] Warning: %s\n", warning)
+		return &types.RemoveBackgroundResult{Image: ref, Warning: warning}, nil
+	}
+
+	transparent := hasTransparency(img)
+	result := &types.RemoveBackgroundResult{Transparent: transparent}
+
+	switch {
+	case format == "jpeg":
+		result.Warning = "provider returned a JPEG image, which has no alpha channel; background was not made transparent"
+	case !transparent:
+		result.Warning = "provider returned an image without transparent pixels; background may not have been removed"
+	}
+
+	if fallback != nil && (!providerTransparent || !transparent) {
+		img = flattenOnto(img, *fallback)
+		result.Transparent = false
+		result.Warning = ""
+	}
+
+	if result.Warning != "" {
+		fmt.Printf("[AIService] Warning: %s\n", result.Warning)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	if a.imageStorage == nil {
+		result.Image = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+		return result, nil
+	}
+
+	// 背景移除结果始终保存为 PNG，不受存储格式设置影响
+	ref, err := a.imageStorage.writeImageBytes(buf.Bytes(), "image/png")
+	if err != nil {
+		return nil, err
+	}
+	result.Image = ref
+	return result, nil
+}
+
+// readImagePayload 读取提供商返回的图像原始字节（内部方法）
+// 支持 data URI、image ref、http(s) URL 和裸 base64
+func (a *AIService) readImagePayload(ctx context.Context, imageData string) ([]byte, error) {
+	if imageData == "" {
+		return nil, fmt.Errorf("empty image data")
+	}
+
+	if strings.HasPrefix(imageData, "images/") || strings.HasPrefix(imageData, "/images/") {
+		loaded, err := a.normalizeImageInput(imageData)
+		if err != nil {
+			return nil, err
+		}
+		imageData = loaded
+	}
+
+	if strings.HasPrefix(imageData, "http://") || strings.HasPrefix(imageData, "https://") {
+		// 与保存结果共用下载器，受并发、频率和大小上限约束
+		data, _, err := sharedImageFetcher.fetch(ctx, imageData)
+		return data, err
+	}
+
+	data, _, err := decodeImageDataURL(imageData)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// hasTransparency 判断图像是否包含非完全不透明的像素
+func hasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, alpha := img.At(x, y).RGBA(); alpha != 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// flattenOnto 将图像合成到纯色背景上
+func flattenOnto(img image.Image, background color.NRGBA) *image.NRGBA {
+	bounds := img.Bounds()
+	flattened := image.NewNRGBA(bounds)
+	draw.Draw(flattened, bounds, &image.Uniform{C: background}, image.Point{}, draw.Src)
+	draw.Draw(flattened, bounds, img, bounds.Min, draw.Over)
+	return flattened
+}
+
+// parseHexColor 解析 "#rgb" 或 "#rrggbb" 格式的颜色
+func parseHexColor(value string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: expected #rgb or #rrggbb", value)
+	}
+
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: %w", value, err)
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// 回收站配置
+const (
+	// trashDirName 回收站目录（位于 images 目录下），不参与未引用图片清理
+	trashDirName = ".trash"
+	// trashIndexName 回收站记录文件（位于回收站目录下）
+	trashIndexName = "tombstones.json"
+	// trashRetention 回收站中的图片保留时间，超过后在启动时永久删除
+	trashRetention = 30 * 24 * time.Hour
+)
+
+// ImageTombstone 回收站中一张图片的记录
+type ImageTombstone struct {
+	Ref       string `json:"ref"`       // 删除前的 image ref
+	Path      string `json:"path"`      // 删除前相对 images 目录的路径（斜杠分隔），恢复时移回此处
+	TrashName string `json:"trashName"` // 回收站目录中的文件名
+	TrashedAt int64  `json:"trashedAt"` // 删除时间（Unix 毫秒）
+}
+
+// TrashImage 将图片移入回收站（images/.trash/）并记录删除时间，可通过 RestoreTrashedImage 恢复
+// 图片文件不存在时返回错误；同一图片重复删除时更新删除时间
+func (s *ImageStorage) TrashImage(imageRef string) error {
+	filePath, err := s.GetImagePath(imageRef)
+	if err != nil {
+		return err
+	}
+	if filePath == "" {
+		return fmt.Errorf("empty image reference")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(filePath); err != nil {
+		return fmt.Errorf("image not found: %s", imageRef)
+	}
+	relPath, err := filepath.Rel(s.imagesDir, filePath)
+	if err != nil {
+		return fmt.Errorf("invalid image reference: %s", imageRef)
+	}
+
+	trashDir := filepath.Join(s.imagesDir, trashDirName)
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	tombstones, err := s.loadTombstonesLocked()
+	if err != nil {
+		return err
+	}
+
+	// 文件名为内容哈希，在回收站中同样唯一
+	trashName := filepath.Base(filePath)
+	if err := os.Rename(filePath, filepath.Join(trashDir, trashName)); err != nil {
+		return fmt.Errorf("failed to move image to trash: %w", err)
+	}
+
+	entry := ImageTombstone{
+		Ref:       s.getImageRef(relPath),
+		Path:      filepath.ToSlash(relPath),
+		TrashName: trashName,
+		TrashedAt: time.Now().UnixMilli(),
+	}
+	kept := tombstones[:0]
+	for _, tombstone := range tombstones {
+		if tombstone.TrashName != trashName {
+			kept = append(kept, tombstone)
+		}
+	}
+	return s.saveTombstonesLocked(append(kept, entry))
+}
+
+// RestoreTrashedImage 将回收站中的图片移回原位置
+// 图片已存在于存储中（如之后重新生成了相同内容）时直接删除回收站中的副本
+func (s *ImageStorage) RestoreTrashedImage(imageRef string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.loadTombstonesLocked()
+	if err != nil {
+		return err
+	}
+
+	// 按文件名（内容哈希）匹配，分片 ref 和旧版平铺 ref 都能找到同一张图片
+	trashName := path.Base(s.parseImageRef(imageRef))
+	for i, tombstone := range tombstones {
+		if tombstone.TrashName != trashName {
+			continue
+		}
+
+		trashPath := filepath.Join(s.imagesDir, trashDirName, tombstone.TrashName)
+		targetPath := filepath.Join(s.imagesDir, filepath.FromSlash(tombstone.Path))
+		if _, err := os.Stat(targetPath); err == nil {
+			os.Remove(trashPath)
+		} else {
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return fmt.Errorf("failed to create image shard directory: %w", err)
+			}
+			if err := os.Rename(trashPath, targetPath); err != nil {
+				return fmt.Errorf("failed to restore image: %w", err)
+			}
+		}
+
+		return s.saveTombstonesLocked(append(tombstones[:i], tombstones[i+1:]...))
+	}
+	return fmt.Errorf("image not found in trash: %s", imageRef)
+}
+
+// ListTrashedImages 列出回收站中的图片（按删除时间倒序）
+func (s *ImageStorage) ListTrashedImages() ([]ImageTombstone, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tombstones, err := s.loadTombstonesLocked()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tombstones, func(i, j int) bool {
+		return tombstones[i].TrashedAt > tombstones[j].TrashedAt
+	})
+	return tombstones, nil
+}
+
+// PurgeTrash 永久删除回收站中删除时间早于 maxAge 的图片，返回删除的数量
+func (s *ImageStorage) PurgeTrash(maxAge time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tombstones, err := s.loadTombstonesLocked()
+	if err != nil {
+		return 0, err
+	}
+	if len(tombstones) == 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-maxAge).UnixMilli()
+	purged := 0
+	kept := tombstones[:0]
+	for _, tombstone := range tombstones {
+		if tombstone.TrashedAt > cutoff {
+			kept = append(kept, tombstone)
+			continue
+		}
+		trashPath := filepath.Join(s.imagesDir, trashDirName, tombstone.TrashName)
+		if err := os.Remove(trashPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("[ImageStorage] Warning: failed to purge trashed image %s: %v\n", tombstone.TrashName, err)
+			kept = append(kept, tombstone)
+			continue
+		}
+		purged++
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+	return purged, s.saveTombstonesLocked(kept)
+}
+
+// loadTombstonesLocked 读取回收站记录，文件不存在时返回空列表（调用方需持有锁）
+func (s *ImageStorage) loadTombstonesLocked() ([]ImageTombstone, error) {
+	data, err := os.ReadFile(filepath.Join(s.imagesDir, trashDirName, trashIndexName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ImageTombstone{}, nil
+		}
+		return nil, fmt.Errorf("failed to read trash index: %w", err)
+	}
+
+	var tombstones []ImageTombstone
+	if err := json.Unmarshal(data, &tombstones); err != nil {
+		return nil, fmt.Errorf("invalid trash index format: %w", err)
+	}
+	return tombstones, nil
+}
+
+// saveTombstonesLocked 写入回收站记录（调用方需持有写锁）
+func (s *ImageStorage) saveTombstonesLocked(tombstones []ImageTombstone) error {
+	data, err := json.Marshal(tombstones)
+	if err != nil {
+		return fmt.Errorf("failed to serialize trash index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.imagesDir, trashDirName, trashIndexName), data); err != nil {
+		return fmt.Errorf("failed to write trash index: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"artifex/core/types"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// 日志文件配置
+const (
+	logFileName     = "app.log"
+	crashLogName    = "crash.log"
+	maxLogFileBytes = 5 << 20 // 单个日志文件大小上限，超过后轮转
+	maxLogBackups   = 3       // 保留的历史日志文件数（app.log.1 ~ app.log.3）
+)
+
+// activeLogService 当前进程的日志服务，供 FlushLogs 在进程退出前使用
+var (
+	activeLogMu      sync.Mutex
+	activeLogService *LogService
+)
+
+// LogService 日志文件服务
+// 各服务的诊断信息都通过 fmt.Printf 输出到标准输出，用户机器上通常没有控制台；
+// 启用文件日志后标准输出同时写入 <数据目录>/logs/app.log（按大小轮转），
+// 运行时崩溃信息写入 <数据目录>/logs/crash.log
+type LogService struct {
+	configService *ConfigService
+	logsDir       string
+
+	mu         sync.Mutex
+	file       *os.File // 当前日志文件
+	size       int64    // 当前日志文件大小
+	lineStart  bool     // 下一次写入是否位于行首（行首添加时间戳）
+	crashFile  *os.File
+	origStdout *os.File      // 启用前的标准输出
+	pipeWriter *os.File      // 替换后的标准输出
+	copyDone   chan struct{} // 标准输出转发结束
+}
+
+// NewLogService 创建日志服务实例
+func NewLogService(configService *ConfigService) *LogService {
+	return &LogService{configService: configService}
+}
+
+// Startup 在应用启动时调用，需在 ConfigService.Startup 之后
+// 根据 fileLoggingEnabled 设置启用文件日志，并在设置变更时重新应用
+func (l *LogService) Startup(ctx context.Context) {
+	dataDir, err := ResolveDataDir()
+	if err != nil {
+		fmt.Printf("[LogService] Warning: failed to resolve data dir: %v\n", err)
+		return
+	}
+	l.logsDir = filepath.Join(dataDir, "logs")
+
+	activeLogMu.Lock()
+	activeLogService = l
+	activeLogMu.Unlock()
+
+	l.applySettings()
+	runtime.EventsOn(ctx, ConfigChangedEvent, func(data ...interface{}) {
+		l.applySettings()
+	})
+}
+
+// Shutdown 在应用关闭时调用，写出剩余日志并关闭日志文件
+func (l *LogService) Shutdown() {
+	l.disable()
+}
+
+// GetLogPath 返回当前日志文件路径（未启用文件日志时文件可能不存在）
+func (l *LogService) GetLogPath() (string, error) {
+	if l.logsDir == "" {
+		return "", fmt.Errorf("log service not initialized")
+	}
+	return filepath.Join(l.logsDir, logFileName), nil
+}
+
+// FlushLogs 写出所有已输出的日志并关闭日志文件
+// 调用 os.Exit 之前必须调用，否则标准输出管道中尚未写入文件的内容会丢失
+func FlushLogs() {
+	activeLogMu.Lock()
+	l := activeLogService
+	activeLogMu.Unlock()
+	if l != nil {
+		l.disable()
+	}
+}
+
+// applySettings 按设置启用或关闭文件日志（内部方法）
+func (l *LogService) applySettings() {
+	enabled := true
+	if settingsJSON, err := l.configService.LoadSettings(); err == nil {
+		var settings types.Settings
+		if json.Unmarshal([]byte(settingsJSON), &settings) == nil {
+			enabled = settings.AI.FileLoggingEnabled
+		}
+	}
+
+	if !enabled {
+		l.disable()
+		return
+	}
+	if err := l.enable(); err != nil {
+		fmt.Printf("[LogService] Warning: failed to enable file logging: %v\n", err)
+	}
+}
+
+// enable 打开日志文件并将标准输出转发到日志文件（内部方法，已启用时不做处理）
+func (l *LogService) enable() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.pipeWriter != nil {
+		return nil
+	}
+	if err := os.MkdirAll(l.logsDir, 0755); err != nil {
+		return fmt.Errorf("failed to create logs directory: %w", err)
+	}
+	if err := l.openLocked(); err != nil {
+		return err
+	}
+
+	pipeReader, pipeWriter, err := os.Pipe()
+	if err != nil {
+		l.file.Close()
+		l.file = nil
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	l.origStdout = os.Stdout
+	l.pipeWriter = pipeWriter
+	l.copyDone = make(chan struct{})
+	os.Stdout = pipeWriter
+	go l.forward(pipeReader, l.origStdout, l.copyDone)
+
+	// 崩溃信息由运行时直接写入文件描述符，无法经过标准输出转发，单独记录
+	crashFile, err := os.OpenFile(filepath.Join(l.logsDir, crashLogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err == nil {
+		if err := debug.SetCrashOutput(crashFile, debug.CrashOptions{}); err == nil {
+			l.crashFile = crashFile
+		} else {
+			crashFile.Close()
+		}
+	}
+
+	return nil
+}
+
+// disable 恢复标准输出，写出剩余日志并关闭日志文件（内部方法，未启用时不做处理）
+func (l *LogService) disable() {
+	l.mu.Lock()
+	if l.pipeWriter == nil {
+		l.mu.Unlock()
+		return
+	}
+	os.Stdout = l.origStdout
+	pipeWriter, copyDone := l.pipeWriter, l.copyDone
+	l.pipeWriter, l.copyDone = nil, nil
+	l.mu.Unlock()
+
+	// 关闭写端后等待转发协程读完管道中的剩余内容（转发时需要获取 mu）
+	pipeWriter.Close()
+	<-copyDone
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		l.file.Sync()
+		l.file.Close()
+		l.file = nil
+	}
+	if l.crashFile != nil {
+		debug.SetCrashOutput(nil, debug.CrashOptions{})
+		l.crashFile.Close()
+		l.crashFile = nil
+	}
+}
+
+// forward 将标准输出管道中的内容同时写入控制台和日志文件（内部方法）
+// 控制台写入失败（如 Windows GUI 程序没有控制台）时忽略，不影响日志文件
+func (l *LogService) forward(pipeReader *os.File, console *os.File, done chan struct{}) {
+	defer close(done)
+	defer pipeReader.Close()
+
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := pipeReader.Read(buf)
+		if n > 0 {
+			if console != nil {
+				console.Write(buf[:n])
+			}
+			l.write(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write 写入日志文件，行首添加时间戳，超过大小上限时轮转（内部方法）
+func (l *LogService) write(p []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return
+	}
+	if l.size+int64(len(p)) > maxLogFileBytes && l.size > 0 {
+		if err := l.rotateLocked(); err != nil {
+			return
+		}
+	}
+
+	for len(p) > 0 {
+		if l.lineStart {
+			n, _ := l.file.WriteString(time.Now().Format("2006-01-02 15:04:05.000 "))
+			l.size += int64(n)
+			l.lineStart = false
+		}
+		line := p
+		if idx := bytes.IndexByte(p, '\n'); idx >= 0 {
+			line = p[:idx+1]
+			l.lineStart = true
+		}
+		n, _ := l.file.Write(line)
+		l.size += int64(n)
+		p = p[len(line):]
+	}
+}
+
+// openLocked 以追加模式打开日志文件（调用方需持有锁）
+func (l *LogService) openLocked() error {
+	file, err := os.OpenFile(filepath.Join(l.logsDir, logFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	l.file = file
+	l.size = info.Size()
+	l.lineStart = true
+	return nil
+}
+
+// rotateLocked 轮转日志文件：app.log -> app.log.1 -> ... -> app.log.N，超出的最旧文件被删除（调用方需持有锁）
+func (l *LogService) rotateLocked() error {
+	l.file.Close()
+	l.file = nil
+
+	base := filepath.Join(l.logsDir, logFileName)
+	os.Remove(fmt.Sprintf("%s.%d", base, maxLogBackups))
+	for i := maxLogBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", base, i), fmt.Sprintf("%s.%d", base, i+1))
+	}
+	if err := os.Rename(base, base+".1"); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(l.origStdout, "[LogService] Warning: failed to rotate log file: %v\n", err)
+	}
+
+	return l.openLocked()
+}
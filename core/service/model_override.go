@@ -0,0 +1,78 @@
+package service
+
+import (
+	"artifex/core/provider"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// modelListTTL 提供商模型列表的缓存时间
+	modelListTTL = 10 * time.Minute
+	// modelListTimeout 获取模型列表的超时时间
+	modelListTimeout = 10 * time.Second
+)
+
+// modelList 缓存的提供商模型列表
+type modelList struct {
+	models    []string
+	fetchedAt time.Time
+}
+
+// validateModelOverride 校验调用参数中的模型覆盖（内部方法）
+// 提供商实现了 ModelLister 时检查模型是否在可用列表中；
+// 未实现或获取列表失败时不做校验，由提供商在调用时报告错误
+func (a *AIService) validateModelOverride(aiProvider provider.AIProvider, model string) error {
+	if model == "" {
+		return nil
+	}
+	lister, ok := aiProvider.(provider.ModelLister)
+	if !ok {
+		return nil
+	}
+
+	models, err := a.providerModels(aiProvider.Name(), lister)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: skipping model override validation for %s: %v\n", aiProvider.Name(), a.sanitizeError(err))
+		return nil
+	}
+	for _, available := range models {
+		if available == model {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q is not available for provider %s", model, aiProvider.Name())
+}
+
+// providerModels 返回提供商的模型列表，在 modelListTTL 内使用缓存（内部方法）
+func (a *AIService) providerModels(providerName string, lister provider.ModelLister) ([]string, error) {
+	a.modelListMu.Lock()
+	cached, ok := a.modelLists[providerName]
+	a.modelListMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < modelListTTL {
+		return cached.models, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelListTimeout)
+	defer cancel()
+	models, err := lister.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.modelListMu.Lock()
+	if a.modelLists == nil {
+		a.modelLists = make(map[string]modelList)
+	}
+	a.modelLists[providerName] = modelList{models: models, fetchedAt: time.Now()}
+	a.modelListMu.Unlock()
+	return models, nil
+}
+
+// clearModelLists 清除模型列表缓存（配置变更后提供商可能指向不同的服务）
+func (a *AIService) clearModelLists() {
+	a.modelListMu.Lock()
+	a.modelLists = nil
+	a.modelListMu.Unlock()
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// pdfPageSizes 支持的纸张尺寸（纵向，单位：pt）
+var pdfPageSizes = map[string][2]float64{
+	"a4":     {595.28, 841.89},
+	"letter": {612, 792},
+}
+
+// defaultPDFMargin 默认页边距（pt，约 12.7mm）
+const defaultPDFMargin = 36
+
+// PDFExportOptions PDF 导出选项
+type PDFExportOptions struct {
+	PageSize string   `json:"pageSize,omitempty"` // "A4"（默认）或 "Letter"
+	Margin   *float64 `json:"margin,omitempty"`   // 页边距（pt），默认 36
+}
+
+// pdfImage 待写入 PDF 的图像
+type pdfImage struct {
+	width, height int
+	filter        string // "DCTDecode" 或 "FlateDecode"
+	colorSpace    string // "DeviceRGB" 或 "DeviceGray"
+	data          []byte // 图像数据（JPEG 原始数据或 zlib 压缩的 RGB）
+	alpha         []byte // zlib 压缩的透明通道（可选）
+}
+
+// ExportPDF 将一组图像导出为多页 PDF，每页一张图像并按比例缩放居中
+// imageRefsJSON: 图像 ref 或 data URL 的 JSON 数组
+// optionsJSON: PDFExportOptions 的 JSON（可选），如 {"pageSize": "Letter", "margin": 24}
+// 每页根据图像宽高自动选择横向或纵向；返回保存的文件路径，用户取消时返回空字符串
+func (f *FileService) ExportPDF(imageRefsJSON string, optionsJSON string) (string, error) {
+	if f.ctx == nil {
+		return "", fmt.Errorf("service not initialized")
+	}
+
+	var refs []string
+	if err := json.Unmarshal([]byte(imageRefsJSON), &refs); err != nil {
+		return "", fmt.Errorf("invalid image refs: %w", err)
+	}
+	if len(refs) == 0 {
+		return "", fmt.Errorf("no images to export")
+	}
+
+	var options PDFExportOptions
+	if strings.TrimSpace(optionsJSON) != "" {
+		if err := json.Unmarshal([]byte(optionsJSON), &options); err != nil {
+			return "", fmt.Errorf("invalid pdf options: %w", err)
+		}
+	}
+
+	pageSize, ok := pdfPageSizes[strings.ToLower(options.PageSize)]
+	if options.PageSize == "" {
+		pageSize, ok = pdfPageSizes["a4"], true
+	}
+	if !ok {
+		return "", fmt.Errorf("unsupported page size %q, allowed values: A4, Letter", options.PageSize)
+	}
+
+	margin := float64(defaultPDFMargin)
+	if options.Margin != nil {
+		margin = *options.Margin
+	}
+	if margin < 0 || margin*2 >= math.Min(pageSize[0], pageSize[1]) {
+		return "", fmt.Errorf("invalid margin %.2f", margin)
+	}
+
+	images := make([]*pdfImage, 0, len(refs))
+	for i, ref := range refs {
+		imageData, err := f.loadImageBytes(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to load image %d: %w", i+1, err)
+		}
+		img, err := newPDFImage(imageData)
+		if err != nil {
+			return "", fmt.Errorf("failed to prepare image %d: %w", i+1, err)
+		}
+		images = append(images, img)
+	}
+
+	filePath, err := runtime.SaveFileDialog(f.ctx, runtime.SaveDialogOptions{
+		DefaultFilename: fmt.Sprintf("artifexBot-export-%d.pdf", time.Now().Unix()),
+		Title:           "Export PDF",
+		Filters: []runtime.FileFilter{
+			{
+				DisplayName: "PDF Document (*.pdf)",
+				Pattern:     "*.pdf",
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("save dialog error: %w", err)
+	}
+
+	// 用户取消了保存
+	if filePath == "" {
+		return "", nil
+	}
+
+	if err := os.WriteFile(filePath, buildPDF(images, pageSize, margin), 0644); err != nil {
+		return "", fmt.Errorf("failed to write pdf file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+// newPDFImage 将图像数据转换为 PDF 图像对象
+// RGB（YCbCr）和灰度 JPEG 直接嵌入并声明对应的颜色空间；CMYK JPEG 的反相约定因软件而异，
+// 与其他格式一样解码后以 zlib 压缩的 RGB 数据嵌入（含透明通道时附加 SMask）
+func newPDFImage(data []byte) (*pdfImage, error) {
+	if detectImageFormat(data) == "jpeg" {
+		config, err := decodeJPEGConfig(data)
+		if err == nil {
+			switch config.ColorModel {
+			case color.YCbCrModel:
+				return &pdfImage{width: config.Width, height: config.Height, filter: "DCTDecode", colorSpace: "DeviceRGB", data: data}, nil
+			case color.GrayModel:
+				return &pdfImage{width: config.Width, height: config.Height, filter: "DCTDecode", colorSpace: "DeviceGray", data: data}, nil
+			}
+		}
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+	alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+	transparent := false
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			if a != 0xffff {
+				transparent = true
+			}
+			// 反预乘，PDF 中颜色与 SMask 分开存储
+			if a > 0 && a < 0xffff {
+				r, g, b = r*0xffff/a, g*0xffff/a, b*0xffff/a
+			}
+			rgb = append(rgb, uint8(r>>8), uint8(g>>8), uint8(b>>8))
+			alpha = append(alpha, uint8(a>>8))
+		}
+	}
+
+	result := &pdfImage{width: bounds.Dx(), height: bounds.Dy(), filter: "FlateDecode", colorSpace: "DeviceRGB", data: zlibCompress(rgb)}
+	if transparent {
+		result.alpha = zlibCompress(alpha)
+	}
+	return result, nil
+}
+
+// decodeJPEGConfig 读取 JPEG 尺寸
+func decodeJPEGConfig(data []byte) (image.Config, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return image.Config{}, err
+	}
+	if format != "jpeg" {
+		return image.Config{}, fmt.Errorf("not a jpeg image")
+	}
+	return config, nil
+}
+
+// zlibCompress 使用 zlib 压缩数据
+func zlibCompress(data []byte) []byte {
+	var buf bytes.Buffer
+	writer := zlib.NewWriter(&buf)
+	writer.Write(data)
+	writer.Close()
+	return buf.Bytes()
+}
+
+// buildPDF 生成多页 PDF 文档，每页一张图像
+func buildPDF(images []*pdfImage, pageSize [2]float64, margin float64) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	// 对象编号从 1 开始：1 为 Catalog，2 为 Pages，其余按页分配
+	nextID := 3
+	allocate := func() int {
+		id := nextID
+		nextID++
+		return id
+	}
+	writeObject := func(id int, body string, stream []byte) {
+		for len(offsets) < id {
+			offsets = append(offsets, 0)
+		}
+		offsets[id-1] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\n", id, body)
+		if stream != nil {
+			buf.WriteString("stream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream\n")
+		}
+		buf.WriteString("endobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	pageIDs := make([]string, 0, len(images))
+	for _, img := range images {
+		pageID, contentID, imageID := allocate(), allocate(), allocate()
+
+		// 根据图像方向选择页面方向
+		pageWidth, pageHeight := pageSize[0], pageSize[1]
+		if img.width > img.height {
+			pageWidth, pageHeight = pageHeight, pageWidth
+		}
+
+		// 按比例缩放并居中
+		availableWidth := pageWidth - margin*2
+		availableHeight := pageHeight - margin*2
+		scale := math.Min(availableWidth/float64(img.width), availableHeight/float64(img.height))
+		drawWidth := float64(img.width) * scale
+		drawHeight := float64(img.height) * scale
+		drawX := (pageWidth - drawWidth) / 2
+		drawY := (pageHeight - drawHeight) / 2
+
+		smask := ""
+		if img.alpha != nil {
+			smaskID := allocate()
+			writeObject(smaskID, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+				img.width, img.height, len(img.alpha)), img.alpha)
+			smask = fmt.Sprintf(" /SMask %d 0 R", smaskID)
+		}
+
+		writeObject(imageID, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /%s /BitsPerComponent 8 /Filter /%s%s /Length %d >>",
+			img.width, img.height, img.colorSpace, img.filter, smask, len(img.data)), img.data)
+
+		content := []byte(fmt.Sprintf("q\n%.2f 0 0 %.2f %.2f %.2f cm\n/Im0 Do\nQ", drawWidth, drawHeight, drawX, drawY))
+		writeObject(contentID, fmt.Sprintf("<< /Length %d >>", len(content)), content)
+
+		writeObject(pageID, fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /XObject << /Im0 %d 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, imageID, contentID), nil)
+		pageIDs = append(pageIDs, fmt.Sprintf("%d 0 R", pageID))
+	}
+
+	writeObject(1, "<< /Type /Catalog /Pages 2 0 R >>", nil)
+	writeObject(2, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(pageIDs, " "), len(pageIDs)), nil)
+
+	// 交叉引用表
+	xrefOffset := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xrefOffset)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestNewPDFImageJPEGColorSpace(t *testing.T) {
+	cases := []struct {
+		name       string
+		img        image.Image
+		colorSpace string
+	}{
+		{name: "rgb", img: image.NewRGBA(image.Rect(0, 0, 8, 8)), colorSpace: "DeviceRGB"},
+		{name: "grayscale", img: image.NewGray(image.Rect(0, 0, 8, 8)), colorSpace: "DeviceGray"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, tc.img, nil); err != nil {
+				t.Fatalf("encode jpeg: %v", err)
+			}
+			pdfImg, err := newPDFImage(buf.Bytes())
+			if err != nil {
+				t.Fatalf("newPDFImage: %v", err)
+			}
+			if pdfImg.filter != "DCTDecode" || pdfImg.colorSpace != tc.colorSpace {
+				t.Fatalf("expected DCTDecode %s, got %s %s", tc.colorSpace, pdfImg.filter, pdfImg.colorSpace)
+			}
+
+			pdf := buildPDF([]*pdfImage{pdfImg}, pdfPageSizes["a4"], defaultPDFMargin)
+			if !bytes.Contains(pdf, []byte("/ColorSpace /"+tc.colorSpace+" ")) {
+				t.Fatalf("PDF does not declare /ColorSpace /%s", tc.colorSpace)
+			}
+		})
+	}
+}
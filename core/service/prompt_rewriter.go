@@ -0,0 +1,217 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PromptRewriteRule 提示词改写规则
+// 当编辑提示词命中任一关键词时，使用 Template 替换原提示词
+// Template 中的 {{prompt}} 会被替换为用户的原始提示词
+type PromptRewriteRule struct {
+	Name     string   `json:"name"`
+	Keywords []string `json:"keywords"`
+	Template string   `json:"template"`
+}
+
+// PromptRewriteFile 改写规则文件数据结构（config/prompt_rewrites.json）
+type PromptRewriteFile struct {
+	Version string              `json:"version"`
+	Rules   []PromptRewriteRule `json:"rules"`
+}
+
+// defaultPromptRewriteRules 内置的默认改写规则，规则文件不存在时使用
+// 只使用明确表达放大/扩图意图的关键词，模板保留用户的原始提示词
+var defaultPromptRewriteRules = []PromptRewriteRule{
+	{
+		Name:     "upscale",
+		Keywords: []string{"放大", "超分", "提高分辨率", "upscale", "enlarge", "super resolution", "higher resolution"},
+		Template: "Upscale this image to a higher resolution. Preserve the original composition, colors, subjects and style exactly; only increase sharpness and fine detail. Do not add, remove or change any content. User request: {{prompt}}",
+	},
+	{
+		Name:     "outpaint",
+		Keywords: []string{"扩图", "外扩", "outpaint", "extend the image", "expand the image"},
+		Template: "Extend this image beyond its current borders. Fill the new areas so they continue the existing scene seamlessly, matching the lighting, perspective, colors and style. Keep the original content unchanged. User request: {{prompt}}",
+	},
+}
+
+// PromptRewriter 编辑提示词改写器
+// 规则从 config/prompt_rewrites.json 加载，文件不存在时使用内置默认规则
+type PromptRewriter struct {
+	rulesFile string
+	rules     []PromptRewriteRule
+	mu        sync.RWMutex
+}
+
+// NewPromptRewriter 创建提示词改写器（初始使用内置默认规则）
+func NewPromptRewriter(dataDir string) *PromptRewriter {
+	return &PromptRewriter{
+		rulesFile: filepath.Join(dataDir, "prompt_rewrites.json"),
+		rules:     defaultPromptRewriteRules,
+	}
+}
+
+// Load 从磁盘加载改写规则
+// 文件不存在时回退到内置默认规则；文件格式错误时保留内置默认规则并返回错误
+func (r *PromptRewriter) Load() error {
+	data, err := os.ReadFile(r.rulesFile)
+	if err != nil {
+		r.setRules(defaultPromptRewriteRules)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read prompt rewrite rules: %w", err)
+	}
+
+	var file PromptRewriteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		r.setRules(defaultPromptRewriteRules)
+		return fmt.Errorf("invalid prompt rewrite rules format: %w", err)
+	}
+
+	rules := make([]PromptRewriteRule, 0, len(file.Rules))
+	for _, rule := range file.Rules {
+		if len(rule.Keywords) == 0 || strings.TrimSpace(rule.Template) == "" {
+			fmt.Printf("[PromptRewriter] Warning: skipping rule %q without keywords or template\n", rule.Name)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	r.setRules(rules)
+	return nil
+}
+
+// setRules 替换当前规则（内部方法）
+func (r *PromptRewriter) setRules(rules []PromptRewriteRule) {
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+}
+
+// Rewrite 按规则改写提示词
+// 关键词需作为独立意图出现才会命中（见 containsIntent）
+// 返回改写后的提示词和命中的规则名称；未命中任何规则时原样返回，规则名称为空
+func (r *PromptRewriter) Rewrite(prompt string) (string, string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	lower := strings.ToLower(prompt)
+	for _, rule := range r.rules {
+		for _, keyword := range rule.Keywords {
+			keyword = strings.ToLower(strings.TrimSpace(keyword))
+			if keyword == "" || !containsIntent(lower, keyword) {
+				continue
+			}
+			return strings.ReplaceAll(rule.Template, "{{prompt}}", prompt), rule.Name
+		}
+	}
+	return prompt, ""
+}
+
+// rewritePromptIfNeeded 在启用提示词改写时按规则改写编辑提示词（内部方法）
+func (a *AIService) rewritePromptIfNeeded(prompt string) string {
+	if a.promptRewriter == nil || !a.promptRewriteEnabled.Load() {
+		return prompt
+	}
+
+	rewritten, ruleName := a.promptRewriter.Rewrite(prompt)
+	if ruleName != "" {
+		fmt.Printf("[AIService] Prompt rewritten by rule %q\n", ruleName)
+	}
+	return rewritten
+}
+
+// ==================== 关键词匹配 ====================
+
+// latinNegations 出现在关键词前（同一分句内最近三个词）时视为否定的英文词
+var latinNegations = map[string]bool{
+	"don't": true, "don’t": true, "dont": true, "not": true, "no": true,
+	"never": true, "without": true, "avoid": true,
+}
+
+// cjkNegations 紧邻关键词之前时视为否定的中文词
+var cjkNegations = []string{"不要", "不用", "无需", "不需要", "别", "勿", "不"}
+
+// cjkNounSuffixes 紧跟在中文关键词之后时构成名词（如"扩展名"、"可扩展性"），不视为编辑意图
+const cjkNounSuffixes = "名性包器板栏度率"
+
+// clauseSeparators 分句分隔符，否定词只在同一分句内生效
+const clauseSeparators = ".,;:!?\n，。；：！？、"
+
+// containsIntent 判断关键词是否作为独立意图出现在提示词中（prompt 和 keyword 均已转为小写）
+// - 英文关键词需在词边界上（"enlarge" 不匹配 "enlargement"）
+// - 中文关键词后不能紧跟构成名词的字（"扩展" 不匹配 "扩展名"）
+// - 同一分句内关键词前有否定词时不匹配（"don't enhance the contrast"、"不要放大"）
+func containsIntent(prompt, keyword string) bool {
+	for offset := 0; offset < len(prompt); {
+		idx := strings.Index(prompt[offset:], keyword)
+		if idx < 0 {
+			return false
+		}
+		start := offset + idx
+		end := start + len(keyword)
+		offset = end
+
+		if isStandaloneKeyword(prompt, keyword, start, end) && !isNegated(prompt[:start]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isStandaloneKeyword 检查 prompt[start:end] 处的关键词是否独立成词
+func isStandaloneKeyword(prompt, keyword string, start, end int) bool {
+	first, _ := utf8.DecodeRuneInString(keyword)
+	last, _ := utf8.DecodeLastRuneInString(keyword)
+
+	if !unicode.Is(unicode.Han, first) {
+		if before, _ := utf8.DecodeLastRuneInString(prompt[:start]); start > 0 && isWordRune(before) {
+			return false
+		}
+	}
+	if end < len(prompt) {
+		after, _ := utf8.DecodeRuneInString(prompt[end:])
+		if unicode.Is(unicode.Han, last) {
+			if strings.ContainsRune(cjkNounSuffixes, after) {
+				return false
+			}
+		} else if isWordRune(after) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordRune 判断字符是否属于英文等字母文字的单词（汉字视为天然的词边界）
+func isWordRune(r rune) bool {
+	return (unicode.IsLetter(r) || unicode.IsDigit(r)) && !unicode.Is(unicode.Han, r)
+}
+
+// isNegated 检查关键词之前（同一分句内）是否有否定词
+func isNegated(before string) bool {
+	if idx := strings.LastIndexAny(before, clauseSeparators); idx >= 0 {
+		_, size := utf8.DecodeRuneInString(before[idx:])
+		before = before[idx+size:]
+	}
+
+	trimmed := strings.TrimSpace(before)
+	for _, negation := range cjkNegations {
+		if strings.HasSuffix(trimmed, negation) {
+			return true
+		}
+	}
+
+	words := strings.Fields(trimmed)
+	for i := len(words) - 1; i >= 0 && i >= len(words)-3; i-- {
+		if latinNegations[strings.Trim(words[i], "\"'()")] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptRewriterRewrite(t *testing.T) {
+	rewriter := NewPromptRewriter(t.TempDir())
+
+	cases := []struct {
+		name   string
+		prompt string
+		rule   string // 期望命中的规则，空表示不应改写
+	}{
+		// 不应改写的提示词
+		{name: "negated enhance", prompt: "don't enhance the contrast, keep it natural", rule: ""},
+		{name: "negated with curly apostrophe", prompt: "Don’t enhance anything, just remove the person", rule: ""},
+		{name: "negated within three words", prompt: "please do not really enlarge it", rule: ""},
+		{name: "keyword inside longer word", prompt: "add a sign about the enlargement discussion", rule: ""},
+		{name: "keyword as prefix of word", prompt: "make the enhanced areas darker", rule: ""},
+		{name: "keyword as suffix of word", prompt: "replace the reenhance label", rule: ""},
+		{name: "without upscale", prompt: "change the sky to sunset without upscale", rule: ""},
+		{name: "cjk negation", prompt: "不要放大，只把背景换成蓝色", rule: ""},
+		{name: "cjk short negation", prompt: "别扩图，把猫换成狗", rule: ""},
+		{name: "cjk noun compound", prompt: "把图中的文件扩展名改成 png", rule: ""},
+		{name: "cjk property noun", prompt: "在海报上写“可扩展性”", rule: ""},
+		{name: "unrelated prompt", prompt: "turn the car red", rule: ""},
+		{name: "enhance is not upscale", prompt: "enhance the sky with a sunset", rule: ""},
+		{name: "cjk generic extend", prompt: "把道路向远处扩展一些", rule: ""},
+
+		// 应改写的提示词
+		{name: "plain upscale", prompt: "upscale", rule: "upscale"},
+		{name: "upscale sentence", prompt: "Please enlarge this photo", rule: "upscale"},
+		{name: "negation in other clause", prompt: "don't change the colors, just upscale it", rule: "upscale"},
+		{name: "multi word keyword", prompt: "give me a higher resolution version", rule: "upscale"},
+		{name: "cjk upscale", prompt: "请把这张图放大", rule: "upscale"},
+		{name: "cjk outpaint", prompt: "向左右扩图", rule: "outpaint"},
+		{name: "english outpaint", prompt: "extend the image to 16:9", rule: "outpaint"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rewritten, rule := rewriter.Rewrite(tc.prompt)
+			if rule != tc.rule {
+				t.Fatalf("Rewrite(%q) matched rule %q, want %q", tc.prompt, rule, tc.rule)
+			}
+			if tc.rule == "" && rewritten != tc.prompt {
+				t.Fatalf("Rewrite(%q) changed the prompt to %q", tc.prompt, rewritten)
+			}
+			if tc.rule != "" && !strings.Contains(rewritten, tc.prompt) {
+				t.Fatalf("Rewrite(%q) dropped the original prompt: %q", tc.prompt, rewritten)
+			}
+		})
+	}
+}
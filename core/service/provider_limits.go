@@ -0,0 +1,46 @@
+package service
+
+import (
+	"artifex/core/provider"
+)
+
+// 限额信息状态
+const (
+	ProviderLimitsKnown   = "known"   // 已从提供商的响应头中获取到限额信息
+	ProviderLimitsUnknown = "unknown" // 提供商不返回限额信息，或尚未收到带限额信息的响应
+)
+
+// ProviderLimits 提供商的限额信息
+type ProviderLimits struct {
+	Provider string                  `json:"provider"`
+	Status   string                  `json:"status"`           // "known" 或 "unknown"
+	Limits   *provider.RateLimitInfo `json:"limits,omitempty"` // 最近一次观察到的限额信息（status 为 "known" 时存在）
+}
+
+// GetProviderLimits 返回提供商最近一次响应中的限额信息（剩余请求数、重置时间等）
+// providerName 为空时使用当前配置的提供商
+// 信息来自实际调用的响应头，不会为此发起请求；提供商未实现 RateLimitReporter、
+// 或自启动（配置变更后提供商会重新创建）以来尚未收到带限额响应头的响应时，status 为 "unknown"
+func (a *AIService) GetProviderLimits(providerName string) (*ProviderLimits, error) {
+	if providerName == "" {
+		aiSettings, err := a.loadAISettings()
+		if err != nil {
+			return nil, err
+		}
+		providerName = aiSettings.Provider
+	}
+
+	aiProvider, err := a.GetProvider(providerName)
+	if err != nil {
+		return nil, a.sanitizeError(err)
+	}
+
+	result := &ProviderLimits{Provider: providerName, Status: ProviderLimitsUnknown}
+	if reporter, ok := aiProvider.(provider.RateLimitReporter); ok {
+		if info, seen := reporter.RateLimits(); seen {
+			result.Status = ProviderLimitsKnown
+			result.Limits = &info
+		}
+	}
+	return result, nil
+}
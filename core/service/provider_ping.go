@@ -0,0 +1,175 @@
+package service
+
+import (
+	"artifex/core/provider"
+	"artifex/core/types"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// providerPingTimeout 连通性检测的超时时间
+const providerPingTimeout = 5 * time.Second
+
+// 各提供商默认的服务地址
+const (
+	defaultGeminiEndpoint = "https://generativelanguage.googleapis.com"
+	defaultOpenAIBaseURL  = "https://api.openai.com/v1"
+	// defaultStabilityBaseURL Stability AI API 地址
+	defaultStabilityBaseURL = "https://api.stability.ai"
+	// defaultReplicateBaseURL Replicate API 地址
+	defaultReplicateBaseURL = "https://api.replicate.com/v1"
+)
+
+// PingResult 提供商连通性检测结果
+type PingResult struct {
+	Provider   string `json:"provider"`
+	URL        string `json:"url"`                  // 实际检测的地址
+	Reachable  bool   `json:"reachable"`            // 是否收到 HTTP 响应（任意状态码都视为可达）
+	StatusCode int    `json:"statusCode,omitempty"` // HTTP 状态码
+	LatencyMs  int64  `json:"latencyMs"`            // 从发出请求到收到响应头的耗时（毫秒）
+	Error      string `json:"error,omitempty"`      // 网络错误信息（已脱敏）
+}
+
+// PingProvider 检测提供商服务地址的连通性，不消耗生成配额
+// providerName 为空时检测当前配置的提供商
+// - openai: GET <openaiBaseUrl>/models（携带 API Key，可同时验证鉴权）
+// - gemini: HEAD Gemini API 或 Vertex AI 区域端点
+// - cloud: HEAD 云服务端点
+// - stability: GET <stabilityBaseUrl>/v1/user/account（携带 API Key）
+// - local: GET <localEndpointUrl>/sdapi/v1/samplers
+// - replicate: GET https://api.replicate.com/v1/account（携带 API Token）
+// 网络错误不作为方法错误返回，而是记录在结果的 error 字段中
+func (a *AIService) PingProvider(providerName string) (result *PingResult, err error) {
+	defer func() { err = a.sanitizeError(err) }()
+
+	aiSettings, err := a.loadAISettings()
+	if err != nil {
+		return nil, err
+	}
+	if providerName == "" {
+		providerName = aiSettings.Provider
+	}
+
+	req, err := newPingRequest(providerName, aiSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), providerPingTimeout)
+	defer cancel()
+	req = req.WithContext(ctx)
+
+	result = &PingResult{Provider: providerName, URL: req.URL.String()}
+
+	// 使用与提供商相同的连接配置（代理、拨号超时等）
+	transport := provider.NewHTTPTransport(aiSettings)
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	result.LatencyMs = time.Since(startTime).Milliseconds()
+	if err != nil {
+		result.Error = a.sanitizeMessage(err.Error())
+		return result, nil
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	result.Reachable = true
+	result.StatusCode = resp.StatusCode
+	return result, nil
+}
+
+// newPingRequest 根据提供商配置构造连通性检测请求（内部函数）
+func newPingRequest(providerName string, settings types.AISettings) (*http.Request, error) {
+	switch providerName {
+	case "openai":
+		baseURL := strings.TrimRight(settings.OpenAIBaseURL, "/")
+		if baseURL == "" {
+			baseURL = defaultOpenAIBaseURL
+		}
+		if problem := validateURLSetting("openaiBaseUrl", baseURL); problem != "" {
+			return nil, fmt.Errorf("%s", problem)
+		}
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/models", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		if settings.OpenAIAPIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+settings.OpenAIAPIKey)
+		}
+		return req, nil
+
+	case "gemini":
+		endpoint := defaultGeminiEndpoint
+		if settings.UseVertexAI && settings.VertexLocation != "" {
+			endpoint = fmt.Sprintf("https://%s-aiplatform.googleapis.com", settings.VertexLocation)
+		}
+		req, err := http.NewRequest(http.MethodHead, endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		return req, nil
+
+	case "cloud":
+		if settings.CloudEndpointURL == "" {
+			return nil, fmt.Errorf("cloud requires cloudEndpointUrl")
+		}
+		if problem := validateURLSetting("cloudEndpointUrl", settings.CloudEndpointURL); problem != "" {
+			return nil, fmt.Errorf("%s", problem)
+		}
+		req, err := http.NewRequest(http.MethodHead, settings.CloudEndpointURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		return req, nil
+
+	case "stability":
+		baseURL := strings.TrimRight(settings.StabilityBaseURL, "/")
+		if baseURL == "" {
+			baseURL = defaultStabilityBaseURL
+		}
+		if problem := validateURLSetting("stabilityBaseUrl", baseURL); problem != "" {
+			return nil, fmt.Errorf("%s", problem)
+		}
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/v1/user/account", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		if settings.StabilityAPIKey != "" {
+			req.Header.Set("Authorization", "Bearer "+settings.StabilityAPIKey)
+		}
+		return req, nil
+
+	case "local":
+		if settings.LocalEndpointURL == "" {
+			return nil, fmt.Errorf("local requires localEndpointUrl")
+		}
+		if problem := validateURLSetting("localEndpointUrl", settings.LocalEndpointURL); problem != "" {
+			return nil, fmt.Errorf("%s", problem)
+		}
+		req, err := http.NewRequest(http.MethodGet, strings.TrimRight(settings.LocalEndpointURL, "/")+"/sdapi/v1/samplers", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		return req, nil
+
+	case "replicate":
+		req, err := http.NewRequest(http.MethodGet, defaultReplicateBaseURL+"/account", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ping request: %w", err)
+		}
+		if settings.ReplicateAPIToken != "" {
+			req.Header.Set("Authorization", "Bearer "+settings.ReplicateAPIToken)
+		}
+		return req, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported provider: %s", providerName)
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultMaxConcurrentRequests 默认允许同时进行的提供商调用数量
+const defaultMaxConcurrentRequests = 3
+
+// RequestLimiter 限制同时进行的 AI 提供商调用数量
+// 超出上限的请求会排队等待，排队期间仍可通过请求 context 取消
+type RequestLimiter struct {
+	mu    sync.Mutex
+	limit int
+	// 正在执行和排队中的请求数量，用于限流和前端展示队列状态
+	active int
+	queued int
+	// wake 在名额释放或上限调整时关闭并替换，唤醒排队的请求重新检查
+	wake chan struct{}
+}
+
+// RequestQueueStatus 请求队列状态
+type RequestQueueStatus struct {
+	Limit  int `json:"limit"`  // 最大并发数
+	Active int `json:"active"` // 正在执行的请求数
+	Queued int `json:"queued"` // 排队等待的请求数
+}
+
+// NewRequestLimiter 创建请求限流器
+// limit <= 0 时使用默认并发数
+func NewRequestLimiter(limit int) *RequestLimiter {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentRequests
+	}
+	return &RequestLimiter{
+		limit: limit,
+		wake:  make(chan struct{}),
+	}
+}
+
+// SetLimit 调整最大并发数
+// 已经在执行的请求继续计入新的上限：调低时要等执行中的请求数降到新上限以下才放行排队的请求
+func (l *RequestLimiter) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = defaultMaxConcurrentRequests
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.limit == limit {
+		return
+	}
+	l.limit = limit
+	l.wakeWaitersLocked()
+}
+
+// Acquire 获取一个执行名额，阻塞直到有空位或 ctx 被取消
+// 返回的 release 函数必须在调用结束后执行
+func (l *RequestLimiter) Acquire(ctx context.Context) (func(), error) {
+	l.mu.Lock()
+	l.queued++
+	for l.active >= l.limit {
+		wake := l.wake
+		l.mu.Unlock()
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			l.mu.Lock()
+			l.queued--
+			l.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.queued--
+	l.active++
+	l.mu.Unlock()
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			l.mu.Lock()
+			l.active--
+			l.wakeWaitersLocked()
+			l.mu.Unlock()
+		})
+	}
+	return release, nil
+}
+
+// wakeWaitersLocked 唤醒所有排队的请求（调用方需持有 mu）
+func (l *RequestLimiter) wakeWaitersLocked() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// Status 返回当前队列状态
+func (l *RequestLimiter) Status() RequestQueueStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return RequestQueueStatus{
+		Limit:  l.limit,
+		Active: l.active,
+		Queued: l.queued,
+	}
+}
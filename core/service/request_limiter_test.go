@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRequestLimiterSetLimitCountsRunningRequests(t *testing.T) {
+	l := NewRequestLimiter(2)
+	ctx := context.Background()
+
+	first, _ := l.Acquire(ctx)
+	second, _ := l.Acquire(ctx)
+
+	// 调低上限后，执行中的两个请求仍计入新上限
+	l.SetLimit(1)
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := l.Acquire(ctx)
+		if err == nil {
+			acquired <- release
+		}
+	}()
+
+	first()
+	select {
+	case <-acquired:
+		t.Fatal("a queued request started while the running requests still exceeded the new limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if status := l.Status(); status.Active != 1 || status.Queued != 1 || status.Limit != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	second()
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(2 * time.Second):
+		t.Fatal("the queued request did not start after a slot was released")
+	}
+}
+
+func TestRequestLimiterRaisedLimitWakesWaiters(t *testing.T) {
+	l := NewRequestLimiter(1)
+	ctx := context.Background()
+	hold, _ := l.Acquire(ctx)
+	defer hold()
+
+	acquired := make(chan func(), 1)
+	go func() {
+		release, err := l.Acquire(ctx)
+		if err == nil {
+			acquired <- release
+		}
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	l.SetLimit(2)
+	select {
+	case release := <-acquired:
+		release()
+	case <-time.After(2 * time.Second):
+		t.Fatal("raising the limit did not start the queued request")
+	}
+}
+
+func TestRequestLimiterAcquireCancelled(t *testing.T) {
+	l := NewRequestLimiter(1)
+	hold, _ := l.Acquire(context.Background())
+	defer hold()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the queued request to be cancelled, got %v", err)
+	}
+	if status := l.Status(); status.Queued != 0 || status.Active != 1 {
+		t.Fatalf("unexpected status after cancellation %+v", status)
+	}
+}
@@ -0,0 +1,240 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	// UnfinishedRequestsEvent 启动时发现上次未完成的请求时发送的事件
+	// 载荷为 JSON 字符串：[PendingRequest...]
+	UnfinishedRequestsEvent = "ai:unfinished-requests"
+	// maxRecoveryAttempts 同一请求最多被恢复的次数，超过后丢弃，避免崩溃循环中反复重放
+	maxRecoveryAttempts = 3
+	// maxRecoveryParamsBytes 记录的请求参数大小上限，超过时只记录请求信息（无法重新执行）
+	maxRecoveryParamsBytes = 256 << 10
+)
+
+// PendingRequest 进行中的请求记录
+type PendingRequest struct {
+	RequestID     string          `json:"requestId"`
+	Operation     string          `json:"operation"` // "generateImage", "generateImages", "editImage"
+	Provider      string          `json:"provider"`
+	Params        json.RawMessage `json:"params,omitempty"`        // 原始请求参数 JSON
+	ParamsOmitted bool            `json:"paramsOmitted,omitempty"` // 参数过大（如内联了 base64 图像）未记录
+	CreatedAt     int64           `json:"createdAt"`               // 开始时间（Unix 毫秒）
+	Attempts      int             `json:"attempts"`                // 已被恢复（启动时发现未完成）的次数
+}
+
+// RequestRecovery 记录进行中的请求，应用异常退出后可在下次启动时提示重新执行
+// 持久化到 pending_requests.json，请求结束（成功、失败或取消）时移除对应记录
+type RequestRecovery struct {
+	recoveryFile string
+	pending      map[string]PendingRequest
+	unfinished   []PendingRequest // 启动时发现的未完成请求
+	mu           sync.Mutex
+}
+
+// NewRequestRecovery 创建请求恢复记录器
+func NewRequestRecovery(dataDir string) *RequestRecovery {
+	return &RequestRecovery{
+		recoveryFile: filepath.Join(dataDir, "pending_requests.json"),
+		pending:      make(map[string]PendingRequest),
+	}
+}
+
+// Load 加载上次运行遗留的请求记录
+// 遗留记录视为未完成的请求，恢复次数加一；超过 maxRecoveryAttempts 的记录被丢弃
+func (r *RequestRecovery) Load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.recoveryFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read recovery file: %w", err)
+	}
+
+	var entries []PendingRequest
+	if err := json.Unmarshal(data, &entries); err != nil {
+		// 记录文件损坏时直接丢弃，不影响启动
+		r.pending = make(map[string]PendingRequest)
+		r.saveLocked()
+		return fmt.Errorf("invalid recovery file format: %w", err)
+	}
+
+	r.pending = make(map[string]PendingRequest, len(entries))
+	r.unfinished = nil
+	for _, entry := range entries {
+		entry.Attempts++
+		if entry.Attempts > maxRecoveryAttempts {
+			fmt.Printf("[RequestRecovery] Dropping request %s after %d recovery attempts\n", entry.RequestID, entry.Attempts-1)
+			continue
+		}
+		r.pending[entry.RequestID] = entry
+		r.unfinished = append(r.unfinished, entry)
+	}
+
+	return r.saveLocked()
+}
+
+// Track 记录一个开始执行的请求
+// 重新执行已恢复的请求（相同 requestID）时保留其恢复次数
+func (r *RequestRecovery) Track(requestID, operation, providerName, paramsJSON string) {
+	entry := PendingRequest{
+		RequestID: requestID,
+		Operation: operation,
+		Provider:  providerName,
+		CreatedAt: time.Now().UnixMilli(),
+	}
+	if len(paramsJSON) > maxRecoveryParamsBytes || !json.Valid([]byte(paramsJSON)) {
+		entry.ParamsOmitted = true
+	} else {
+		entry.Params = json.RawMessage(paramsJSON)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.pending[requestID]; ok {
+		entry.Attempts = existing.Attempts
+	}
+	r.pending[requestID] = entry
+	if err := r.saveLocked(); err != nil {
+		fmt.Printf("[RequestRecovery] Warning: failed to save recovery file: %v\n", err)
+	}
+}
+
+// Complete 移除已结束的请求记录
+func (r *RequestRecovery) Complete(requestID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pending[requestID]; !ok {
+		return
+	}
+	delete(r.pending, requestID)
+	if err := r.saveLocked(); err != nil {
+		fmt.Printf("[RequestRecovery] Warning: failed to save recovery file: %v\n", err)
+	}
+}
+
+// Unfinished 返回启动时发现的、尚未重新执行或忽略的请求（按开始时间排序）
+func (r *RequestRecovery) Unfinished() []PendingRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]PendingRequest, 0, len(r.unfinished))
+	for _, entry := range r.unfinished {
+		// 已重新执行（被新的记录替换或已完成）的请求不再返回
+		if current, ok := r.pending[entry.RequestID]; ok && current.CreatedAt == entry.CreatedAt {
+			result = append(result, entry)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+	return result
+}
+
+// Dismiss 忽略未完成的请求，requestID 为空时忽略全部
+func (r *RequestRecovery) Dismiss(requestID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.unfinished[:0]
+	for _, entry := range r.unfinished {
+		if requestID == "" || entry.RequestID == requestID {
+			if current, ok := r.pending[entry.RequestID]; ok && current.CreatedAt == entry.CreatedAt {
+				delete(r.pending, entry.RequestID)
+			}
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	r.unfinished = kept
+	return r.saveLocked()
+}
+
+// saveLocked 使用临时文件 + 原子性重命名写入记录文件（调用方需持有锁）
+func (r *RequestRecovery) saveLocked() error {
+	entries := make([]PendingRequest, 0, len(r.pending))
+	for _, entry := range r.pending {
+		entries = append(entries, entry)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to serialize recovery file: %w", err)
+	}
+
+	tempFile := r.recoveryFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp recovery file: %w", err)
+	}
+	if err := os.Rename(tempFile, r.recoveryFile); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename recovery file: %w", err)
+	}
+	return nil
+}
+
+// ==================== AIService 集成 ====================
+
+// trackPendingRequest 记录进行中的请求（内部方法），返回请求结束时必须调用的函数
+func (a *AIService) trackPendingRequest(requestID, operation, providerName, paramsJSON string) func() {
+	if a.requestRecovery == nil {
+		return func() {}
+	}
+	a.requestRecovery.Track(requestID, operation, providerName, paramsJSON)
+	return func() { a.requestRecovery.Complete(requestID) }
+}
+
+// emitUnfinishedRequests 发送上次运行未完成的请求（内部方法）
+// 启动时前端可能尚未注册监听，前端也可以通过 ListUnfinishedRequests 主动获取
+func (a *AIService) emitUnfinishedRequests() {
+	if a.ctx == nil || a.requestRecovery == nil {
+		return
+	}
+	unfinished := a.requestRecovery.Unfinished()
+	if len(unfinished) == 0 {
+		return
+	}
+	data, err := json.Marshal(unfinished)
+	if err != nil {
+		fmt.Printf("[AIService] Warning: failed to serialize unfinished requests: %v\n", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, UnfinishedRequestsEvent, string(data))
+}
+
+// ListUnfinishedRequests 列出上次运行未完成的请求
+// 返回 JSON 数组：[PendingRequest...]；重新执行时使用相同的 requestId 可保留恢复次数
+func (a *AIService) ListUnfinishedRequests() (string, error) {
+	unfinished := make([]PendingRequest, 0)
+	if a.requestRecovery != nil {
+		unfinished = a.requestRecovery.Unfinished()
+	}
+	data, err := json.Marshal(unfinished)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize unfinished requests: %w", err)
+	}
+	return string(data), nil
+}
+
+// DismissUnfinishedRequest 忽略上次运行未完成的请求，requestID 为空时忽略全部
+func (a *AIService) DismissUnfinishedRequest(requestID string) error {
+	if a.requestRecovery == nil {
+		return nil
+	}
+	return a.requestRecovery.Dismiss(requestID)
+}
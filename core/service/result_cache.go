@@ -0,0 +1,127 @@
+package service
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+)
+
+// defaultResultCacheSize 结果缓存默认最大条目数
+const defaultResultCacheSize = 100
+
+// ResultCache AI 调用结果的 LRU 缓存
+// 以 (提供商, 模型, 操作, 规范化参数) 的哈希为键，避免重复调用消耗 API 配额
+type ResultCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // 最近使用的条目在前
+}
+
+// resultCacheEntry 缓存条目
+type resultCacheEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewResultCache 创建结果缓存
+// maxEntries <= 0 时使用默认大小
+func NewResultCache(maxEntries int) *ResultCache {
+	if maxEntries <= 0 {
+		maxEntries = defaultResultCacheSize
+	}
+	return &ResultCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// ResultCacheKey 根据提供商、模型、操作和参数计算缓存键
+func ResultCacheKey(providerName, model, operation string, params interface{}) (string, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(providerName))
+	hash.Write([]byte{0})
+	hash.Write([]byte(model))
+	hash.Write([]byte{0})
+	hash.Write([]byte(operation))
+	hash.Write([]byte{0})
+	hash.Write(data)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Get 获取缓存结果
+func (c *ResultCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*resultCacheEntry).value, true
+}
+
+// Put 写入缓存结果，超出容量时淘汰最久未使用的条目
+func (c *ResultCache) Put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*resultCacheEntry).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&resultCacheEntry{key: key, value: value})
+	c.evictLocked()
+}
+
+// SetMaxEntries 调整缓存容量
+func (c *ResultCache) SetMaxEntries(maxEntries int) {
+	if maxEntries <= 0 {
+		maxEntries = defaultResultCacheSize
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.maxEntries = maxEntries
+	c.evictLocked()
+}
+
+// Clear 清空缓存
+func (c *ResultCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Len 返回当前缓存条目数
+func (c *ResultCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// evictLocked 淘汰超出容量的条目（调用方需持有锁）
+func (c *ResultCache) evictLocked() {
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		if oldest == nil {
+			return
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*resultCacheEntry).key)
+	}
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"strings"
+)
+
+// redactedPlaceholder 替换密钥时使用的占位符
+const redactedPlaceholder = "[REDACTED]"
+
+// minSecretLength 参与替换的最短密钥长度，避免误替换过短的普通字符串
+const minSecretLength = 6
+
+// redactedError 已脱敏的错误
+// Error() 返回脱敏后的消息，Unwrap() 保留原始错误以支持 errors.Is（如 context.Canceled）
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// secretValues 收集配置中的所有密钥值
+func secretValues(settings types.AISettings) []string {
+	secrets := []string{
+		settings.APIKey,
+		settings.OpenAIAPIKey,
+		settings.OpenAIImageAPIKey,
+		settings.CloudToken,
+		settings.StabilityAPIKey,
+		settings.ReplicateAPIToken,
+		settings.VertexCredentials,
+	}
+
+	// Vertex 凭证是 JSON，错误信息中可能只出现其中的私钥片段
+	if settings.VertexCredentials != "" {
+		var credentials map[string]interface{}
+		if err := json.Unmarshal([]byte(settings.VertexCredentials), &credentials); err == nil {
+			for _, key := range []string{"private_key", "private_key_id", "client_secret", "refresh_token"} {
+				if value, ok := credentials[key].(string); ok {
+					secrets = append(secrets, value)
+				}
+			}
+		}
+	}
+
+	return secrets
+}
+
+// redactSecrets 将消息中出现的所有已知密钥替换为占位符
+func redactSecrets(message string, settings types.AISettings) string {
+	for _, secret := range secretValues(settings) {
+		secret = strings.TrimSpace(secret)
+		if len(secret) < minSecretLength {
+			continue
+		}
+		message = strings.ReplaceAll(message, secret, redactedPlaceholder)
+	}
+	return message
+}
+
+// sanitizeError 移除错误信息中的密钥（APIKey、OpenAIAPIKey、CloudToken、VertexCredentials 等）
+// 在错误返回前端或打印日志前调用
+func (a *AIService) sanitizeError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*redactedError); ok {
+		return err
+	}
+
+	aiSettings, loadErr := a.loadAISettings()
+	if loadErr != nil {
+		return err
+	}
+
+	message := redactSecrets(err.Error(), aiSettings)
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+// sanitizeMessage 移除普通消息字符串中的密钥
+func (a *AIService) sanitizeMessage(message string) string {
+	if message == "" {
+		return message
+	}
+	aiSettings, err := a.loadAISettings()
+	if err != nil {
+		return message
+	}
+	return redactSecrets(message, aiSettings)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// minFreeDiskBytes 自检时数据目录所在磁盘的最低剩余空间
+const minFreeDiskBytes = 500 << 20
+
+// SelfTestCheck 单项自检结果
+type SelfTestCheck struct {
+	Name    string `json:"name"`              // 检查项："dataDir"、"imagesDir"、"provider"、"history"、"diskSpace"
+	Passed  bool   `json:"passed"`            // 是否通过
+	Message string `json:"message,omitempty"` // 失败原因或附加信息
+}
+
+// SelfTestReport 自检报告
+type SelfTestReport struct {
+	Passed     bool            `json:"passed"`     // 所有检查项均通过
+	Checks     []SelfTestCheck `json:"checks"`     // 各检查项结果（按执行顺序）
+	DurationMs int64           `json:"durationMs"` // 自检耗时（毫秒）
+}
+
+// RunSelfTest 执行一键诊断：数据目录与图片目录可写、当前提供商可用、历史文件可解析、磁盘剩余空间
+// 单项失败不影响其余检查项的执行
+func RunSelfTest(aiService *AIService, historyService *HistoryService) SelfTestReport {
+	startTime := time.Now()
+	var checks []SelfTestCheck
+	addCheck := func(name string, err error, message string) {
+		check := SelfTestCheck{Name: name, Passed: err == nil, Message: message}
+		if err != nil {
+			check.Message = err.Error()
+		}
+		checks = append(checks, check)
+	}
+
+	dataDir, err := ResolveDataDir()
+	if err == nil {
+		err = checkDirWritable(dataDir)
+	}
+	addCheck("dataDir", err, dataDir)
+
+	imagesDir, err := ResolveImagesDir()
+	message := imagesDir
+	if err == nil {
+		err = checkDirWritable(imagesDir)
+	}
+	if err == nil {
+		if size, sizeErr := NewImageStorage(filepath.Dir(imagesDir)).GetStorageSize(); sizeErr == nil {
+			message = fmt.Sprintf("%s (%s used)", imagesDir, formatByteSize(size))
+		}
+	}
+	addCheck("imagesDir", err, message)
+
+	checks = append(checks, checkProviderForSelfTest(aiService))
+
+	if historyService == nil {
+		addCheck("history", fmt.Errorf("history service not initialized"), "")
+	} else {
+		addCheck("history", historyService.VerifyHistory(), "")
+	}
+
+	if dataDir != "" {
+		free, err := diskFreeBytes(dataDir)
+		if err == nil && free < minFreeDiskBytes {
+			err = fmt.Errorf("only %s free (at least %s recommended)", formatByteSize(int64(free)), formatByteSize(minFreeDiskBytes))
+		}
+		addCheck("diskSpace", err, fmt.Sprintf("%s free", formatByteSize(int64(free))))
+	} else {
+		addCheck("diskSpace", fmt.Errorf("data dir unavailable"), "")
+	}
+
+	report := SelfTestReport{Passed: true, Checks: checks, DurationMs: time.Since(startTime).Milliseconds()}
+	for _, check := range checks {
+		if !check.Passed {
+			report.Passed = false
+			break
+		}
+	}
+	return report
+}
+
+// checkProviderForSelfTest 检查当前配置的提供商是否可用（内部函数）
+func checkProviderForSelfTest(aiService *AIService) SelfTestCheck {
+	check := SelfTestCheck{Name: "provider"}
+	if aiService == nil {
+		check.Message = "AI service not initialized"
+		return check
+	}
+	aiSettings, err := aiService.loadAISettings()
+	if err != nil {
+		check.Message = err.Error()
+		return check
+	}
+
+	available, message, err := aiService.CheckProviderAvailability(aiSettings.Provider, true)
+	switch {
+	case err != nil:
+		check.Message = fmt.Sprintf("%s: %v", aiSettings.Provider, err)
+	case !available:
+		check.Message = fmt.Sprintf("%s: %s", aiSettings.Provider, message)
+	default:
+		check.Passed = true
+		check.Message = aiSettings.Provider
+	}
+	return check
+}
+
+// formatByteSize 将字节数格式化为易读的字符串（如 "1.5 GB"）
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ValidateSettings 校验指定提供商所需的配置项
+// 返回缺失或无效字段的描述列表，列表为空表示配置完整
+// providerName 为空时校验当前配置的提供商
+func (a *AIService) ValidateSettings(providerName string) ([]string, error) {
+	aiSettings, err := a.loadAISettings()
+	if err != nil {
+		return nil, err
+	}
+	if providerName == "" {
+		providerName = aiSettings.Provider
+	}
+	return validateProviderSettings(providerName, aiSettings), nil
+}
+
+// validateProviderSettings 校验提供商配置（内部函数）
+func validateProviderSettings(providerName string, settings types.AISettings) []string {
+	var problems []string
+
+	switch providerName {
+	case "gemini":
+		if settings.UseVertexAI {
+			if settings.VertexProject == "" || settings.VertexLocation == "" {
+				problems = append(problems, "vertex requires vertexProject and vertexLocation")
+			}
+			if settings.VertexCredentials != "" && !json.Valid([]byte(settings.VertexCredentials)) {
+				problems = append(problems, "vertexCredentials is not valid JSON")
+			}
+		} else if settings.APIKey == "" {
+			problems = append(problems, "gemini requires apiKey")
+		}
+	case "openai":
+		if settings.OpenAIAPIKey == "" {
+			problems = append(problems, "openai requires openaiApiKey")
+		}
+		if problem := validateURLSetting("openaiBaseUrl", settings.OpenAIBaseURL); problem != "" {
+			problems = append(problems, problem)
+		}
+		if problem := validateURLSetting("openaiImageBaseUrl", settings.OpenAIImageBaseURL); problem != "" {
+			problems = append(problems, problem)
+		}
+		switch settings.OpenAIImageMode {
+		case "", types.OpenAIImageModeAuto, types.OpenAIImageModeImageAPI, types.OpenAIImageModeChat:
+		default:
+			problems = append(problems, fmt.Sprintf("openaiImageMode %q is invalid (expected auto, image_api or chat)", settings.OpenAIImageMode))
+		}
+	case "cloud":
+		if settings.CloudEndpointURL == "" || settings.CloudToken == "" {
+			problems = append(problems, "cloud requires cloudEndpointUrl and cloudToken")
+		}
+		if problem := validateURLSetting("cloudEndpointUrl", settings.CloudEndpointURL); problem != "" {
+			problems = append(problems, problem)
+		}
+	case "stability":
+		if settings.StabilityAPIKey == "" {
+			problems = append(problems, "stability requires stabilityApiKey")
+		}
+		if problem := validateURLSetting("stabilityBaseUrl", settings.StabilityBaseURL); problem != "" {
+			problems = append(problems, problem)
+		}
+	case "local":
+		if settings.LocalEndpointURL == "" {
+			problems = append(problems, "local requires localEndpointUrl")
+		}
+		if problem := validateURLSetting("localEndpointUrl", settings.LocalEndpointURL); problem != "" {
+			problems = append(problems, problem)
+		}
+		if settings.LocalSteps < 0 {
+			problems = append(problems, fmt.Sprintf("localSteps %d must not be negative", settings.LocalSteps))
+		}
+	case "replicate":
+		if settings.ReplicateAPIToken == "" {
+			problems = append(problems, "replicate requires replicateApiToken")
+		}
+		if settings.ReplicateModel != "" && strings.Count(strings.SplitN(settings.ReplicateModel, ":", 2)[0], "/") != 1 {
+			problems = append(problems, fmt.Sprintf("replicateModel %q is invalid (expected owner/name or owner/name:version)", settings.ReplicateModel))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported AI provider: %s", providerName))
+	}
+
+	return problems
+}
+
+// validateURLSetting 校验可选的 URL 类配置项，非空时必须是 http/https 地址
+func validateURLSetting(field, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Sprintf("%s must be an http(s) URL", field)
+	}
+	return ""
+}
+
+// formatSettingsProblems 将配置问题列表格式化为一条可展示的消息
+func formatSettingsProblems(problems []string) string {
+	return "配置不完整: " + strings.Join(problems, "; ")
+}
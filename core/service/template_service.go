@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// templatePlaceholderPattern 模板占位符格式：{{name}}，名称允许字母、数字、下划线和连字符
+var templatePlaceholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_\-]+)\s*\}\}`)
+
+// PromptTemplate 提示词模板
+type PromptTemplate struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Content     string            `json:"content"`            // 模板内容，使用 {{name}} 作为占位符
+	Variables   []string          `json:"variables"`          // 模板中出现的占位符名称（保存时自动提取）
+	Defaults    map[string]string `json:"defaults,omitempty"` // 占位符默认值（可选）
+	CreatedAt   int64             `json:"createdAt"`
+	UpdatedAt   int64             `json:"updatedAt"`
+}
+
+// TemplateFile 模板文件数据结构
+type TemplateFile struct {
+	Version   string           `json:"version"`
+	UpdatedAt int64            `json:"updatedAt"`
+	Templates []PromptTemplate `json:"templates"`
+}
+
+// TemplateService 提示词模板服务
+// 提供命名提示词模板的保存、列出、删除和渲染功能，持久化到 config/templates.json
+type TemplateService struct {
+	ctx          context.Context
+	templateFile string
+	templates    []PromptTemplate
+	mu           sync.Mutex
+}
+
+// NewTemplateService 创建提示词模板服务实例
+func NewTemplateService() *TemplateService {
+	return &TemplateService{}
+}
+
+// Startup 在应用启动时调用
+func (t *TemplateService) Startup(ctx context.Context) error {
+	t.ctx = ctx
+
+	dataDir, err := ResolveDataDir()
+	if err != nil {
+		return fmt.Errorf("failed to resolve data dir: %w", err)
+	}
+
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create app data dir: %w", err)
+	}
+
+	t.templateFile = filepath.Join(dataDir, "templates.json")
+	return t.load()
+}
+
+// load 从磁盘加载模板（内部方法）
+func (t *TemplateService) load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := os.ReadFile(t.templateFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			t.templates = []PromptTemplate{}
+			return nil
+		}
+		return fmt.Errorf("failed to read template file: %w", err)
+	}
+
+	var file TemplateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid template file format: %w", err)
+	}
+	t.templates = file.Templates
+	return nil
+}
+
+// saveLocked 使用临时文件 + 原子性重命名写入模板文件（调用方需持有锁）
+func (t *TemplateService) saveLocked() error {
+	if t.templateFile == "" {
+		return fmt.Errorf("template service not initialized")
+	}
+
+	file := TemplateFile{
+		Version:   "1.0",
+		UpdatedAt: time.Now().Unix(),
+		Templates: t.templates,
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize templates: %w", err)
+	}
+
+	tempFile := t.templateFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp template file: %w", err)
+	}
+	if err := os.Rename(tempFile, t.templateFile); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename template file: %w", err)
+	}
+	return nil
+}
+
+// SaveTemplate 保存提示词模板
+// templateJSON: PromptTemplate 的 JSON，id 为空时创建新模板，否则更新同 id 的模板
+// 返回保存后的模板 JSON
+func (t *TemplateService) SaveTemplate(templateJSON string) (string, error) {
+	var template PromptTemplate
+	if err := json.Unmarshal([]byte(templateJSON), &template); err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	template.Name = strings.TrimSpace(template.Name)
+	if template.Name == "" {
+		return "", fmt.Errorf("template name is required")
+	}
+	if strings.TrimSpace(template.Content) == "" {
+		return "", fmt.Errorf("template content is required")
+	}
+	template.Variables = extractTemplateVariables(template.Content)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now().Unix()
+	template.UpdatedAt = now
+
+	index := -1
+	if template.ID != "" {
+		for i := range t.templates {
+			if t.templates[i].ID == template.ID {
+				index = i
+				break
+			}
+		}
+	}
+
+	if index >= 0 {
+		template.CreatedAt = t.templates[index].CreatedAt
+		t.templates[index] = template
+	} else {
+		if template.ID == "" {
+			id, err := newTemplateID()
+			if err != nil {
+				return "", err
+			}
+			template.ID = id
+		}
+		template.CreatedAt = now
+		t.templates = append(t.templates, template)
+	}
+
+	if err := t.saveLocked(); err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize template: %w", err)
+	}
+	return string(data), nil
+}
+
+// ListTemplates 列出全部提示词模板（按名称排序）
+// 返回 JSON 数组
+func (t *TemplateService) ListTemplates() (string, error) {
+	t.mu.Lock()
+	templates := make([]PromptTemplate, len(t.templates))
+	copy(templates, t.templates)
+	t.mu.Unlock()
+
+	sort.SliceStable(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	data, err := json.Marshal(templates)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize templates: %w", err)
+	}
+	return string(data), nil
+}
+
+// DeleteTemplate 删除指定 id 的提示词模板
+func (t *TemplateService) DeleteTemplate(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i := range t.templates {
+		if t.templates[i].ID == id {
+			t.templates = append(t.templates[:i], t.templates[i+1:]...)
+			return t.saveLocked()
+		}
+	}
+	return fmt.Errorf("template not found: %s", id)
+}
+
+// RenderTemplate 使用变量渲染提示词模板
+// varsJSON: 变量名到值的 JSON 对象，未提供的变量使用模板默认值
+// 存在既没有传入值也没有默认值的占位符时返回错误
+func (t *TemplateService) RenderTemplate(id string, varsJSON string) (string, error) {
+	vars := map[string]string{}
+	if strings.TrimSpace(varsJSON) != "" {
+		if err := json.Unmarshal([]byte(varsJSON), &vars); err != nil {
+			return "", fmt.Errorf("invalid template variables: %w", err)
+		}
+	}
+
+	t.mu.Lock()
+	var template *PromptTemplate
+	for i := range t.templates {
+		if t.templates[i].ID == id {
+			found := t.templates[i]
+			template = &found
+			break
+		}
+	}
+	t.mu.Unlock()
+
+	if template == nil {
+		return "", fmt.Errorf("template not found: %s", id)
+	}
+
+	var missing []string
+	missingSeen := make(map[string]bool)
+	rendered := templatePlaceholderPattern.ReplaceAllStringFunc(template.Content, func(match string) string {
+		name := templatePlaceholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		if value, ok := template.Defaults[name]; ok {
+			return value
+		}
+		if !missingSeen[name] {
+			missingSeen[name] = true
+			missing = append(missing, name)
+		}
+		return match
+	})
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing template variables: %s", strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
+
+// extractTemplateVariables 提取模板中的占位符名称（去重，保持出现顺序）
+func extractTemplateVariables(content string) []string {
+	variables := []string{}
+	seen := make(map[string]bool)
+	for _, match := range templatePlaceholderPattern.FindAllStringSubmatch(content, -1) {
+		name := match[1]
+		if !seen[name] {
+			seen[name] = true
+			variables = append(variables, name)
+		}
+	}
+	return variables
+}
+
+// newTemplateID 生成随机模板 ID
+func newTemplateID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate template id: %w", err)
+	}
+	return "tpl_" + hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/inconshreveable/go-update"
+	"github.com/run-bigpig/go-github-selfupdate/selfupdate"
+)
+
+// ErrUpdateCancelled 更新被 CancelUpdate 取消
+var ErrUpdateCancelled = errors.New("更新已取消")
+
+// updateDownloadSuffix 更新包下载过程中的临时文件后缀（与可执行文件位于同一目录）
+// 以 .tmp 结尾，异常退出遗留的文件会被 CleanupOldFiles 清理
+const updateDownloadSuffix = ".download.tmp"
+
+// updateSpaceFactor 更新所需磁盘空间相对更新包大小的倍数
+// 下载的更新包、解压出的新可执行文件和替换时保留的旧文件同时存在于可执行文件所在目录
+const updateSpaceFactor = 3
+
+// beginUpdate 登记一次进行中的更新（内部方法）
+// 返回可取消的上下文和更新结束时必须调用的函数；已有更新在进行时返回错误
+func (u *UpdateService) beginUpdate() (context.Context, func(), error) {
+	u.updateMu.Lock()
+	defer u.updateMu.Unlock()
+
+	if u.cancelUpdate != nil {
+		return nil, nil, fmt.Errorf("更新正在进行中")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	u.cancelUpdate = cancel
+
+	finish := func() {
+		u.updateMu.Lock()
+		u.cancelUpdate = nil
+		u.updateMu.Unlock()
+		cancel()
+	}
+	return ctx, finish, nil
+}
+
+// CancelUpdate 取消进行中的更新
+// 下载阶段可随时取消，已下载的临时文件会被删除，并发送 "cancelled" 进度事件；
+// 安装阶段（替换可执行文件）不可中断。没有进行中的更新时返回 false
+func (u *UpdateService) CancelUpdate() bool {
+	u.updateMu.Lock()
+	defer u.updateMu.Unlock()
+
+	if u.cancelUpdate == nil {
+		return false
+	}
+	u.cancelUpdate()
+	return true
+}
+
+// downloadUpdateAsset 下载更新包到 destPath（内部方法）
+// 上下文取消时中断下载并删除不完整的文件
+func downloadUpdateAsset(ctx context.Context, assetURL, destPath string, progress selfupdate.ProgressCallback) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, assetURL, nil)
+	if err != nil {
+		return fmt.Errorf("创建下载请求失败: %w", err)
+	}
+	req.Header.Add("Accept", "application/octet-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("下载更新包失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("下载更新包失败: 状态码 %d", resp.StatusCode)
+	}
+
+	total := int64(-1)
+	if resp.ContentLength > 0 {
+		total = resp.ContentLength
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+
+	var downloaded int64
+	buf := make([]byte, 32<<10)
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				file.Close()
+				os.Remove(destPath)
+				return fmt.Errorf("写入临时文件失败: %w", err)
+			}
+			downloaded += int64(n)
+			if progress != nil {
+				progress(downloaded, total)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			file.Close()
+			os.Remove(destPath)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("下载更新包失败: %w", readErr)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(destPath)
+		return fmt.Errorf("写入临时文件失败: %w", err)
+	}
+	return nil
+}
+
+// applyUpdateAsset 解压已下载的更新包并替换可执行文件（内部方法）
+func applyUpdateAsset(assetPath, assetURL, exe string) error {
+	file, err := os.Open(assetPath)
+	if err != nil {
+		return fmt.Errorf("打开更新包失败: %w", err)
+	}
+	defer file.Close()
+
+	asset, err := selfupdate.UncompressCommand(file, assetURL, filepath.Base(exe))
+	if err != nil {
+		return err
+	}
+	return update.Apply(asset, update.Options{TargetPath: exe})
+}
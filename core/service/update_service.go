@@ -8,6 +8,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/blang/semver"
@@ -22,6 +24,9 @@ type UpdateService struct {
 	repoOwner      string // GitHub 仓库所有者
 	repoName       string // GitHub 仓库名称
 	currentVersion string // 当前版本号
+
+	updateMu     sync.Mutex
+	cancelUpdate context.CancelFunc // 进行中的更新的取消函数，没有更新时为 nil
 }
 
 // UpdateInfo 更新信息
@@ -36,7 +41,7 @@ type UpdateInfo struct {
 
 // UpdateProgress 更新进度信息
 type UpdateProgress struct {
-	Status  string `json:"status"`  // "checking", "downloading", "installing", "completed", "error"
+	Status  string `json:"status"`  // "checking", "downloading", "installing", "completed", "cancelled", "error"
 	Message string `json:"message"` // 状态消息
 	Percent int    `json:"percent"` // 进度百分比 (0-100)
 }
@@ -180,7 +185,14 @@ func (u *UpdateService) emitProgress(status, message string, percent int) {
 // Update 执行更新（下载并替换当前可执行文件）
 // 通过 Wails Event 系统实时推送更新进度
 // 注意：在 Wails 应用中，更新完成后需要重启应用才能生效
+// 下载完成前可通过 CancelUpdate 取消，此时返回 ErrUpdateCancelled
 func (u *UpdateService) Update() error {
+	ctx, finish, err := u.beginUpdate()
+	if err != nil {
+		return err
+	}
+	defer finish()
+
 	// 发送初始进度
 	u.emitProgress("checking", "正在检查更新...", 0)
 
@@ -198,6 +210,10 @@ func (u *UpdateService) Update() error {
 		u.emitProgress("error", "未找到更新", 0)
 		return fmt.Errorf("未找到更新")
 	}
+	if ctx.Err() != nil {
+		u.emitProgress("cancelled", "更新已取消", 0)
+		return ErrUpdateCancelled
+	}
 
 	// 解析当前版本并检查是否需要更新
 	currentVer, err := semver.ParseTolerant(u.currentVersion)
@@ -218,6 +234,14 @@ func (u *UpdateService) Update() error {
 		return fmt.Errorf("获取可执行文件路径失败: %w", err)
 	}
 
+	// 下载前检查可执行文件所在磁盘的剩余空间，避免下载或替换到一半时写入失败
+	if latest.AssetByteSize > 0 {
+		if err := checkFreeSpace(filepath.Dir(exe), int64(latest.AssetByteSize)*updateSpaceFactor); err != nil {
+			u.emitProgress("error", fmt.Sprintf("磁盘空间不足，无法下载更新: %v", err), 0)
+			return fmt.Errorf("磁盘空间不足: %w", err)
+		}
+	}
+
 	// 执行更新（使用带进度回调的版本）
 	// 下载进度范围：30% - 70%（下载阶段），70% - 90%（安装阶段）
 	downloadStartPercent := 30
@@ -259,14 +283,28 @@ func (u *UpdateService) Update() error {
 	// 开始下载
 	u.emitProgress("downloading", fmt.Sprintf("正在下载版本 %s...", latest.Version.String()), downloadStartPercent)
 
-	// 执行更新（带进度回调）
-	if err := selfupdate.UpdateToWithProcess(latest.AssetURL, exe, progressCallback); err != nil {
+	// 下载到可执行文件所在目录的临时文件，取消或失败时删除
+	assetPath := exe + updateDownloadSuffix
+	defer os.Remove(assetPath)
+	if err := downloadUpdateAsset(ctx, latest.AssetURL, assetPath, progressCallback); err != nil {
+		if ctx.Err() != nil {
+			u.emitProgress("cancelled", "更新已取消", 0)
+			return ErrUpdateCancelled
+		}
 		u.emitProgress("error", fmt.Sprintf("更新失败: %v", err), 0)
 		return fmt.Errorf("更新失败: %w", err)
 	}
+	if ctx.Err() != nil {
+		u.emitProgress("cancelled", "更新已取消", 0)
+		return ErrUpdateCancelled
+	}
 
-	// 安装阶段
+	// 安装阶段（替换可执行文件，不可取消）
 	u.emitProgress("installing", "正在安装更新...", installEndPercent)
+	if err := applyUpdateAsset(assetPath, latest.AssetURL, exe); err != nil {
+		u.emitProgress("error", fmt.Sprintf("更新失败: %v", err), 0)
+		return fmt.Errorf("更新失败: %w", err)
+	}
 
 	// 更新完成
 	u.emitProgress("completed", fmt.Sprintf("更新完成！新版本 %s 已安装，应用将在几秒后自动重启...", latest.Version.String()), 100)
@@ -297,8 +335,17 @@ func (u *UpdateService) RestartApplication() error {
 		// Windows: 使用 PowerShell 启动新进程，延迟执行以确保当前进程先退出
 		// 使用 Start-Sleep 延迟 2 秒后启动新进程
 		cmd = exec.Command("powershell.exe", "-Command", fmt.Sprintf("Start-Sleep -Seconds 2; Start-Process -FilePath '%s' -WorkingDirectory '%s'", exePath, filepath.Dir(exePath)))
-	case "darwin", "linux":
-		// macOS/Linux: 使用 sh 启动新进程
+	case "darwin":
+		// macOS: 位于 .app 包内时通过 open 重新打开整个应用包，直接启动包内的二进制
+		// 无法正确关联 Dock 图标和应用激活状态；不在应用包内时与 Linux 相同
+		if bundlePath, ok := macAppBundlePath(exePath); ok {
+			fmt.Printf("[UpdateService] 检测到应用包: %s\n", bundlePath)
+			cmd = exec.Command("sh", "-c", `sleep 2 && open -n "$0"`, bundlePath)
+		} else {
+			cmd = exec.Command("sh", "-c", fmt.Sprintf("sleep 2 && %s", exePath))
+		}
+	case "linux":
+		// Linux: 使用 sh 启动新进程
 		cmd = exec.Command("sh", "-c", fmt.Sprintf("sleep 2 && %s", exePath))
 	default:
 		return fmt.Errorf("不支持的操作系统: %s", runtime.GOOS)
@@ -323,12 +370,29 @@ func (u *UpdateService) RestartApplication() error {
 	go func() {
 		time.Sleep(2 * time.Second)
 		fmt.Printf("[UpdateService] 退出当前进程\n")
+		// os.Exit 不会执行 OnShutdown，退出前写出剩余日志
+		FlushLogs()
 		os.Exit(0)
 	}()
 
 	return nil
 }
 
+// macAppBundlePath 判断可执行文件是否位于 macOS 应用包内（X.app/Contents/MacOS/<exe>）
+// 是则返回应用包路径
+func macAppBundlePath(exePath string) (string, bool) {
+	macOSDir := filepath.Dir(exePath)
+	contentsDir := filepath.Dir(macOSDir)
+	bundlePath := filepath.Dir(contentsDir)
+	if filepath.Base(macOSDir) != "MacOS" || filepath.Base(contentsDir) != "Contents" {
+		return "", false
+	}
+	if !strings.HasSuffix(strings.ToLower(filepath.Base(bundlePath)), ".app") {
+		return "", false
+	}
+	return bundlePath, true
+}
+
 // GetExecutableName 获取当前平台的可执行文件名
 func GetExecutableName() string {
 	ext := ""
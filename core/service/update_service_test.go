@@ -0,0 +1,37 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMacAppBundlePath(t *testing.T) {
+	cases := []struct {
+		name       string
+		exePath    string
+		wantBundle string
+		wantOK     bool
+	}{
+		{name: "app bundle", exePath: "/Applications/Artifex.app/Contents/MacOS/Artifex", wantBundle: "/Applications/Artifex.app", wantOK: true},
+		{name: "bundle name with spaces", exePath: "/Users/me/My Apps/Artifex Beta.app/Contents/MacOS/Artifex", wantBundle: "/Users/me/My Apps/Artifex Beta.app", wantOK: true},
+		{name: "uppercase app suffix", exePath: "/Applications/Artifex.APP/Contents/MacOS/Artifex", wantBundle: "/Applications/Artifex.APP", wantOK: true},
+		{name: "plain binary", exePath: "/usr/local/bin/artifex", wantOK: false},
+		{name: "binary next to bundle", exePath: "/Applications/Artifex.app/artifex", wantOK: false},
+		{name: "missing contents dir", exePath: "/Applications/Artifex.app/MacOS/Artifex", wantOK: false},
+		{name: "contents layout without app suffix", exePath: "/opt/Artifex/Contents/MacOS/Artifex", wantOK: false},
+		{name: "other directory inside bundle", exePath: "/Applications/Artifex.app/Contents/Resources/helper", wantOK: false},
+		{name: "relative bundle path", exePath: "build/bin/Artifex.app/Contents/MacOS/Artifex", wantBundle: "build/bin/Artifex.app", wantOK: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			bundle, ok := macAppBundlePath(filepath.FromSlash(tc.exePath))
+			if ok != tc.wantOK {
+				t.Fatalf("macAppBundlePath(%q) ok = %v, want %v", tc.exePath, ok, tc.wantOK)
+			}
+			if want := filepath.FromSlash(tc.wantBundle); ok && bundle != want {
+				t.Fatalf("macAppBundlePath(%q) = %q, want %q", tc.exePath, bundle, want)
+			}
+		})
+	}
+}
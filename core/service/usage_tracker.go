@@ -0,0 +1,209 @@
+package service
+
+import (
+	"artifex/core/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxUsageRecords 用量文件中保留的最大记录数，超出时丢弃最旧的记录
+const maxUsageRecords = 50000
+
+// usageSaveDelay 记录用量后延迟写盘的时间，期间的多次记录合并为一次写入
+const usageSaveDelay = 2 * time.Second
+
+// UsageRecord 单次 AI 调用的用量记录
+type UsageRecord struct {
+	Timestamp    int64  `json:"timestamp"` // Unix 时间戳（秒）
+	Provider     string `json:"provider"`
+	Model        string `json:"model,omitempty"`
+	Operation    string `json:"operation"` // "generateImage", "editImage", "removeBackground", "enhancePrompt"
+	InputTokens  int    `json:"inputTokens,omitempty"`
+	OutputTokens int    `json:"outputTokens,omitempty"`
+	TotalTokens  int    `json:"totalTokens,omitempty"`
+	Images       int    `json:"images,omitempty"`
+}
+
+// UsageFile 用量文件数据结构
+type UsageFile struct {
+	Version   string        `json:"version"`
+	UpdatedAt int64         `json:"updatedAt"`
+	Records   []UsageRecord `json:"records"`
+}
+
+// UsageTotals 聚合后的用量统计
+type UsageTotals struct {
+	Calls        int `json:"calls"`
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+	TotalTokens  int `json:"totalTokens"`
+	Images       int `json:"images"`
+}
+
+// UsageStats 用量统计结果
+type UsageStats struct {
+	Since       int64                  `json:"since"`
+	Total       UsageTotals            `json:"total"`
+	ByProvider  map[string]UsageTotals `json:"byProvider"`
+	ByModel     map[string]UsageTotals `json:"byModel"`
+	ByOperation map[string]UsageTotals `json:"byOperation"`
+}
+
+// UsageTracker 用量统计器
+// 记录每次 AI 调用的提供商、模型、操作类型和 token/图像数量，持久化到 config/usage.json
+// 写盘延迟 usageSaveDelay 合并执行，关闭时通过 Flush 写入剩余的记录
+type UsageTracker struct {
+	usageFile string
+	records   []UsageRecord
+	dirty     bool        // 有尚未写盘的记录
+	saveTimer *time.Timer // 待执行的延迟写盘，nil 表示没有
+	mu        sync.Mutex
+}
+
+// NewUsageTracker 创建用量统计器
+func NewUsageTracker(dataDir string) *UsageTracker {
+	return &UsageTracker{
+		usageFile: filepath.Join(dataDir, "usage.json"),
+	}
+}
+
+// Load 从磁盘加载已有的用量记录
+func (t *UsageTracker) Load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := os.ReadFile(t.usageFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read usage file: %w", err)
+	}
+
+	var file UsageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("invalid usage file format: %w", err)
+	}
+	t.records = file.Records
+	return nil
+}
+
+// Record 记录一次调用的用量，在 usageSaveDelay 后写入磁盘
+func (t *UsageTracker) Record(providerName, model, operation string, usage *types.Usage) {
+	record := UsageRecord{
+		Timestamp: time.Now().Unix(),
+		Provider:  providerName,
+		Model:     model,
+		Operation: operation,
+	}
+	if usage != nil {
+		record.InputTokens = usage.InputTokens
+		record.OutputTokens = usage.OutputTokens
+		record.TotalTokens = usage.TotalTokens
+		record.Images = usage.Images
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.records = append(t.records, record)
+	if len(t.records) > maxUsageRecords {
+		t.records = t.records[len(t.records)-maxUsageRecords:]
+	}
+
+	t.dirty = true
+	if t.saveTimer == nil {
+		t.saveTimer = time.AfterFunc(usageSaveDelay, func() {
+			if err := t.Flush(); err != nil {
+				fmt.Printf("[UsageTracker] Warning: failed to save usage records: %v\n", err)
+			}
+		})
+	}
+}
+
+// Flush 立即写入尚未保存的用量记录
+// 写入失败时保留未保存标记，下一次 Flush 会重试
+func (t *UsageTracker) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.saveTimer != nil {
+		t.saveTimer.Stop()
+		t.saveTimer = nil
+	}
+	if !t.dirty {
+		return nil
+	}
+	if err := t.saveLocked(); err != nil {
+		return err
+	}
+	t.dirty = false
+	return nil
+}
+
+// saveLocked 使用临时文件 + 原子性重命名写入用量文件（调用方需持有锁）
+func (t *UsageTracker) saveLocked() error {
+	file := UsageFile{
+		Version:   "1.0",
+		UpdatedAt: time.Now().Unix(),
+		Records:   t.records,
+	}
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("failed to serialize usage: %w", err)
+	}
+
+	tempFile := t.usageFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temp usage file: %w", err)
+	}
+	if err := os.Rename(tempFile, t.usageFile); err != nil {
+		os.Remove(tempFile)
+		return fmt.Errorf("failed to rename usage file: %w", err)
+	}
+	return nil
+}
+
+// Stats 聚合 sinceUnix 之后（含）的用量记录
+// sinceUnix <= 0 时统计全部记录
+func (t *UsageTracker) Stats(sinceUnix int64) UsageStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := UsageStats{
+		Since:       sinceUnix,
+		ByProvider:  make(map[string]UsageTotals),
+		ByModel:     make(map[string]UsageTotals),
+		ByOperation: make(map[string]UsageTotals),
+	}
+
+	add := func(totals UsageTotals, record UsageRecord) UsageTotals {
+		totals.Calls++
+		totals.InputTokens += record.InputTokens
+		totals.OutputTokens += record.OutputTokens
+		totals.TotalTokens += record.TotalTokens
+		totals.Images += record.Images
+		return totals
+	}
+
+	for _, record := range t.records {
+		if sinceUnix > 0 && record.Timestamp < sinceUnix {
+			continue
+		}
+		stats.Total = add(stats.Total, record)
+		stats.ByProvider[record.Provider] = add(stats.ByProvider[record.Provider], record)
+		model := record.Model
+		if model == "" {
+			model = "unknown"
+		}
+		stats.ByModel[model] = add(stats.ByModel[model], record)
+		stats.ByOperation[record.Operation] = add(stats.ByOperation[record.Operation], record)
+	}
+
+	return stats
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"artifex/core/types"
+	"os"
+	"testing"
+)
+
+func TestUsageTrackerBatchesWrites(t *testing.T) {
+	dir := t.TempDir()
+	tracker := NewUsageTracker(dir)
+
+	for i := 0; i < 3; i++ {
+		tracker.Record("openai", "gpt-image-1", "generateImage", &types.Usage{Images: 1})
+	}
+
+	// 延迟写盘期间不应产生任何写入
+	if _, err := os.Stat(tracker.usageFile); !os.IsNotExist(err) {
+		t.Fatalf("usage file written before the save delay: %v", err)
+	}
+
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	reloaded := NewUsageTracker(dir)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	stats := reloaded.Stats(0)
+	if stats.Total.Calls != 3 || stats.Total.Images != 3 {
+		t.Fatalf("reloaded totals = %+v, want 3 calls and 3 images", stats.Total)
+	}
+}
+
+func TestUsageTrackerFlushWithoutRecords(t *testing.T) {
+	tracker := NewUsageTracker(t.TempDir())
+	if err := tracker.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if _, err := os.Stat(tracker.usageFile); !os.IsNotExist(err) {
+		t.Fatalf("Flush wrote a usage file with no records: %v", err)
+	}
+}
@@ -43,6 +43,64 @@ type AISettings struct {
 	// Cloud 云服务配置
 	CloudEndpointURL string `json:"cloudEndpointUrl"` // 云服务端点 URL
 	CloudToken       string `json:"cloudToken"`       // 云服务认证 Token（加密存储）
+
+	// Stability AI 配置
+	StabilityAPIKey  string `json:"stabilityApiKey"`  // Stability API Key（加密存储）
+	StabilityBaseURL string `json:"stabilityBaseUrl"` // API 地址，默认 https://api.stability.ai
+	StabilityModel   string `json:"stabilityModel"`   // "core"、"ultra" 或 SD3 模型（如 "sd3.5-large"），默认 "core"
+
+	// 本地 Stable Diffusion（Automatic1111 WebUI）配置
+	LocalEndpointURL string `json:"localEndpointUrl"` // WebUI 地址，默认 http://127.0.0.1:7860
+	LocalSampler     string `json:"localSampler"`     // 默认采样器，默认 "Euler a"
+	LocalSteps       int    `json:"localSteps"`       // 默认采样步数（<= 0 时使用默认值 30）
+
+	// Replicate 配置
+	ReplicateAPIToken string `json:"replicateApiToken"` // Replicate API Token（加密存储）
+	ReplicateModel    string `json:"replicateModel"`    // 模型标识 "owner/name" 或 "owner/name:version"，默认 "black-forest-labs/flux-schnell"
+
+	// 并发控制配置
+	// 同时进行的图像生成/编辑调用上限，超出的请求排队等待（<= 0 时使用默认值 3）
+	MaxConcurrentRequests int `json:"maxConcurrentRequests"`
+
+	// 提供商 HTTP 连接配置（<= 0 时使用默认值），修改后提供商重新创建时生效
+	// 网络不稳定时闲置过久的 keep-alive 连接可能已失效，缩短空闲保留时间可避免空闲后第一次请求失败
+	ProviderIdleConnTimeout int `json:"providerIdleConnTimeout"` // 空闲连接保留时间（秒，默认 30）
+	ProviderMaxIdleConns    int `json:"providerMaxIdleConns"`    // 每个提供商保留的空闲连接数上限（默认 10）
+	ProviderDialTimeout     int `json:"providerDialTimeout"`     // 建立连接的超时时间（秒，默认 30）
+
+	// 输入图像大小上限（字节，按解码后的大小计算，<= 0 时使用默认值 8MB）
+	// 超过上限的输入图像在发送给提供商前按比例缩小并重新编码，存储中的原图不受影响
+	MaxInputImageBytes int64 `json:"maxInputImageBytes"`
+
+	// 生成图像的存储格式："png"、"jpeg"，为空时按提供商返回的格式保存（默认）
+	// 不支持 "webp"（缺少 WebP 编码器）；含透明像素的图像不会转换为 JPEG
+	StoredImageFormat string `json:"storedImageFormat"`
+
+	// 保存图像需要有损重新编码（如转存为 JPEG）时使用的质量（1-100，<= 0 时使用默认值 90）
+	// PNG 为无损格式，不受此设置影响；按原始格式保存的图像不会重新编码
+	StoredImageQuality int `json:"storedImageQuality"`
+
+	// 允许保存的生成图像 MIME 类型（如 ["image/png", "image/jpeg", "image/webp"]），为空时不限制（默认）
+	// 提供商返回不在列表中的类型时该次调用返回错误，而不是按 .png 保存
+	AllowedImageMimeTypes []string `json:"allowedImageMimeTypes,omitempty"`
+
+	// 结果缓存配置（默认关闭）
+	// 相同提供商、模型和参数的重复调用直接返回上次结果，节省 API 配额
+	ResultCacheEnabled    bool `json:"resultCacheEnabled"`    // 是否缓存提示词增强结果
+	ResultCacheImages     bool `json:"resultCacheImages"`     // 是否同时缓存图像生成结果（仅在指定了固定种子时生效）
+	ResultCacheMaxEntries int  `json:"resultCacheMaxEntries"` // 最大缓存条目数（<= 0 时使用默认值 100）
+
+	// 文件日志配置（默认开启）
+	// 诊断输出同时写入 <数据目录>/logs/app.log，按大小轮转并保留最近几个文件
+	FileLoggingEnabled bool `json:"fileLoggingEnabled"`
+
+	// 历史文件压缩配置（默认关闭）
+	// 启用后聊天和画布历史以 gzip 压缩保存；读取时按文件内容自动识别，压缩和未压缩的文件均可加载
+	CompressHistoryFiles bool `json:"compressHistoryFiles"`
+
+	// 编辑提示词自动改写配置
+	// 提示词命中规则关键词（如"放大"、"扩图"）时替换为预设提示词，规则来自 config/prompt_rewrites.json；默认关闭
+	PromptRewriteEnabled bool `json:"promptRewriteEnabled"`
 }
 
 // OpenAI 图像模式常量
@@ -52,27 +110,97 @@ const (
 	OpenAIImageModeChat     = "chat"      // 使用 Chat Completion API
 )
 
+// 图像存储格式常量
+const (
+	StoredImageFormatPNG  = "png"
+	StoredImageFormatJPEG = "jpeg"
+)
+
 // ==================== AI 服务参数结构体 ====================
 
 // GenerateImageParams 图像生成参数
 type GenerateImageParams struct {
 	Prompt         string `json:"prompt"`
-	ReferenceImage string `json:"referenceImage,omitempty"` // base64 编码的参考图像
-	SketchImage    string `json:"sketchImage,omitempty"`    // base64 编码的草图图像
+	ReferenceImage string `json:"referenceImage,omitempty"` // 参考图像（data URL 或 image ref）
+	SketchImage    string `json:"sketchImage,omitempty"`    // 草图图像（data URL 或 image ref）
 	ImageSize      string `json:"imageSize"`                // "1K", "2K", "4K"
 	AspectRatio    string `json:"aspectRatio"`              // "1:1", "16:9", "9:16", "3:4", "4:3"
+	NegativePrompt string `json:"negativePrompt,omitempty"` // 反向提示词，描述不希望出现的元素（可选）
+	Seed           int64  `json:"seed,omitempty"`           // 随机种子，0 表示随机（可选）
+	Count          int    `json:"count,omitempty"`          // 批量生成数量，默认 1（仅 GenerateImages 使用）
+	Model          string `json:"model,omitempty"`          // 模型覆盖，为空时使用设置中的图像模型（可选）
+	Preview        bool   `json:"preview,omitempty"`        // 生成过程中尽快发送一张中间预览（ai:preview 事件，需要提供商支持 Preview，可选）
+	Provider       string `json:"provider,omitempty"`       // 提供商覆盖（如 "openai"），仅本次调用使用，为空时使用设置中的提供商（可选）
 }
 
 // MultiImageEditParams 多图编辑参数
 type MultiImageEditParams struct {
-	Images      []string `json:"images"`                // base64 编码的图像数组（支持单图或多图）
-	Prompt      string   `json:"prompt"`                // 编辑提示词
-	ImageSize   string   `json:"imageSize,omitempty"`   // 图片尺寸，可选值："1K", "2K", "4K"（可选）
-	AspectRatio string   `json:"aspectRatio,omitempty"` // 宽高比，可选值："1:1", "16:9", "9:16", "3:4", "4:3"（可选）
+	Images            []string `json:"images"`                      // 输入图像数组，每项为 data URL 或 image ref（支持单图或多图）
+	Prompt            string   `json:"prompt"`                      // 编辑提示词
+	ImageSize         string   `json:"imageSize,omitempty"`         // 图片尺寸，可选值："1K", "2K", "4K"（可选）
+	AspectRatio       string   `json:"aspectRatio,omitempty"`       // 宽高比，可选值："1:1", "16:9", "9:16", "3:4", "4:3"（可选）
+	NegativePrompt    string   `json:"negativePrompt,omitempty"`    // 反向提示词，描述不希望出现的元素（可选）
+	Seed              int64    `json:"seed,omitempty"`              // 随机种子，0 表示随机（可选）
+	Mask              string   `json:"mask,omitempty"`              // 遮罩图像（data URL 或 image ref），白色区域为可编辑区域（可选，需要提供商支持 Inpaint）
+	BestEffort        bool     `json:"bestEffort,omitempty"`        // 尽力模式：跳过无法读取或解码的输入图像，使用其余图像继续编辑（可选）
+	SkipPromptRewrite bool     `json:"skipPromptRewrite,omitempty"` // 本次调用跳过提示词自动改写，即使全局启用了改写（可选）
+	Model             string   `json:"model,omitempty"`             // 模型覆盖，为空时使用设置中的图像模型（可选）
+	Provider          string   `json:"provider,omitempty"`          // 提供商覆盖（如 "openai"），仅本次调用使用，为空时使用设置中的提供商（可选）
+}
+
+// RemoveBackgroundParams 背景移除参数
+type RemoveBackgroundParams struct {
+	Image         string `json:"image"`                   // base64 编码的图像或 image ref
+	FallbackColor string `json:"fallbackColor,omitempty"` // 提供商无法输出透明背景时使用的纯色背景（如 "#ffffff"，可选）
 }
 
 // EnhancePromptParams 增强提示词参数
 type EnhancePromptParams struct {
 	Prompt          string   `json:"prompt"`                    // 原始提示词
-	ReferenceImages []string `json:"referenceImages,omitempty"` // base64 编码的参考图像数组（可选）
+	ReferenceImages []string `json:"referenceImages,omitempty"` // 参考图像数组，每项为 data URL 或 image ref（可选）
+	Model           string   `json:"model,omitempty"`           // 模型覆盖，为空时使用设置中的默认模型（可选）
+}
+
+// ==================== AI 服务结果结构体 ====================
+
+// ImageResult 提供商返回的图像结果
+type ImageResult struct {
+	Image         string            `json:"image"`                   // 图像数据（data URI、http URL 或 image ref）
+	Model         string            `json:"model,omitempty"`         // 实际使用的模型
+	RevisedPrompt string            `json:"revisedPrompt,omitempty"` // 提供商改写后的提示词（如 DALL-E 3）
+	Metadata      map[string]string `json:"metadata,omitempty"`      // 提供商回传的其他元数据
+	Usage         *Usage            `json:"usage,omitempty"`         // 提供商回传的用量信息（可选）
+}
+
+// PromptResult 提供商返回的提示词增强结果
+type PromptResult struct {
+	Text  string `json:"text"`            // 增强后的提示词
+	Model string `json:"model,omitempty"` // 实际使用的模型
+	Usage *Usage `json:"usage,omitempty"` // 提供商回传的用量信息（可选）
+}
+
+// RemoveBackgroundResult 背景移除结果
+type RemoveBackgroundResult struct {
+	Image       string `json:"image"`             // 结果图像 ref（始终保存为 PNG）
+	Transparent bool   `json:"transparent"`       // 结果是否包含透明像素
+	Warning     string `json:"warning,omitempty"` // 结果不符合预期时的提示（如返回了不含透明通道的格式）
+}
+
+// Usage 单次调用的用量信息
+type Usage struct {
+	InputTokens  int `json:"inputTokens,omitempty"`  // 输入 token 数
+	OutputTokens int `json:"outputTokens,omitempty"` // 输出 token 数
+	TotalTokens  int `json:"totalTokens,omitempty"`  // 总 token 数
+	Images       int `json:"images,omitempty"`       // 生成的图像数量
+}
+
+// ==================== 文件导出结构体 ====================
+
+// ImageMetadata 嵌入到导出图像中的生成元数据
+type ImageMetadata struct {
+	Prompt         string `json:"prompt,omitempty"`         // 生成提示词
+	NegativePrompt string `json:"negativePrompt,omitempty"` // 反向提示词
+	Provider       string `json:"provider,omitempty"`       // 提供商名称
+	Model          string `json:"model,omitempty"`          // 使用的模型
+	Seed           int64  `json:"seed,omitempty"`           // 随机种子
 }
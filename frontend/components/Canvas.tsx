@@ -1256,7 +1256,7 @@ const Canvas: React.FC<CanvasProps> = ({
       const now = new Date();
       const formattedDate = `${now.getFullYear()}${String(now.getMonth() + 1).padStart(2, '0')}${String(now.getDate()).padStart(2, '0')}-${String(now.getHours()).padStart(2, '0')}${String(now.getMinutes()).padStart(2, '0')}${String(now.getSeconds()).padStart(2, '0')}`;
       const randomName = `artifexBot-${formattedDate}-${Math.random().toString(36).slice(2, 11)}.png`;
-      await ExportImage(img.src, randomName, 'png', '');
+      await ExportImage(img.src, JSON.stringify({ suggestedName: randomName, format: 'png', quality: 90 }));
     } catch (err) {
       console.error('导出图片失败:', err);
     }
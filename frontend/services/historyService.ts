@@ -27,6 +27,29 @@ import { toImageRef } from '../utils/imageSource';
 let lastChatHistorySnapshot: string | null = null;
 let lastCanvasHistorySnapshot: string | null = null;
 
+/**
+ * 清除历史记录时删除图片的结果（pruneImages 为 false 时均为 0）
+ */
+export interface ImageCleanupResult {
+  deletedCount: number;   // 删除的图片文件数
+  reclaimedBytes: number; // 释放的磁盘空间（字节）
+}
+
+/**
+ * 解析后端返回的图片清理结果，格式异常时视为未删除任何图片
+ */
+const parseCleanupResult = (resultJSON: string): ImageCleanupResult => {
+  try {
+    const result = JSON.parse(resultJSON);
+    return {
+      deletedCount: Number(result?.deletedCount) || 0,
+      reclaimedBytes: Number(result?.reclaimedBytes) || 0,
+    };
+  } catch {
+    return { deletedCount: 0, reclaimedBytes: 0 };
+  }
+};
+
 /**
  * 深度比较两个数据是否相同（通过 JSON 序列化比较）
  * @param data1 第一个数据
@@ -150,12 +173,15 @@ export const saveChatHistory = activityDebounce(_saveChatHistoryInternal, 10000)
 
 /**
  * 清除聊天历史记录
+ * @param pruneImages 是否同时删除只被聊天历史引用的图片（画布仍引用的图片保留）
+ * @returns 图片清理结果
  */
-export const clearChatHistory = async (): Promise<void> => {
+export const clearChatHistory = async (pruneImages: boolean = false): Promise<ImageCleanupResult> => {
   try {
-    await ClearChatHistory();
+    const resultJSON = await ClearChatHistory(pruneImages);
     // 清除快照
     lastChatHistorySnapshot = '[]';
+    return parseCleanupResult(resultJSON);
   } catch (error) {
     console.error('Failed to clear chat history:', error);
     throw error;
@@ -211,6 +237,12 @@ const _saveCanvasHistoryInternal = (viewport: Viewport, images: CanvasImage[]):
         // 数据未变化，跳过保存
         return;
       }
+      // 只有视口变化（平移/缩放）时只保存视口，后端写入独立的小文件，不必重新序列化全部图像
+      if (isDataEqual(data.images, lastData.images)) {
+        lastCanvasHistorySnapshot = JSON.stringify(data);
+        EventsEmit('history:save-viewport', JSON.stringify(viewport));
+        return;
+      }
     } catch (error) {
       // 解析失败，继续执行保存
       console.warn('[HistoryService] 解析上次画布快照失败，将执行保存:', error);
@@ -371,13 +403,16 @@ export const saveCanvasHistorySync = async (viewport: Viewport, images: CanvasIm
 
 /**
  * 清除画布历史记录
+ * @param pruneImages 是否同时删除只被画布历史引用的图片（聊天记录仍引用的图片保留）
+ * @returns 图片清理结果
  */
-export const clearCanvasHistory = async (): Promise<void> => {
+export const clearCanvasHistory = async (pruneImages: boolean = false): Promise<ImageCleanupResult> => {
   try {
-    await ClearCanvasHistory();
+    const resultJSON = await ClearCanvasHistory(pruneImages);
     // 清除快照
     const emptyData = { viewport: { x: 0, y: 0, zoom: 1 }, images: [] };
     lastCanvasHistorySnapshot = JSON.stringify(emptyData);
+    return parseCleanupResult(resultJSON);
   } catch (error) {
     console.error('Failed to clear canvas history:', error);
     throw error;
@@ -1,4 +1,4 @@
-import { CheckForUpdate, GetCurrentVersion, Update, RestartApplication } from '../wailsjs/go/core/App';
+import { CheckForUpdate, GetCurrentVersion, Update, CancelUpdate, RestartApplication } from '../wailsjs/go/core/App';
 import { EventsOn, EventsOff } from '../wailsjs/runtime/runtime';
 
 /**
@@ -17,7 +17,7 @@ export interface UpdateInfo {
  * 更新进度信息接口
  */
 export interface UpdateProgress {
-  status: 'checking' | 'downloading' | 'installing' | 'completed' | 'error';
+  status: 'checking' | 'downloading' | 'installing' | 'completed' | 'cancelled' | 'error';
   message: string;
   percent: number;
 }
@@ -64,6 +64,19 @@ export const update = async (): Promise<string> => {
   }
 };
 
+/**
+ * 取消进行中的更新下载
+ * @returns 是否有进行中的更新被取消
+ */
+export const cancelUpdate = async (): Promise<boolean> => {
+  try {
+    return await CancelUpdate();
+  } catch (error) {
+    console.error('取消更新失败:', error);
+    return false;
+  }
+};
+
 /**
  * 执行更新（带进度信息，通过事件监听）
  * @param onProgress 进度回调函数
@@ -94,7 +107,7 @@ export const updateWithProgress = async (
           }
           unsubscribe();
           resolve(progress);
-        } else if (progress.status === 'error' && !isResolved) {
+        } else if ((progress.status === 'error' || progress.status === 'cancelled') && !isResolved) {
           isResolved = true;
           if (timeoutId) {
             clearTimeout(timeoutId);
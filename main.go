@@ -2,6 +2,7 @@ package main
 
 import (
 	"artifex/core"
+	"artifex/core/service"
 	"embed"
 
 	"github.com/wailsapp/wails/v2"
@@ -17,8 +18,14 @@ func main() {
 	// Create an instance of the app structure
 	app := core.NewApp()
 
+	// 图片资源目录与各服务共用同一路径
+	imagesDir, err := service.ResolveImagesDir()
+	if err != nil {
+		println("Warning: failed to resolve images dir:", err.Error())
+	}
+
 	// Create application with options
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:     "ArtifexBot",
 		Width:     1536,
 		Height:    960,
@@ -26,7 +33,7 @@ func main() {
 		MinHeight: 800,
 		AssetServer: &assetserver.Options{
 			Assets:  assets,
-			Handler: newImageAssetHandler(),
+			Handler: newImageAssetHandler(imagesDir),
 		},
 		// 深色背景，与前端 tech-900 (#0B0E14) 匹配
 		BackgroundColour: &options.RGBA{R: 11, G: 14, B: 20, A: 255},